@@ -14,6 +14,28 @@ type bundeslandStat struct {
 	Hospitalized  uint64
 	IntensiveCare uint64
 	Healed        uint64
+	Vaccination   vaccinationApiStat
+
+	HospitalFree             uint64
+	IntensiveCareFree        uint64
+	HospitalUtilisation      float64
+	IntensiveCareUtilisation float64
+
+	Tests          uint64
+	TestsPCR       uint64
+	TestsAntigen   uint64
+	TestsDaily     uint64
+	TestPositivity float64
+}
+
+type vaccinationApiStat struct {
+	Doses              uint64
+	FirstDose          uint64
+	SecondDose         uint64
+	BoosterDose        uint64
+	FirstDosePercent   float64
+	SecondDosePercent  float64
+	BoosterDosePercent float64
 }
 
 type bezirkStat struct {
@@ -24,19 +46,44 @@ type bezirkStat struct {
 }
 
 type overallStat struct {
-	TotalInfected            uint64
-	TotalDead                uint64
-	TotalHospitalized        uint64
-	TotalIntensiveCare       uint64
-	AgeDistributionInfection map[string]uint64
+	TotalInfected              uint64
+	TotalDead                  uint64
+	TotalHospitalized          uint64
+	TotalIntensiveCare         uint64
+	AgeDistributionInfection   map[string]uint64
+	Vaccination                vaccinationApiStat
+	AgeDistributionVaccination map[string]vaccinationApiStat
+	TotalTests                 uint64
+	TotalTestsPCR              uint64
+	TotalTestsAntigen          uint64
+	TestsDaily                 uint64
+	TestPositivity             float64
 }
 
 type api struct {
 	he *healthMinistryExporter
+	ve *vaccinationExporter
+	hc *hospitalExporter
+	te *testingsExporter
+
+	bezirkBoundaries     *boundaryProvider
+	bundeslandBoundaries *boundaryProvider
 }
 
-func newApi(he *healthMinistryExporter) *api {
-	return &api{he}
+func newApi(he *healthMinistryExporter, ve *vaccinationExporter, hc *hospitalExporter, te *testingsExporter) *api {
+	return &api{he, ve, hc, te, newBoundaryProvider("geo/bezirke.geojson"), newBoundaryProvider("geo/bundeslaender.geojson")}
+}
+
+func newVaccinationApiStat(s vaccinationStat) vaccinationApiStat {
+	return vaccinationApiStat{
+		Doses:              s.doses(),
+		FirstDose:          s.firstDose,
+		SecondDose:         s.secondDose,
+		BoosterDose:        s.boosterDose,
+		FirstDosePercent:   percent(s.firstDose, s.population),
+		SecondDosePercent:  percent(s.secondDose, s.population),
+		BoosterDosePercent: percent(s.boosterDose, s.population),
+	}
 }
 
 func (a *api) GetOverallStat() (overallStat, error) {
@@ -73,6 +120,26 @@ func (a *api) GetOverallStat() (overallStat, error) {
 		r.TotalInfected = uint64(confirmed.Value)
 	}
 
+	r.AgeDistributionVaccination = make(map[string]vaccinationApiStat)
+	if vaccinations, err := a.ve.getVaccinationStat(); err == nil {
+		if austria, ok := vaccinations["Austria"]; ok {
+			r.Vaccination = newVaccinationApiStat(austria.vaccinationStat)
+			for group, s := range austria.ageGroups {
+				r.AgeDistributionVaccination[group] = newVaccinationApiStat(*s)
+			}
+		}
+	}
+
+	if testings, err := a.te.getTestingsStat(); err == nil {
+		if austria, ok := testings["Austria"]; ok {
+			r.TotalTests = austria.tests
+			r.TotalTestsPCR = austria.pcr
+			r.TotalTestsAntigen = austria.antigen
+			r.TestsDaily = austria.newTests
+			r.TestPositivity = austria.positivity()
+		}
+	}
+
 	return r, nil
 }
 
@@ -81,25 +148,39 @@ func (a *api) GetBezirkStat() ([]bezirkStat, error) {
 }
 
 func (a *api) GetBundeslandStat() ([]bundeslandStat, error) {
-
-	result := make([]bundeslandStat, 0)
-	/*for k, v := range bundeslandStats {
-		data := a.se.mp.getMetadata(k)
-		hospitalized := uint64(0)
-		intensiveCare := uint64(0)
-		if v, ok := hospitalStat[k]; ok {
-			hospitalized = v.Hospitalized
-			intensiveCare = v.IntensiveCare
+	result, err := a.he.getBundeslandStat()
+	if err != nil {
+		return nil, err
+	}
+	if vaccinations, err := a.ve.getVaccinationStat(); err == nil {
+		for i := range result {
+			if s, ok := vaccinations[result[i].Name]; ok {
+				result[i].Vaccination = newVaccinationApiStat(s.vaccinationStat)
+			}
 		}
-		result = append(result, bundeslandStat{
-			Name:          k,
-			Infected:      v.infected,
-			Dead:          v.deaths,
-			Population:    data.population,
-			Hospitalized:  hospitalized,
-			IntensiveCare: intensiveCare,
-			Location:      apiLocaiton{Lat: data.location.lat, Long: data.location.long},
-		})
-	}*/
+	}
+	if hospitals, err := a.hc.getHospitalStat(); err == nil {
+		for i := range result {
+			if s, ok := hospitals[result[i].Name]; ok {
+				result[i].Hospitalized = s.hospitalized
+				result[i].HospitalFree = s.hospitalFree
+				result[i].HospitalUtilisation = utilisation(s.hospitalized, s.hospitalFree)
+				result[i].IntensiveCare = s.intensiveCare
+				result[i].IntensiveCareFree = s.intensiveCareFree
+				result[i].IntensiveCareUtilisation = utilisation(s.intensiveCare, s.intensiveCareFree)
+			}
+		}
+	}
+	if testings, err := a.te.getTestingsStat(); err == nil {
+		for i := range result {
+			if s, ok := testings[result[i].Name]; ok {
+				result[i].Tests = s.tests
+				result[i].TestsPCR = s.pcr
+				result[i].TestsAntigen = s.antigen
+				result[i].TestsDaily = s.newTests
+				result[i].TestPositivity = s.positivity()
+			}
+		}
+	}
 	return result, nil
 }
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+//The v2 api uses snake_case json fields with units in their names, ISO 8601 timestamps and official identifiers
+//(ISO 3166-2 for provinces, GKZ for districts). Every response is wrapped in an envelope with metadata.
+//The v1 types in api.go are frozen and must not be changed.
+
+const (
+	apiV2License     = "CC BY 4.0"
+	apiV2Attribution = "Bundesministerium für Soziales, Gesundheit, Pflege und Konsumentenschutz; AGES"
+
+	//the public names of the sources, the configured urls may be mirrors or local files
+	apiV2SourceMinistry = "Bundesministerium für Soziales, Gesundheit, Pflege und Konsumentenschutz"
+	apiV2SourceAges     = "AGES"
+)
+
+type apiV2Meta struct {
+	Source      []string   `json:"source"`
+	AsOf        *time.Time `json:"as_of"`
+	License     string     `json:"license"`
+	Attribution string     `json:"attribution"`
+}
+
+type apiV2Response struct {
+	Meta apiV2Meta   `json:"meta"`
+	Data interface{} `json:"data"`
+}
+
+type apiV2Error struct {
+	Error string `json:"error"`
+}
+
+type apiV2Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type apiV2Vaccination struct {
+	Doses              uint64  `json:"doses"`
+	FirstDose          uint64  `json:"first_dose"`
+	SecondDose         uint64  `json:"second_dose"`
+	BoosterDose        uint64  `json:"booster_dose"`
+	FirstDosePercent   float64 `json:"first_dose_percent"`
+	SecondDosePercent  float64 `json:"second_dose_percent"`
+	BoosterDosePercent float64 `json:"booster_dose_percent"`
+}
+
+type apiV2Tests struct {
+	Total               uint64  `json:"total"`
+	PCR                 uint64  `json:"pcr"`
+	Antigen             uint64  `json:"antigen"`
+	Daily               uint64  `json:"daily"`
+	Positivity7dPercent float64 `json:"positivity_7d_percent"`
+}
+
+type apiV2Bundesland struct {
+	ID                              string           `json:"id"`
+	GKZ                             string           `json:"gkz"`
+	Name                            string           `json:"name"`
+	Location                        apiV2Location    `json:"location"`
+	Population                      uint64           `json:"population"`
+	Infected                        uint64           `json:"infected"`
+	InfectedPer100k                 float64          `json:"infected_per_100k"`
+	Healed                          uint64           `json:"healed"`
+	Dead                            uint64           `json:"dead"`
+	Hospitalized                    uint64           `json:"hospitalized"`
+	IntensiveCare                   uint64           `json:"intensive_care"`
+	HospitalFreeBeds                uint64           `json:"hospital_free_beds"`
+	IntensiveCareFreeBeds           uint64           `json:"intensive_care_free_beds"`
+	HospitalUtilisationPercent      float64          `json:"hospital_utilisation_percent"`
+	IntensiveCareUtilisationPercent float64          `json:"intensive_care_utilisation_percent"`
+	Tests                           apiV2Tests       `json:"tests"`
+	Vaccination                     apiV2Vaccination `json:"vaccination"`
+}
+
+type apiV2Bezirk struct {
+	ID                 string        `json:"id"`
+	Name               string        `json:"name"`
+	ProvinceID         string        `json:"province_id"`
+	Location           apiV2Location `json:"location"`
+	Population         uint64        `json:"population"`
+	Infected           uint64        `json:"infected"`
+	InfectedPer100k    float64       `json:"infected_per_100k"`
+	Incidence7dPer100k *float64      `json:"incidence_7d_per_100k"`
+}
+
+type apiV2Locate struct {
+	Bezirk apiV2Bezirk `json:"bezirk"`
+}
+
+type apiV2Total struct {
+	ID                    string                      `json:"id"`
+	Name                  string                      `json:"name"`
+	Population            uint64                      `json:"population"`
+	Infected              uint64                      `json:"infected"`
+	InfectedPer100k       float64                     `json:"infected_per_100k"`
+	Dead                  uint64                      `json:"dead"`
+	Hospitalized          uint64                      `json:"hospitalized"`
+	IntensiveCare         uint64                      `json:"intensive_care"`
+	InfectedByAgeGroup    map[string]uint64           `json:"infected_by_age_group"`
+	Tests                 apiV2Tests                  `json:"tests"`
+	Vaccination           apiV2Vaccination            `json:"vaccination"`
+	VaccinationByAgeGroup map[string]apiV2Vaccination `json:"vaccination_by_age_group"`
+}
+
+type apiV2HistoryPoint struct {
+	Date   string            `json:"date"`
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+func newApiV2Location(l apiLocaiton) apiV2Location {
+	return apiV2Location{Latitude: l.Lat, Longitude: l.Long}
+}
+
+func newApiV2Vaccination(v vaccinationApiStat) apiV2Vaccination {
+	return apiV2Vaccination(v)
+}
+
+//provinceIso returns the ISO 3166-2 code of a province by its GKZ
+func provinceIso(gkz string) string {
+	if gkz == "" {
+		return ""
+	}
+	return "AT-" + gkz[:1]
+}
+
+func (a *api) GetOverallStatV2() (apiV2Total, error) {
+	s, err := a.GetOverallStat()
+	if err != nil {
+		return apiV2Total{}, err
+	}
+	population := populationOf("Austria")
+	result := apiV2Total{
+		ID:                 "AT",
+		Name:               "Österreich",
+		Population:         population,
+		Infected:           s.TotalInfected,
+		InfectedPer100k:    geoInfection100k(s.TotalInfected, population),
+		Dead:               s.TotalDead,
+		Hospitalized:       s.TotalHospitalized,
+		IntensiveCare:      s.TotalIntensiveCare,
+		InfectedByAgeGroup: s.AgeDistributionInfection,
+		Tests: apiV2Tests{
+			Total:               s.TotalTests,
+			PCR:                 s.TotalTestsPCR,
+			Antigen:             s.TotalTestsAntigen,
+			Daily:               s.TestsDaily,
+			Positivity7dPercent: s.TestPositivity * 100,
+		},
+		Vaccination:           newApiV2Vaccination(s.Vaccination),
+		VaccinationByAgeGroup: make(map[string]apiV2Vaccination, len(s.AgeDistributionVaccination)),
+	}
+	for group, v := range s.AgeDistributionVaccination {
+		result.VaccinationByAgeGroup[group] = newApiV2Vaccination(v)
+	}
+	return result, nil
+}
+
+func (a *api) GetBundeslandStatV2() ([]apiV2Bundesland, error) {
+	stats, err := a.GetBundeslandStat()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]apiV2Bundesland, 0, len(stats))
+	for _, s := range stats {
+		gkz := a.he.mp.getId(s.Name)
+		result = append(result, apiV2Bundesland{
+			ID:                              provinceIso(gkz),
+			GKZ:                             gkz,
+			Name:                            s.Name,
+			Location:                        newApiV2Location(s.Location),
+			Population:                      s.Population,
+			Infected:                        s.Infected,
+			InfectedPer100k:                 geoInfection100k(s.Infected, s.Population),
+			Healed:                          s.Healed,
+			Dead:                            s.Dead,
+			Hospitalized:                    s.Hospitalized,
+			IntensiveCare:                   s.IntensiveCare,
+			HospitalFreeBeds:                s.HospitalFree,
+			IntensiveCareFreeBeds:           s.IntensiveCareFree,
+			HospitalUtilisationPercent:      s.HospitalUtilisation * 100,
+			IntensiveCareUtilisationPercent: s.IntensiveCareUtilisation * 100,
+			Tests: apiV2Tests{
+				Total:               s.Tests,
+				PCR:                 s.TestsPCR,
+				Antigen:             s.TestsAntigen,
+				Daily:               s.TestsDaily,
+				Positivity7dPercent: s.TestPositivity * 100,
+			},
+			Vaccination: newApiV2Vaccination(s.Vaccination),
+		})
+	}
+	return result, nil
+}
+
+//newApiV2Bezirk converts a district, the incidence is null without history
+func (a *api) newApiV2Bezirk(row bezirkRow) apiV2Bezirk {
+	result := apiV2Bezirk{
+		ID:              row.gkz,
+		Name:            row.Name,
+		ProvinceID:      provinceIso(a.he.mp.getId(row.province)),
+		Location:        newApiV2Location(row.Location),
+		Population:      row.Population,
+		Infected:        row.Infected,
+		InfectedPer100k: geoInfection100k(row.Infected, row.Population),
+	}
+	if row.hasIncidence {
+		incidence := row.incidence
+		result.Incidence7dPer100k = &incidence
+	}
+	return result
+}
+
+func newApiV2History(points []historyPoint) []apiV2HistoryPoint {
+	result := make([]apiV2HistoryPoint, 0, len(points))
+	for _, p := range points {
+		result = append(result, apiV2HistoryPoint{Date: p.Date, Metric: p.Name, Labels: p.Tags, Value: p.Value})
+	}
+	return result
+}
+
+//apiV2AsOf returns the time of the upstream report, nil if it is unknown
+func apiV2AsOf(reported reportTimer) *time.Time {
+	date, err := reported.getReportTime()
+	if err != nil {
+		logger.Print(err)
+		return nil
+	}
+	return &date
+}
+
+//writeApiV2 wraps the result in the v2 envelope, csv contains only the data. The data of all sources is as of the
+//report of the health ministry.
+func writeApiV2(w http.ResponseWriter, r *http.Request, source []string, f func() (interface{}, error)) {
+	if wantsCsv(r) {
+		writeResult(w, r, f)
+		return
+	}
+	result, err := f()
+	if err != nil {
+		writeApiV2Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Add("Content-type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(apiV2Response{
+		Meta: apiV2Meta{Source: source, AsOf: apiV2AsOf(a.he), License: apiV2License, Attribution: apiV2Attribution},
+		Data: result,
+	})
+}
+
+func writeApiV2Error(w http.ResponseWriter, status int, message string) {
+	w.Header().Add("Content-type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiV2Error{message})
+}
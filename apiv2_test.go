@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiV2(t *testing.T) {
+	testApi, cleanup := newTestDashboardApi(t)
+	defer cleanup()
+	testApi.hc = newHospitalExporter(e.mp)
+	serveTestContent(t, &testApi.hc.url, hospitalCsv)
+
+	provinces, err := testApi.GetBundeslandStatV2()
+	assert.Nil(t, err)
+	assert.Equal(t, "AT-9", provinces[0].ID)
+	assert.Equal(t, "9", provinces[0].GKZ)
+	assert.Equal(t, "Wien", provinces[0].Name)
+	assert.Equal(t, uint64(12), provinces[0].Dead)
+	assert.InDelta(t, 1200/float64(provinces[0].Population)*100000, provinces[0].InfectedPer100k, 0.0001)
+	provincesV1, err := testApi.GetBundeslandStat()
+	assert.Nil(t, err)
+	assert.InDelta(t, 350.0/1200*100, provinces[0].HospitalUtilisationPercent, 0.0001)
+	assert.InDelta(t, 120.0/200*100, provinces[0].IntensiveCareUtilisationPercent, 0.0001)
+	assert.Equal(t, provincesV1[0].HospitalUtilisation*100, provinces[0].HospitalUtilisationPercent)
+	assert.Equal(t, provincesV1[0].IntensiveCareUtilisation*100, provinces[0].IntensiveCareUtilisationPercent)
+	assert.Equal(t, provincesV1[0].TestPositivity*100, provinces[0].Tests.Positivity7dPercent)
+
+	rows, err := testApi.getBezirkRows(nil, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, apiV2Bezirk{"601", "Graz(Stadt)", "AT-6", apiV2Location{47.070714, 15.439504}, 288806, 340, 340.0 / 288806 * 100000, nil}, testApi.newApiV2Bezirk(rows[0]))
+	assert.Equal(t, "900", testApi.newApiV2Bezirk(rows[1]).ID)
+	assert.Equal(t, "AT-9", testApi.newApiV2Bezirk(rows[1]).ProvinceID)
+	rows[0].incidence, rows[0].hasIncidence = 42, true
+	assert.Equal(t, 42.0, *testApi.newApiV2Bezirk(rows[0]).Incidence7dPer100k)
+
+	total, err := testApi.GetOverallStatV2()
+	assert.Nil(t, err)
+	assert.Equal(t, "AT", total.ID)
+	assert.Equal(t, uint64(1700), total.Infected)
+	assert.Equal(t, map[string]uint64{"<5": 10}, total.InfectedByAgeGroup)
+}
+
+func TestWriteApiV2(t *testing.T) {
+	defer func(url string) { he.url = url }(he.url)
+	serveTestContent(t, &he.url, `var Erkrankungen = "1.700"; var LetzteAktualisierung = "09.11.2020 10:00.00";`)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeApiV2(w, r, []string{apiV2SourceMinistry}, func() (interface{}, error) {
+			if r.URL.Query().Get("fail") != "" {
+				return nil, assert.AnError
+			}
+			return []apiV2Bezirk{{ID: "601", Name: "Graz(Stadt)", ProvinceID: "AT-6"}}, nil
+		})
+	}))
+	defer ts.Close()
+
+	response, err := ts.Client().Get(ts.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "application/json; charset=utf-8", response.Header.Get("Content-type"))
+	result := map[string]interface{}{}
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&result))
+	meta := result["meta"].(map[string]interface{})
+	assert.Equal(t, []interface{}{apiV2SourceMinistry}, meta["source"])
+	assert.Equal(t, apiV2License, meta["license"])
+	assert.Equal(t, "2020-11-09T10:00:00+01:00", meta["as_of"])
+	assert.Equal(t, "AT-6", result["data"].([]interface{})[0].(map[string]interface{})["province_id"])
+
+	response, err = ts.Client().Get(ts.URL + "?format=csv")
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(response.Body)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(body), "id,name,province_id,location_latitude,location_longitude,population,infected,infected_per_100k,incidence_7d_per_100k\r\n"))
+
+	response, err = ts.Client().Get(ts.URL + "?fail=1")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	apiError := apiV2Error{}
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&apiError))
+	assert.Equal(t, assert.AnError.Error(), apiError.Error)
+
+	serveTestContent(t, &he.url, `var Erkrankungen = "1.700";`)
+	response, err = ts.Client().Get(ts.URL)
+	assert.Nil(t, err)
+	result = map[string]interface{}{}
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&result))
+	assert.Contains(t, result["meta"], "as_of")
+	assert.Nil(t, result["meta"].(map[string]interface{})["as_of"])
+}
+
+func TestApiV1Frozen(t *testing.T) {
+	content, err := json.Marshal(bezirkStat{"Graz(Stadt)", apiLocaiton{47.07, 15.43}, 288806, 340})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"Name":"Graz(Stadt)","Location":{"Lat":47.07,"Long":15.43},"Population":288806,"Infected":340}`, string(content))
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+//archive stores every distinct upstream response content-addressed on disk
+type archive struct {
+	dir       string
+	retention time.Duration
+	compress  bool
+
+	mu        sync.Mutex
+	entries   []archiveEntry
+	latest    map[string]string
+	replaying bool
+	pruned    time.Time
+}
+
+type archiveEntry struct {
+	URL     string
+	Hash    string
+	Fetched time.Time
+	Headers http.Header
+}
+
+//upstreamArchive receives all fetched upstream responses if set
+var upstreamArchive *archive
+
+const archiveIndex = "index.jsonl"
+
+//openArchive loads the index of an archive directory, a retention of 0 keeps all responses
+func openArchive(dir string, retention time.Duration, compress bool) (*archive, error) {
+	err := os.MkdirAll(filepath.Join(dir, "objects"), 0755)
+	if err != nil {
+		return nil, err
+	}
+	a := &archive{dir: dir, retention: retention, compress: compress, latest: make(map[string]string)}
+	file, err := os.Open(filepath.Join(dir, archiveIndex))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			entry := archiveEntry{}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				return nil, err
+			}
+			a.entries = append(a.entries, entry)
+			a.latest[entry.URL] = entry.Hash
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return a, a.prune(time.Now())
+}
+
+func (a *archive) objectPath(hash string) string {
+	return filepath.Join(a.dir, "objects", hash[:2], hash)
+}
+
+//store archives a response unless it is identical to the last response of the same url
+func (a *archive) store(url string, headers http.Header, body []byte, fetched time.Time) error {
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.latest[url] == hash {
+		return nil
+	}
+	err := a.writeObject(hash, body)
+	if err != nil {
+		return err
+	}
+	entry := archiveEntry{URL: url, Hash: hash, Fetched: fetched.UTC(), Headers: headers}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(a.dir, archiveIndex), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+	a.entries = append(a.entries, entry)
+	a.latest[url] = hash
+	if time.Since(a.pruned) > time.Hour {
+		return a.pruneLocked(time.Now())
+	}
+	return nil
+}
+
+func (a *archive) writeObject(hash string, body []byte) error {
+	path := a.objectPath(hash)
+	if fileExists(path) {
+		return nil
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	content := body
+	if a.compress {
+		buffer := bytes.Buffer{}
+		writer := gzip.NewWriter(&buffer)
+		writer.Write(body)
+		writer.Close()
+		content = buffer.Bytes()
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, content, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+//read returns the archived body of an object, compressed objects are detected by their gzip header.
+//The caller holds the lock, so prune cannot remove the object while it is read
+func (a *archive) read(hash string) ([]byte, error) {
+	content, err := ioutil.ReadFile(a.objectPath(hash))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > 2 && content[0] == 0x1f && content[1] == 0x8b {
+		reader, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	}
+	return content, nil
+}
+
+//lookup returns the newest archived response of an url that was fetched before the given time
+func (a *archive) lookup(url string, before time.Time) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	hash := ""
+	for _, e := range a.entries {
+		if e.URL == url && !e.Fetched.After(before) {
+			hash = e.Hash
+		}
+	}
+	if hash == "" {
+		return nil, errors.New("No archived response for " + url)
+	}
+	return a.read(hash)
+}
+
+func (a *archive) isReplaying() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.replaying
+}
+
+func (a *archive) setReplaying(replaying bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.replaying = replaying
+}
+
+//days returns the distinct days on which responses were archived
+func (a *archive) days() []time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	seen := make(map[time.Time]bool)
+	result := make([]time.Time, 0)
+	for _, e := range a.entries {
+		day := calendarDay(e.Fetched)
+		if !seen[day] {
+			seen[day] = true
+			result = append(result, day)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+	return result
+}
+
+func (a *archive) prune(now time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.pruneLocked(now)
+}
+
+//pruneLocked removes index entries older than the retention and objects that are no longer referenced
+func (a *archive) pruneLocked(now time.Time) error {
+	a.pruned = now
+	if a.retention == 0 {
+		return nil
+	}
+	kept := make([]archiveEntry, 0, len(a.entries))
+	removed := make(map[string]bool)
+	for _, e := range a.entries {
+		if now.Sub(e.Fetched) > a.retention {
+			removed[e.Hash] = true
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	buffer := bytes.Buffer{}
+	for _, e := range kept {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buffer.Write(append(line, '\n'))
+		delete(removed, e.Hash)
+	}
+	tmp := filepath.Join(a.dir, archiveIndex+".tmp")
+	err := ioutil.WriteFile(tmp, buffer.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, filepath.Join(a.dir, archiveIndex))
+	if err != nil {
+		return err
+	}
+	for hash := range removed {
+		os.Remove(a.objectPath(hash))
+	}
+	a.entries = kept
+	a.latest = make(map[string]string)
+	for _, e := range kept {
+		a.latest[e.URL] = e.Hash
+	}
+	return nil
+}
+
+//rebuildHistory runs the exporters against the archived responses of every archived day
+func (a *archive) rebuildHistory(exporters []Exporter, history *historyStore) (int, error) {
+	a.setReplaying(true)
+	defer a.setReplaying(false)
+	defer replay.set(replay.get())
+
+	days := a.days()
+	for _, day := range days {
+		replay.set(day.Add(24*time.Hour - time.Nanosecond))
+		err := history.record(day, collectMetrics(exporters))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(days), nil
+}
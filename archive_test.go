@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestArchive(t *testing.T, retention time.Duration, compress bool) (*archive, func()) {
+	dir, err := ioutil.TempDir("", "archive")
+	assert.Nil(t, err)
+	a, err := openArchive(dir, retention, compress)
+	assert.Nil(t, err)
+	return a, func() { os.RemoveAll(dir) }
+}
+
+func TestArchiveStore(t *testing.T) {
+	a, cleanup := newTestArchive(t, 0, true)
+	defer cleanup()
+
+	day := time.Date(2020, 3, 20, 10, 0, 0, 0, time.UTC)
+	assert.Nil(t, a.store("http://upstream/a", http.Header{"Etag": {"1"}}, []byte("first"), day))
+	assert.Nil(t, a.store("http://upstream/a", nil, []byte("first"), day.Add(time.Hour)))
+	assert.Nil(t, a.store("http://upstream/a", nil, []byte("second"), day.Add(24*time.Hour)))
+	assert.Nil(t, a.store("http://upstream/b", nil, []byte("first"), day.Add(24*time.Hour)))
+	assert.Equal(t, 3, len(a.entries))
+
+	content, err := a.lookup("http://upstream/a", day.Add(2*time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, "first", string(content))
+	content, err = a.lookup("http://upstream/a", day.Add(48*time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, "second", string(content))
+	_, err = a.lookup("http://upstream/b", day)
+	assert.NotNil(t, err)
+
+	reopened, err := openArchive(a.dir, 0, false)
+	assert.Nil(t, err)
+	assert.Equal(t, a.entries, reopened.entries)
+	assert.Equal(t, []time.Time{calendarDay(day), calendarDay(day.Add(24 * time.Hour))}, reopened.days())
+	content, err = reopened.lookup("http://upstream/a", day)
+	assert.Nil(t, err)
+	assert.Equal(t, "first", string(content))
+}
+
+func TestArchiveRetention(t *testing.T) {
+	a, cleanup := newTestArchive(t, 24*time.Hour, false)
+	defer cleanup()
+
+	now := time.Now()
+	assert.Nil(t, a.store("http://upstream/a", nil, []byte("old"), now.Add(-48*time.Hour)))
+	assert.Nil(t, a.store("http://upstream/a", nil, []byte("new"), now))
+	oldPath := a.objectPath(a.entries[0].Hash)
+	assert.True(t, fileExists(oldPath))
+
+	assert.Nil(t, a.prune(now))
+	assert.Equal(t, 1, len(a.entries))
+	assert.False(t, fileExists(oldPath))
+
+	reopened, err := openArchive(a.dir, 0, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(reopened.entries))
+}
+
+func TestArchiveReadFromGet(t *testing.T) {
+	a, cleanup := newTestArchive(t, 0, true)
+	defer cleanup()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.js" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Last-Modified", "Fri, 20 Mar 2020 10:00:00 GMT")
+		w.Write([]byte(`var Erkrankungen = "1.016";`))
+	}))
+	defer ts.Close()
+
+	upstreamArchive = a
+	defer func() { upstreamArchive = nil }()
+
+	_, err := readJsVarFromGet(ts.URL+"/SimpleData.js", "Erkrankungen")
+	assert.Nil(t, err)
+	_, err = readJsVarFromGet(ts.URL+"/SimpleData.js", "Erkrankungen")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(a.entries))
+	assert.Equal(t, ts.URL+"/SimpleData.js", a.entries[0].URL)
+	assert.Equal(t, "Fri, 20 Mar 2020 10:00:00 GMT", a.entries[0].Headers.Get("Last-Modified"))
+
+	_, err = readFromGet(ts.URL + "/missing.js")
+	assert.EqualError(t, err, ts.URL+"/missing.js returned 404 Not Found")
+	assert.Equal(t, 1, len(a.entries))
+}
+
+func TestArchiveRebuildHistory(t *testing.T) {
+	a, cleanup := newTestArchive(t, 0, true)
+	defer cleanup()
+	dir, err := ioutil.TempDir("", "history")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	store, err := newHistoryStore(dir)
+	assert.Nil(t, err)
+
+	day := time.Date(2020, 3, 20, 10, 0, 0, 0, time.UTC)
+	assert.Nil(t, a.store("http://upstream/SimpleData.js", nil, []byte(`var Erkrankungen = "100";`), day))
+	assert.Nil(t, a.store("http://upstream/SimpleData.js", nil, []byte(`var Erkrankungen = "250";`), day.Add(30*time.Hour)))
+
+	upstreamArchive = a
+	defer func() { upstreamArchive = nil }()
+	source, err := newDeclarativeExporter(sourceConfig{Name: "test", URL: "http://upstream/SimpleData.js", Format: "jsvar", Variable: "Erkrankungen", Metrics: map[string]string{"cov19_confirmed": ""}})
+	assert.Nil(t, err)
+
+	days, err := a.rebuildHistory([]Exporter{source}, store)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, days)
+	assert.False(t, a.isReplaying())
+	assert.True(t, replay.get().IsZero())
+
+	points, err := store.query("cov19_confirmed", regionMatcher("", ""), time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, []historyPoint{
+		{"2020-03-20", "cov19_confirmed", map[string]string{}, 100},
+		{"2020-03-21", "cov19_confirmed", map[string]string{}, 250},
+	}, points)
+}
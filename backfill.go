@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//backfillSample is a metric of a day in a historical dataset
+type backfillSample struct {
+	metric
+	Time time.Time
+}
+
+//backfiller converts historical datasets into the metrics of the live exporters, one sample per series and day
+type backfiller struct {
+	agesURL string
+	jhuURL  string
+	ecdcURL string
+	from    time.Time
+	to      time.Time
+	samples []backfillSample
+}
+
+//backfillSources read the historical datasets, ages replaces the health ministry, jhu mathdro and ecdc the ECDC table
+var backfillSources = map[string]func(b *backfiller) error{
+	"ages": (*backfiller).ages,
+	"jhu":  (*backfiller).jhu,
+	"ecdc": (*backfiller).ecdc,
+}
+
+//add keeps the metrics of days between from and to, a zero bound is open
+func (b *backfiller) add(date time.Time, m metrics) {
+	if (!b.from.IsZero() && date.Before(b.from)) || (!b.to.IsZero() && date.After(b.to)) {
+		return
+	}
+	for _, x := range m {
+		b.samples = append(b.samples, backfillSample{x, date})
+	}
+}
+
+//ages reads the timelines of the provinces and districts of the AGES dashboard
+func (b *backfiller) ages() error {
+	records, err := readCsvFromGet(b.agesURL+"/CovidFaelle_Timeline.csv", ';')
+	if err != nil {
+		return err
+	}
+	c, err := csvColumns(records[0], "Time", "Bundesland", "BundeslandID", "AnzahlFaelleSum", "AnzahlTotSum", "AnzahlGeheiltSum")
+	if err != nil {
+		return err
+	}
+	provinces, healedDeaths := make(map[time.Time]ministryStat), make(map[time.Time]ministryStat)
+	for _, row := range records[1:] {
+		date, err := time.ParseInLocation("02.01.2006 15:04:05", row[c["Time"]], viennaLocation())
+		if err != nil {
+			return err
+		}
+		infected, dead, healed := atoi(row[c["AnzahlFaelleSum"]]), atoi(row[c["AnzahlTotSum"]]), atoi(row[c["AnzahlGeheiltSum"]])
+		if row[c["BundeslandID"]] == "10" {
+			b.add(date, metrics{
+				{"cov19_confirmed", nil, float64(infected)},
+				{"cov19_healed", nil, float64(healed)},
+				{"cov19_dead", nil, float64(dead)},
+			})
+			continue
+		}
+		provinces[date] = append(provinces[date], ministryEntry{row[c["Bundesland"]], infected, 0})
+		healedDeaths[date] = append(healedDeaths[date], ministryEntry{row[c["Bundesland"]], healed, dead})
+	}
+	for date, stats := range provinces {
+		b.add(date, he.bundeslandMetrics(stats))
+		b.add(date, he.healedDeathMetrics(healedDeaths[date]))
+	}
+
+	records, err = readCsvFromGet(b.agesURL+"/CovidFaelle_Timeline_GKZ.csv", ';')
+	if err != nil {
+		return err
+	}
+	c, err = csvColumns(records[0], "Time", "Bezirk", "AnzahlFaelleSum")
+	if err != nil {
+		return err
+	}
+	districts := make(map[time.Time]ministryStat)
+	for _, row := range records[1:] {
+		date, err := time.ParseInLocation("02.01.2006 15:04:05", row[c["Time"]], viennaLocation())
+		if err != nil {
+			return err
+		}
+		districts[date] = append(districts[date], ministryEntry{row[c["Bezirk"]], atoi(row[c["AnzahlFaelleSum"]]), 0})
+	}
+	for date, stats := range districts {
+		b.add(date, he.bezirkMetrics(stats))
+	}
+	return nil
+}
+
+//jhu reads the global time series of the recovered of the Johns Hopkins University, the source of mathdro
+func (b *backfiller) jhu() error {
+	records, err := readCsvFromGet(b.jhuURL, ',')
+	if err != nil {
+		return err
+	}
+	c, err := csvColumns(records[0], "Province/State", "Country/Region", "Lat", "Long")
+	if err != nil {
+		return err
+	}
+	for i, column := range records[0] {
+		date, err := time.ParseInLocation("1/2/06", column, viennaLocation())
+		if err != nil {
+			continue
+		}
+		recovered := make(recoveredStats, 0, len(records)-1)
+		for _, row := range records[1:] {
+			var province *string
+			if p := row[c["Province/State"]]; p != "" {
+				province = &p
+			}
+			recovered = append(recovered, recoveredStat{province, row[c["Country/Region"]], atoi(row[i]), atof(row[c["Lat"]]), atof(row[c["Long"]])})
+		}
+		b.add(date, recoveredMetrics(recovered))
+	}
+	return nil
+}
+
+//ecdc reads the daily cases and deaths of the ECDC case distribution dataset and accumulates them like the ECDC table
+func (b *backfiller) ecdc() error {
+	records, err := readCsvFromGet(b.ecdcURL, ',')
+	if err != nil {
+		return err
+	}
+	c, err := csvColumns(records[0], "dateRep", "cases", "deaths", "countriesAndTerritories", "continentExp")
+	if err != nil {
+		return err
+	}
+	type ecdcDay struct {
+		date          time.Time
+		cases, deaths int64
+		continent     string
+	}
+	countries := make(map[string][]ecdcDay)
+	for _, row := range records[1:] {
+		date, err := time.ParseInLocation("02/01/2006", row[c["dateRep"]], viennaLocation())
+		if err != nil {
+			return err
+		}
+		//corrections are reported as negative daily numbers
+		cases, _ := strconv.ParseInt(row[c["cases"]], 10, 64)
+		deaths, _ := strconv.ParseInt(row[c["deaths"]], 10, 64)
+		country := normalizeCountryName(row[c["countriesAndTerritories"]])
+		countries[country] = append(countries[country], ecdcDay{date, cases, deaths, row[c["continentExp"]]})
+	}
+
+	days := make(map[time.Time][]ecdcStat)
+	for country, d := range countries {
+		sort.Slice(d, func(i, j int) bool { return d[i].date.Before(d[j].date) })
+		var infected, deaths int64
+		for _, day := range d {
+			infected += day.cases
+			deaths += day.deaths
+			if infected > 0 || deaths > 0 {
+				days[day.date] = append(days[day.date], ecdcStat{CovidStat{country, uint64(max64(infected, 0)), uint64(max64(deaths, 0))}, day.continent})
+			}
+		}
+	}
+	e := newEcdcExporter(mp)
+	for date, stats := range days {
+		b.add(date, e.statMetrics(stats))
+	}
+	return nil
+}
+
+func max64(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+var openMetricsEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+//writeOpenMetrics writes the samples grouped by metric family as gauges with timestamps in seconds.
+//Repeated samples of a series and day are dropped, promtool rejects them.
+func writeOpenMetrics(w io.Writer, samples []backfillSample) error {
+	keys := make([]string, len(samples))
+	for i, s := range samples {
+		keys[i] = seriesKey(s.metric)
+	}
+	order := make([]int, len(samples))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := samples[order[i]], samples[order[j]]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if keys[order[i]] != keys[order[j]] {
+			return keys[order[i]] < keys[order[j]]
+		}
+		return a.Time.Before(b.Time)
+	})
+
+	writer := bufio.NewWriter(w)
+	family := ""
+	for n, i := range order {
+		s := samples[i]
+		if n+1 < len(order) && keys[order[n+1]] == keys[i] && samples[order[n+1]].Time.Equal(s.Time) {
+			continue
+		}
+		if s.Name != family {
+			family = s.Name
+			fmt.Fprintf(writer, "# TYPE %s gauge\n", family)
+		}
+		labels := make([]string, 0)
+		for _, k := range sortedTags(s.metric) {
+			labels = append(labels, k+`="`+openMetricsEscaper.Replace((*s.Tags)[k])+`"`)
+		}
+		name := s.Name
+		if len(labels) > 0 {
+			name += "{" + strings.Join(labels, ",") + "}"
+		}
+		fmt.Fprintf(writer, "%s %s %d\n", name, strconv.FormatFloat(s.Value, 'f', -1, 64), s.Time.Unix())
+	}
+	fmt.Fprint(writer, "# EOF\n")
+	return writer.Flush()
+}
+
+//runBackfill is the backfill subcommand, it writes the historical datasets as OpenMetrics file for
+//promtool tsdb create-blocks-from openmetrics
+func runBackfill(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	sources := flags.String("sources", "ages,jhu,ecdc", "Comma separated datasets to import: ages, jhu and ecdc")
+	from := flags.String("from", "", "First day to import (YYYY-MM-DD)")
+	to := flags.String("to", "", "Last day to import (YYYY-MM-DD)")
+	output := flags.String("output", "backfill.om", "OpenMetrics file that is written, - for stdout")
+	agesURL := flags.String("ages-url", "https://covid19-dashboard.ages.at/data", "Base url of the AGES timelines")
+	jhuURL := flags.String("jhu-url", "https://raw.githubusercontent.com/CSSEGISandData/COVID-19/master/csse_covid_19_data/csse_covid_19_time_series/time_series_covid19_recovered_global.csv", "Url of the JHU time series of the recovered")
+	ecdcURL := flags.String("ecdc-url", "https://opendata.ecdc.europa.eu/covid19/casedistribution/csv", "Url of the ECDC case distribution dataset")
+	flags.Parse(args)
+
+	b := &backfiller{agesURL: *agesURL, jhuURL: *jhuURL, ecdcURL: *ecdcURL}
+	var err error
+	if *from != "" {
+		if b.from, err = time.ParseInLocation(snapshotLayout, *from, viennaLocation()); err != nil {
+			return err
+		}
+	}
+	if *to != "" {
+		if b.to, err = time.ParseInLocation(snapshotLayout, *to, viennaLocation()); err != nil {
+			return err
+		}
+	}
+	names := splitList([]string{*sources})
+	if len(names) == 0 {
+		return errors.New("No sources to backfill")
+	}
+	for _, name := range names {
+		if _, ok := backfillSources[name]; !ok {
+			return fmt.Errorf("Unknown backfill source %s", name)
+		}
+	}
+	for _, name := range names {
+		before := len(b.samples)
+		if err := backfillSources[name](b); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		//the log goes to stderr, the output may be stdout
+		fmt.Fprintf(os.Stderr, "Read %d samples from %s\n", len(b.samples)-before, name)
+	}
+
+	if *output == "-" {
+		return writeOpenMetrics(stdout, b.samples)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return writeOpenMetrics(file, b.samples)
+}
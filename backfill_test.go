@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBackfiller(t *testing.T) (*backfiller, func()) {
+	dir, err := ioutil.TempDir("", "backfill")
+	assert.Nil(t, err)
+	for name, content := range map[string]string{
+		"CovidFaelle_Timeline.csv": "\ufeffTime;Bundesland;BundeslandID;AnzEinwohner;AnzahlFaelle;AnzahlFaelleSum;AnzahlTotTaeglich;AnzahlTotSum;AnzahlGeheiltTaeglich;AnzahlGeheiltSum\n" +
+			"26.02.2020 00:00:00;Wien;9;1911191;1;1;0;0;0;0\n" +
+			"26.02.2020 00:00:00;Österreich;10;8901064;2;2;0;0;0;0\n" +
+			"27.02.2020 00:00:00;Wien;9;1911191;2;3;1;1;1;1\n" +
+			"27.02.2020 00:00:00;Österreich;10;8901064;3;5;1;1;1;1\n",
+		"CovidFaelle_Timeline_GKZ.csv": "Time;Bezirk;GKZ;AnzEinwohner;AnzahlFaelle;AnzahlFaelleSum\n" +
+			"26.02.2020 00:00:00;Graz(Stadt);601;291134;1;1\n" +
+			"27.02.2020 00:00:00;Graz(Stadt);601;291134;1;2\n",
+		"recovered.csv": "Province/State,Country/Region,Lat,Long,2/26/20,2/27/20\n" +
+			",Austria,47.5162,14.5501,0,1\n" +
+			"\"Hubei\",China,30.9756,112.2707,2959,3218\n",
+		"ecdc.csv": "dateRep,day,month,year,cases,deaths,countriesAndTerritories,geoId,countryterritoryCode,popData2019,continentExp\n" +
+			"27/02/2020,27,2,2020,3,0,Austria,AT,AUT,8858775,Europe\n" +
+			"26/02/2020,26,2,2020,2,0,Austria,AT,AUT,8858775,Europe\n" +
+			"26/02/2020,26,2,2020,0,0,United_States_of_America,US,USA,329064917,America\n",
+	} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	url := "file://" + filepath.ToSlash(dir)
+	b := &backfiller{agesURL: url, jhuURL: url + "/recovered.csv", ecdcURL: url + "/ecdc.csv"}
+	return b, func() { os.RemoveAll(dir) }
+}
+
+func TestBackfill(t *testing.T) {
+	b, cleanup := newTestBackfiller(t)
+	defer cleanup()
+	for _, source := range []string{"ages", "jhu", "ecdc"} {
+		assert.Nil(t, backfillSources[source](b), source)
+	}
+	output := bytes.Buffer{}
+	assert.Nil(t, writeOpenMetrics(&output, b.samples))
+	lines := output.String()
+
+	assert.Contains(t, lines, "# TYPE cov19_confirmed gauge\ncov19_confirmed 2 1582671600\ncov19_confirmed 5 1582758000\n")
+	assert.Contains(t, lines, `cov19_bezirk_infected{bezirk="Graz(Stadt)",country="Austria",latitude="47.070714",longitude="15.439504"} 2 1582758000`)
+	assert.Contains(t, lines, `cov19_detail_dead{country="Austria",latitude="48.206351",longitude="16.374817",province="Wien"} 1 1582758000`)
+	assert.Contains(t, lines, `cov19_world_recovered{country="China",latitude="30.975600",longitude="112.270700",province="Hubei"} 3218 1582758000`)
+	assert.Contains(t, lines, `cov19_world_recovered{country="Austria",latitude="47.516200",longitude="14.550100"} 0 1582671600`)
+	assert.Contains(t, lines, "cov19_world_infected{continent=\"Europe\",country=\"Austria\",latitude=\"47.516231\",longitude=\"14.550072\"} 2 1582671600\n")
+	assert.Contains(t, lines, "cov19_world_infected{continent=\"Europe\",country=\"Austria\",latitude=\"47.516231\",longitude=\"14.550072\"} 5 1582758000\n")
+	assert.NotContains(t, lines, "United States")
+	assert.True(t, strings.HasSuffix(lines, "\n# EOF\n"))
+
+	//every family is written once
+	families := make(map[string]bool)
+	for _, line := range strings.Split(lines, "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			assert.False(t, families[line], line)
+			families[line] = true
+		}
+	}
+}
+
+func TestBackfillRange(t *testing.T) {
+	b, cleanup := newTestBackfiller(t)
+	defer cleanup()
+	b.from = time.Date(2020, 2, 27, 0, 0, 0, 0, viennaLocation())
+	assert.Nil(t, b.ages())
+	for _, s := range b.samples {
+		assert.Equal(t, b.from, s.Time)
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	date := time.Date(2020, 2, 26, 0, 0, 0, 0, time.UTC)
+	output := bytes.Buffer{}
+	assert.Nil(t, writeOpenMetrics(&output, []backfillSample{
+		{metric{"cov19_detail", &map[string]string{"province": `Nieder "österreich"`}, 1}, date},
+		{metric{"cov19_confirmed", nil, 1}, date},
+		{metric{"cov19_detail", &map[string]string{"province": `Nieder "österreich"`}, 2}, date},
+	}))
+	assert.Equal(t, `# TYPE cov19_confirmed gauge
+cov19_confirmed 1 1582675200
+# TYPE cov19_detail gauge
+cov19_detail{province="Nieder \"österreich\""} 2 1582675200
+# EOF
+`, output.String())
+
+	assert.NotNil(t, runBackfill([]string{"-sources", "wikipedia"}, ioutil.Discard))
+}
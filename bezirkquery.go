@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//bezirkRow is a district with the identifiers and values that can be filtered on but are not part of bezirkStat
+type bezirkRow struct {
+	bezirkStat
+	gkz          string
+	province     string
+	incidence    float64
+	hasIncidence bool
+}
+
+//bezirkQuery filters, sorts, paginates and projects the districts
+type bezirkQuery struct {
+	provinces       []string
+	prefix          string
+	gkz             []string
+	minIncidence    float64
+	filterIncidence bool
+	sort            string
+	descending      bool
+	limit           int
+	offset          int
+	fields          []string
+}
+
+//getBezirkRows adds the GKZ and, if a history is given, the 7-day incidence to the district stats
+func (a *api) getBezirkRows(history *historyStore, date time.Time) ([]bezirkRow, error) {
+	stats, err := a.GetBezirkStat()
+	if err != nil {
+		return nil, err
+	}
+	day := calendarDay(date).AddDate(0, 0, -7)
+	weekAgo, err := history.byRegion("cov19_bezirk_infected", "bezirk", day, day)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]bezirkRow, 0, len(stats))
+	for _, s := range stats {
+		row := bezirkRow{bezirkStat: s, gkz: a.he.mp.getId(s.Name), province: a.he.mp.getParent(s.Name)}
+		if p, ok := weekAgo[normalizeName(s.Name)]; ok && s.Population > 0 {
+			row.incidence = (float64(s.Infected) - p[0].Value) / float64(s.Population) * 100000
+			row.hasIncidence = true
+		}
+		result = append(result, row)
+	}
+	return result, nil
+}
+
+//splitList splits repeated and comma separated query parameters
+func splitList(values []string) []string {
+	result := make([]string, 0)
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+//jsonFieldName returns the json name of a struct field, "" if it is not serialized
+func jsonFieldName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+	tag := strings.Split(field.Tag.Get("json"), ",")[0]
+	if tag == "-" {
+		return ""
+	}
+	if tag != "" {
+		return tag
+	}
+	return field.Name
+}
+
+func findField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if json := jsonFieldName(field); json != "" && (strings.EqualFold(json, name) || strings.EqualFold(field.Name, name)) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func isNumeric(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+//parseBezirkQuery reads the query parameters and checks sort and fields against the result type
+func parseBezirkQuery(r *http.Request, result reflect.Type) (bezirkQuery, error) {
+	values := r.URL.Query()
+	q := bezirkQuery{
+		provinces: splitList(values["bundesland"]),
+		prefix:    values.Get("name"),
+		gkz:       splitList(values["gkz"]),
+		sort:      values.Get("sort"),
+		fields:    splitList(values["fields"]),
+	}
+	if v := values.Get("min_incidence"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return q, errors.New("min_incidence must be a number")
+		}
+		q.minIncidence, q.filterIncidence = f, true
+	}
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{{"limit", &q.limit}, {"offset", &q.offset}} {
+		if values.Get(p.name) == "" {
+			continue
+		}
+		v, err := strconv.Atoi(values.Get(p.name))
+		if err != nil || v < 0 {
+			return q, errors.New(p.name + " must be a positive number")
+		}
+		*p.value = v
+	}
+	if strings.HasPrefix(q.sort, "-") {
+		q.sort, q.descending = q.sort[1:], true
+	}
+	if q.sort != "" && !strings.EqualFold(q.sort, "incidence") {
+		field, ok := findField(result, q.sort)
+		if !ok {
+			return q, errors.New("Unknown field " + q.sort)
+		}
+		if !isNumeric(field.Type) {
+			return q, errors.New("Cannot sort by " + q.sort)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, f := range q.fields {
+		field, ok := findField(result, f)
+		if !ok {
+			return q, errors.New("Unknown field " + f)
+		}
+		if seen[field.Name] {
+			return q, errors.New("Duplicate field " + f)
+		}
+		seen[field.Name] = true
+	}
+	return q, nil
+}
+
+//needsIncidence is true if the query filters or sorts by the 7-day incidence
+func (q bezirkQuery) needsIncidence() bool {
+	return q.filterIncidence || strings.EqualFold(q.sort, "incidence")
+}
+
+func (q bezirkQuery) matches(row bezirkRow, mp *metadataProvider) bool {
+	if len(q.provinces) > 0 {
+		gkz := mp.getId(row.province)
+		found := false
+		for _, p := range q.provinces {
+			if matchesProvince(p, row.province, gkz) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.prefix != "" && !strings.HasPrefix(strings.ToLower(row.Name), strings.ToLower(q.prefix)) {
+		return false
+	}
+	if len(q.gkz) > 0 && !contains(q.gkz, row.gkz) {
+		return false
+	}
+	if q.filterIncidence && (!row.hasIncidence || row.incidence < q.minIncidence) {
+		return false
+	}
+	return true
+}
+
+//numericValue returns the value of a numeric field, false if it is a nil pointer
+func numericValue(v reflect.Value) (float64, bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	}
+	return v.Float(), true
+}
+
+//apply filters the rows, converts them, sorts, paginates and selects the fields.
+//It returns the result and the number of matching rows before pagination.
+func (q bezirkQuery) apply(rows []bezirkRow, mp *metadataProvider, result reflect.Type, convert func(bezirkRow) interface{}) (interface{}, int) {
+	type entry struct {
+		row  bezirkRow
+		item reflect.Value
+	}
+	entries := make([]entry, 0, len(rows))
+	for _, row := range rows {
+		if q.matches(row, mp) {
+			entries = append(entries, entry{row, reflect.ValueOf(convert(row))})
+		}
+	}
+	total := len(entries)
+
+	if q.sort != "" {
+		key := func(e entry) (float64, bool) { return e.row.incidence, e.row.hasIncidence }
+		if !strings.EqualFold(q.sort, "incidence") {
+			field, _ := findField(result, q.sort)
+			key = func(e entry) (float64, bool) { return numericValue(e.item.FieldByIndex(field.Index)) }
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, okA := key(entries[i])
+			b, okB := key(entries[j])
+			if !okA || !okB {
+				return okA && !okB
+			}
+			if q.descending {
+				return a > b
+			}
+			return a < b
+		})
+	}
+
+	if q.offset >= len(entries) {
+		entries = entries[:0]
+	} else {
+		entries = entries[q.offset:]
+	}
+	if q.limit > 0 && q.limit < len(entries) {
+		entries = entries[:q.limit]
+	}
+
+	itemType := result
+	var selected []reflect.StructField
+	if len(q.fields) > 0 {
+		projection := make([]reflect.StructField, 0, len(q.fields))
+		for _, f := range q.fields {
+			field, _ := findField(result, f)
+			selected = append(selected, field)
+			projection = append(projection, reflect.StructField{Name: field.Name, Type: field.Type, Tag: field.Tag})
+		}
+		itemType = reflect.StructOf(projection)
+	}
+	items := reflect.MakeSlice(reflect.SliceOf(itemType), 0, len(entries))
+	for _, e := range entries {
+		if selected == nil {
+			items = reflect.Append(items, e.item)
+			continue
+		}
+		item := reflect.New(itemType).Elem()
+		for i, field := range selected {
+			item.Field(i).Set(e.item.FieldByIndex(field.Index))
+		}
+		items = reflect.Append(items, item)
+	}
+	return items.Interface(), total
+}
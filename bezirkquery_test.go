@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testBezirkRows() []bezirkRow {
+	return []bezirkRow{
+		{bezirkStat{"Graz(Stadt)", apiLocaiton{47.07, 15.43}, 288806, 340}, "601", "Steiermark", 100, true},
+		{bezirkStat{"Graz-Umgebung", apiLocaiton{47.1, 15.3}, 156000, 20}, "606", "Steiermark", 0, false},
+		{bezirkStat{"Wien(Stadt)", apiLocaiton{48.18, 16.3}, 1897491, 1200}, "900", "Wien", 50, true},
+	}
+}
+
+func applyBezirkQuery(t *testing.T, query string) ([]bezirkStat, int) {
+	q, err := parseBezirkQuery(httptest.NewRequest("GET", "/api/bezirk?"+query, nil), reflect.TypeOf(bezirkStat{}))
+	assert.Nil(t, err, query)
+	items, total := q.apply(testBezirkRows(), e.mp, reflect.TypeOf(bezirkStat{}), func(row bezirkRow) interface{} { return row.bezirkStat })
+	return items.([]bezirkStat), total
+}
+
+func bezirkNames(stats []bezirkStat) []string {
+	names := make([]string, 0, len(stats))
+	for _, s := range stats {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestBezirkQueryFilter(t *testing.T) {
+	for query, expected := range map[string][]string{
+		"":                                {"Graz(Stadt)", "Graz-Umgebung", "Wien(Stadt)"},
+		"bundesland=Steiermark":           {"Graz(Stadt)", "Graz-Umgebung"},
+		"bundesland=steiermark,W":         {"Graz(Stadt)", "Graz-Umgebung"},
+		"bundesland=9":                    {"Wien(Stadt)"},
+		"bundesland=AT-9":                 {"Wien(Stadt)"},
+		"bundesland=at-6&bundesland=at-9": {"Graz(Stadt)", "Graz-Umgebung", "Wien(Stadt)"},
+		"name=graz":                       {"Graz(Stadt)", "Graz-Umgebung"},
+		"name=Graz-":                      {"Graz-Umgebung"},
+		"gkz=601,900":                     {"Graz(Stadt)", "Wien(Stadt)"},
+		"min_incidence=60":                {"Graz(Stadt)"},
+		"min_incidence=0":                 {"Graz(Stadt)", "Wien(Stadt)"},
+	} {
+		stats, total := applyBezirkQuery(t, query)
+		assert.Equal(t, expected, bezirkNames(stats), query)
+		assert.Equal(t, len(expected), total, query)
+	}
+}
+
+func TestBezirkQuerySortAndPagination(t *testing.T) {
+	stats, _ := applyBezirkQuery(t, "sort=-infected")
+	assert.Equal(t, []string{"Wien(Stadt)", "Graz(Stadt)", "Graz-Umgebung"}, bezirkNames(stats))
+	stats, _ = applyBezirkQuery(t, "sort=Population")
+	assert.Equal(t, []string{"Graz-Umgebung", "Graz(Stadt)", "Wien(Stadt)"}, bezirkNames(stats))
+	stats, _ = applyBezirkQuery(t, "sort=incidence")
+	assert.Equal(t, []string{"Wien(Stadt)", "Graz(Stadt)", "Graz-Umgebung"}, bezirkNames(stats))
+	stats, _ = applyBezirkQuery(t, "sort=-incidence")
+	assert.Equal(t, []string{"Graz(Stadt)", "Wien(Stadt)", "Graz-Umgebung"}, bezirkNames(stats))
+
+	stats, total := applyBezirkQuery(t, "sort=-infected&limit=2")
+	assert.Equal(t, []string{"Wien(Stadt)", "Graz(Stadt)"}, bezirkNames(stats))
+	assert.Equal(t, 3, total)
+	stats, total = applyBezirkQuery(t, "sort=-infected&limit=2&offset=2")
+	assert.Equal(t, []string{"Graz-Umgebung"}, bezirkNames(stats))
+	assert.Equal(t, 3, total)
+	stats, _ = applyBezirkQuery(t, "offset=5")
+	assert.Empty(t, stats)
+}
+
+func TestBezirkQueryFields(t *testing.T) {
+	q, err := parseBezirkQuery(httptest.NewRequest("GET", "/api/v2/bezirk?fields=id,infected&sort=-infected&limit=1", nil), reflect.TypeOf(apiV2Bezirk{}))
+	assert.Nil(t, err)
+	items, total := q.apply(testBezirkRows(), e.mp, reflect.TypeOf(apiV2Bezirk{}), func(row bezirkRow) interface{} { return a.newApiV2Bezirk(row) })
+	assert.Equal(t, 3, total)
+	content, err := json.Marshal(items)
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"id":"900","infected":1200}]`, string(content))
+}
+
+func TestBezirkQueryErrors(t *testing.T) {
+	for query, expected := range map[string]string{
+		"min_incidence=high": "min_incidence must be a number",
+		"limit=-1":           "limit must be a positive number",
+		"offset=x":           "offset must be a positive number",
+		"sort=altitude":      "Unknown field altitude",
+		"sort=Name":          "Cannot sort by Name",
+		"fields=Name,Color":  "Unknown field Color",
+		"fields=Name,name":   "Duplicate field name",
+	} {
+		_, err := parseBezirkQuery(httptest.NewRequest("GET", "/api/bezirk?"+query, nil), reflect.TypeOf(bezirkStat{}))
+		if assert.NotNil(t, err, query) {
+			assert.Equal(t, expected, err.Error(), query)
+		}
+	}
+}
+
+func TestHandleBezirkQuery(t *testing.T) {
+	testApi, cleanup := newTestDashboardApi(t)
+	defer cleanup()
+	defer func(previousApi *api, previousHistory *historyStore) { a, history = previousApi, previousHistory }(a, history)
+	a, history = testApi, nil
+
+	recorder := httptest.NewRecorder()
+	handleApiBezirk(recorder, httptest.NewRequest("GET", "/api/bezirk?min_incidence=10", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handleApiBezirk(recorder, httptest.NewRequest("GET", "/api/bezirk?bundesland=AT-9&fields=Name,Infected&format=csv", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "1", recorder.Header().Get("X-Total-Count"))
+	assert.Equal(t, "name,infected\r\nWien(Stadt),1200\r\n", recorder.Body.String())
+
+	store, cleanupHistory := newTestHistory(t)
+	defer cleanupHistory()
+	assert.Nil(t, store.record(now().Truncate(24*time.Hour).AddDate(0, 0, -7), metrics{
+		{"cov19_bezirk_infected", &map[string]string{"bezirk": "Graz(Stadt)", "country": "Austria"}, 51},
+	}))
+	history = store
+	recorder = httptest.NewRecorder()
+	handleApiV2Bezirk(recorder, httptest.NewRequest("GET", "/api/v2/bezirk?min_incidence=50&fields=name,incidence_7d_per_100k", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	response := struct {
+		Data []map[string]interface{} `json:"data"`
+	}{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, 1, len(response.Data))
+	assert.Equal(t, "Graz(Stadt)", response.Data[0]["name"])
+	assert.InDelta(t, 289.0/288806*100000, response.Data[0]["incidence_7d_per_100k"], 0.0001)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//changeHistorySize is the number of events kept for clients that reconnect with Last-Event-ID
+const changeHistorySize = 100
+
+//metricChange is a value that differs from the previous refresh, Old is nil for new series. Reported is the time
+//of the upstream report of the value if its exporter publishes one.
+type metricChange struct {
+	Metric   string            `json:"metric"`
+	Labels   map[string]string `json:"labels"`
+	Old      *float64          `json:"old"`
+	New      float64           `json:"new"`
+	Reported *time.Time        `json:"reported,omitempty"`
+}
+
+//changeEvent contains all changes of a refresh, time is the time of the refresh (the replay date when replaying)
+type changeEvent struct {
+	ID      uint64         `json:"id"`
+	Time    time.Time      `json:"time"`
+	Changes []metricChange `json:"changes"`
+}
+
+//changeBroker distributes change events to subscribers and keeps the last events
+type changeBroker struct {
+	mu          sync.Mutex
+	lastID      uint64
+	events      []changeEvent
+	subscribers map[chan changeEvent]bool
+}
+
+//seriesKey identifies a series by its name and sorted tags
+func seriesKey(m metric) string {
+	if m.Tags == nil || len(*m.Tags) == 0 {
+		return m.Name
+	}
+	tags := make([]string, 0, len(*m.Tags))
+	for k, v := range *m.Tags {
+		tags = append(tags, k+"="+v)
+	}
+	sort.Strings(tags)
+	return m.Name + "{" + strings.Join(tags, ",") + "}"
+}
+
+//diffMetrics returns the series of current that are new or have another value than in previous.
+//Series missing in current are ignored, they are usually caused by an unavailable source.
+func diffMetrics(previous metrics, current metrics) []metricChange {
+	old := make(map[string]float64, len(previous))
+	for _, m := range previous {
+		old[seriesKey(m)] = m.Value
+	}
+	result := make([]metricChange, 0)
+	for _, m := range current {
+		labels := map[string]string{}
+		if m.Tags != nil {
+			labels = *m.Tags
+		}
+		value, ok := old[seriesKey(m)]
+		if !ok {
+			result = append(result, metricChange{Metric: m.Name, Labels: labels, New: m.Value})
+		} else if value != m.Value {
+			v := value
+			result = append(result, metricChange{Metric: m.Name, Labels: labels, Old: &v, New: m.Value})
+		}
+	}
+	return result
+}
+
+//addReportTimes sets the report time of the changes of exporters that publish one, like the ministry
+func addReportTimes(changes []metricChange, results []exporterResult) {
+	reported := make(map[string]time.Time)
+	for _, r := range results {
+		timer, ok := r.exporter.(reportTimer)
+		if !ok || r.err != nil {
+			continue
+		}
+		date, err := timer.getReportTime()
+		if err != nil {
+			logger.Print(err)
+			continue
+		}
+		for _, m := range r.metrics {
+			reported[seriesKey(m)] = date
+		}
+	}
+	for i, c := range changes {
+		labels := c.Labels
+		if date, ok := reported[seriesKey(metric{c.Metric, &labels, c.New})]; ok {
+			changes[i].Reported = &date
+		}
+	}
+}
+
+func newChangeBroker() *changeBroker {
+	return &changeBroker{subscribers: make(map[chan changeEvent]bool)}
+}
+
+//publish sends the changes to all subscribers, subscribers that are not ready miss the event
+func (b *changeBroker) publish(date time.Time, changes []metricChange) changeEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastID++
+	event := changeEvent{ID: b.lastID, Time: date, Changes: changes}
+	b.events = append(b.events, event)
+	if len(b.events) > changeHistorySize {
+		b.events = b.events[len(b.events)-changeHistorySize:]
+	}
+	for c := range b.subscribers {
+		select {
+		case c <- event:
+		default:
+			logger.Print("Dropped change event for slow subscriber")
+		}
+	}
+	return event
+}
+
+//subscribe returns a channel of new events and the kept events after lastID
+func (b *changeBroker) subscribe(lastID uint64) (chan changeEvent, []changeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := make(chan changeEvent, 16)
+	b.subscribers[c] = true
+	missed := make([]changeEvent, 0)
+	for _, e := range b.events {
+		if e.ID > lastID {
+			missed = append(missed, e)
+		}
+	}
+	return c, missed
+}
+
+func (b *changeBroker) unsubscribe(c chan changeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, c)
+}
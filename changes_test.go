@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffMetrics(t *testing.T) {
+	previous := metrics{
+		{"cov19_confirmed", nil, 100},
+		{"cov19_detail", &map[string]string{"province": "Wien", "country": "Austria"}, 40},
+		{"cov19_detail", &map[string]string{"province": "Tirol", "country": "Austria"}, 20},
+		{"cov19_dead", nil, 3},
+	}
+	current := metrics{
+		{"cov19_confirmed", nil, 110},
+		{"cov19_detail", &map[string]string{"country": "Austria", "province": "Wien"}, 40},
+		{"cov19_detail", &map[string]string{"province": "Tirol", "country": "Austria"}, 25},
+		{"cov19_healed", nil, 50},
+	}
+	old100, old20 := 100.0, 20.0
+	assert.Equal(t, []metricChange{
+		{"cov19_confirmed", map[string]string{}, &old100, 110, nil},
+		{"cov19_detail", map[string]string{"province": "Tirol", "country": "Austria"}, &old20, 25, nil},
+		{"cov19_healed", map[string]string{}, nil, 50, nil},
+	}, diffMetrics(previous, current))
+	assert.Empty(t, diffMetrics(current, current))
+	assert.Equal(t, "cov19_detail{country=Austria,province=Wien}", seriesKey(current[1]))
+}
+
+func TestChangeBroker(t *testing.T) {
+	broker := newChangeBroker()
+	date := time.Date(2020, 3, 20, 10, 0, 0, 0, time.UTC)
+	broker.publish(date, []metricChange{{Metric: "cov19_confirmed", New: 1}})
+
+	events, missed := broker.subscribe(0)
+	assert.Equal(t, 1, len(missed))
+	assert.Equal(t, uint64(1), missed[0].ID)
+	broker.publish(date, []metricChange{{Metric: "cov19_confirmed", New: 2}})
+	e := <-events
+	assert.Equal(t, uint64(2), e.ID)
+	assert.Equal(t, date, e.Time)
+	assert.Equal(t, 2.0, e.Changes[0].New)
+
+	_, missed = broker.subscribe(2)
+	assert.Empty(t, missed)
+	broker.unsubscribe(events)
+	broker.publish(date, nil)
+	assert.Empty(t, events)
+
+	for i := 0; i < changeHistorySize+10; i++ {
+		broker.publish(date, nil)
+	}
+	_, missed = broker.subscribe(0)
+	assert.Equal(t, changeHistorySize, len(missed))
+}
+
+func TestSchedulerPublishesChanges(t *testing.T) {
+	exporter := &staticExporter{metrics{{"cov19_confirmed", nil, 100}}}
+	s := newScheduler([]Exporter{exporter}, nil)
+	events, _ := s.changes.subscribe(0)
+	s.refresh()
+	s.refresh()
+	assert.Empty(t, events)
+
+	exporter.metrics = metrics{{"cov19_confirmed", nil, 120}}
+	s.refresh()
+	e := <-events
+	assert.Equal(t, "cov19_confirmed", e.Changes[0].Metric)
+	assert.Equal(t, 100.0, *e.Changes[0].Old)
+	assert.Equal(t, 120.0, e.Changes[0].New)
+	assert.Nil(t, e.Changes[0].Reported)
+}
+
+func TestChangesReportTime(t *testing.T) {
+	reported := time.Date(2020, 11, 9, 10, 0, 0, 0, viennaLocation())
+	ministry := &reportedExporter{staticExporter{metrics{{"cov19_confirmed", nil, 100}, {"cov19_detail", &map[string]string{"province": "Wien"}, 40}}}, reported, nil}
+	other := &staticExporter{metrics{{"cov19_tests", nil, 5}}}
+	s := newScheduler([]Exporter{ministry, other}, nil)
+	events, _ := s.changes.subscribe(0)
+	s.refresh()
+
+	ministry.metrics = metrics{{"cov19_confirmed", nil, 120}, {"cov19_detail", &map[string]string{"province": "Wien"}, 50}}
+	other.metrics = metrics{{"cov19_tests", nil, 6}}
+	s.refresh()
+	e := <-events
+	assert.Equal(t, 3, len(e.Changes))
+	assert.Equal(t, reported, *e.Changes[0].Reported)
+	assert.Equal(t, reported, *e.Changes[1].Reported)
+	assert.Nil(t, e.Changes[2].Reported)
+	content, err := json.Marshal(e.Changes[0])
+	assert.Nil(t, err)
+	assert.Equal(t, `{"metric":"cov19_confirmed","labels":{},"old":100,"new":120,"reported":"2020-11-09T10:00:00+01:00"}`, string(content))
+
+	ministry.err = errors.New("LetzteAktualisierung not found")
+	ministry.metrics = metrics{{"cov19_confirmed", nil, 130}}
+	s.refresh()
+	e = <-events
+	assert.Nil(t, e.Changes[0].Reported)
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+//command is a subcommand of the binary, output is written to stdout and errors exit with status 1
+type command struct {
+	description string
+	run         func(args []string, stdout io.Writer) error
+}
+
+//commands are the subcommands, serve is the default if the first argument is a flag or missing
+var commands = map[string]command{
+	"serve":    {"Run the exporter, the api and the dashboard (default)", runServe},
+	"fetch":    {"Run the exporters once and print the metrics or an api result", runFetch},
+	"check":    {"Run the health checks of the exporters, exits with 1 on failures", runCheck},
+	"diff":     {"Compare two metric snapshots", runDiff},
+	"summary":  {"Print the daily summary", runSummary},
+	"backfill": {"Write the history of the sources as OpenMetrics file", runBackfill},
+	"metadata": {"List region names of the exporters that are missing in the metadata files", runMetadata},
+}
+
+func main() {
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	c, ok := commands[name]
+	if !ok {
+		if name != "help" {
+			fmt.Fprintf(os.Stderr, "Unknown command %s\n\n", name)
+			printUsage(os.Stderr)
+			os.Exit(2)
+		}
+		printUsage(os.Stdout)
+		return
+	}
+	if err := c.run(args, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "Usage: covid19-at [command] [flags]\n\nCommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].description)
+	}
+	fmt.Fprintf(w, "\nRun covid19-at <command> -h for the flags of a command.\n")
+}
+
+//sourceFlags registers the source url flags, the returned function applies them and adds the sources of sources.yml
+func sourceFlags(flags *flag.FlagSet) func() error {
+	ministryURL := flags.String("ministry-url", he.url, "Base url of the health ministry data, file:// urls and snapshot directories are supported")
+	vaccinationURL := flags.String("vaccination-url", ve.url, "Base url of the vaccination data")
+	hospitalURL := flags.String("hospital-url", hc.url, "Base url of the hospital capacity data")
+	testingsURL := flags.String("testings-url", te.url, "Base url of the testing data")
+	ecdcURL := flags.String("ecdc-url", ec.Url, "Url of the ECDC table")
+	mathdroURL := flags.String("mathdro-url", md.url, "Base url of the mathdro api")
+	return func() error {
+		he.url, ve.url, hc.url, te.url = *ministryURL, *vaccinationURL, *hospitalURL, *testingsURL
+		ec.Url, md.url = *ecdcURL, *mathdroURL
+		if fileExists("sources.yml") {
+			sources, err := loadSourcesConfig("sources.yml")
+			if err != nil {
+				return err
+			}
+			for _, s := range sources {
+				exporters = append(exporters, s)
+			}
+		}
+		return nil
+	}
+}
+
+//selectExporters returns the exporters with the given names (see exporterName), all exporters if names is empty
+func selectExporters(names []string) ([]Exporter, error) {
+	if len(names) == 0 {
+		return exporters, nil
+	}
+	result := make([]Exporter, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, e := range exporters {
+			if exporterName(e) == name {
+				result, found = append(result, e), true
+			}
+		}
+		if !found {
+			available := make([]string, 0, len(exporters))
+			for _, e := range exporters {
+				available = append(available, exporterName(e))
+			}
+			return nil, fmt.Errorf("Unknown exporter %s, available: %s", name, strings.Join(available, ", "))
+		}
+	}
+	return result, nil
+}
+
+//fetchApis are the api results that fetch prints instead of the metrics
+var fetchApis = map[string]func() (interface{}, error){
+	"total":      func() (interface{}, error) { return a.GetOverallStat() },
+	"bundesland": func() (interface{}, error) { return a.GetBundeslandStat() },
+	"bezirk":     func() (interface{}, error) { return a.GetBezirkStat() },
+}
+
+//writeFormatted writes metrics or an api result as json or csv
+func writeFormatted(w io.Writer, format string, result interface{}) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case "csv":
+		return writeCsv(w, result, false)
+	}
+	return fmt.Errorf("Unknown format %s", format)
+}
+
+func runFetch(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("fetch", flag.ExitOnError)
+	applySources := sourceFlags(flags)
+	format := flags.String("format", "prometheus", "Output format: prometheus, influx, graphite, json or csv")
+	exporterNames := flags.String("exporter", "", "Comma separated exporters to run, all by default")
+	api := flags.String("api", "", "Print the api result total, bundesland or bezirk instead of the metrics (json or csv)")
+	flags.Parse(args)
+	if err := applySources(); err != nil {
+		return err
+	}
+
+	if *api != "" {
+		f, ok := fetchApis[*api]
+		if !ok {
+			return fmt.Errorf("Unknown api %s", *api)
+		}
+		result, err := f()
+		if err != nil {
+			return err
+		}
+		return writeFormatted(stdout, *format, result)
+	}
+
+	selected, err := selectExporters(splitList([]string{*exporterNames}))
+	if err != nil {
+		return err
+	}
+	encoders := map[string]metricsEncoder{"prometheus": prometheusEncoder{}, "influx": influxEncoder{}, "graphite": graphiteEncoder{prefix: "covid19"}}
+	encoder, ok := encoders[*format]
+	if !ok && *format != "json" && *format != "csv" {
+		return fmt.Errorf("Unknown format %s", *format)
+	}
+	result := make(metrics, 0)
+	for _, e := range selected {
+		m, err := e.GetMetrics()
+		if err != nil {
+			return fmt.Errorf("%s: %s", exporterName(e), err)
+		}
+		result = append(result, m...)
+	}
+	if ok {
+		return encoder.encode(stdout, result, time.Time{})
+	}
+	return writeFormatted(stdout, *format, result)
+}
+
+func runCheck(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	applySources := sourceFlags(flags)
+	exporterNames := flags.String("exporter", "", "Comma separated exporters to check, all by default")
+	quiet := flags.Bool("quiet", false, "Print only failures")
+	flags.Parse(args)
+	if err := applySources(); err != nil {
+		return err
+	}
+	selected, err := selectExporters(splitList([]string{*exporterNames}))
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, e := range selected {
+		errs := e.Health()
+		if len(errs) == 0 {
+			if !*quiet {
+				fmt.Fprintf(stdout, "OK   %s\n", exporterName(e))
+			}
+			continue
+		}
+		failed++
+		for _, err := range errs {
+			fmt.Fprintf(stdout, "FAIL %s: %s\n", exporterName(e), err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d exporters are unhealthy", failed, len(selected))
+	}
+	return nil
+}
+
+//loadSnapshot reads the metrics of a history snapshot file, a day of the history or the live exporters for "now"
+func loadSnapshot(name string, historyDir string) (metrics, error) {
+	if name == "now" {
+		return collectMetrics(exporters), nil
+	}
+	if date, err := time.Parse(snapshotLayout, name); err == nil {
+		if historyDir == "" {
+			return nil, errors.New("Dates require -history-dir")
+		}
+		store, err := newHistoryStore(historyDir)
+		if err != nil {
+			return nil, err
+		}
+		return store.load(date)
+	}
+	content, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	result := make(metrics, 0)
+	return result, json.Unmarshal(content, &result)
+}
+
+func runDiff(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	applySources := sourceFlags(flags)
+	historyDir := flags.String("history-dir", "", "Directory of the daily snapshots for dates as arguments")
+	format := flags.String("format", "text", "Output format: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: covid19-at diff [flags] <old> <new>\n\n<old> and <new> are snapshot files, dates (YYYY-MM-DD) of -history-dir or now\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("diff requires two snapshots")
+	}
+	if err := applySources(); err != nil {
+		return err
+	}
+	previous, err := loadSnapshot(flags.Arg(0), *historyDir)
+	if err != nil {
+		return err
+	}
+	current, err := loadSnapshot(flags.Arg(1), *historyDir)
+	if err != nil {
+		return err
+	}
+
+	changes := diffMetrics(previous, current)
+	//diffMetrics ignores removed series, they are listed with a missing new value
+	keys := make(map[string]bool, len(current))
+	for _, m := range current {
+		keys[seriesKey(m)] = true
+	}
+	removed := make(metrics, 0)
+	for _, m := range previous {
+		if !keys[seriesKey(m)] {
+			removed = append(removed, m)
+		}
+	}
+
+	if *format == "json" {
+		return writeFormatted(stdout, "json", struct {
+			Changes []metricChange `json:"changes"`
+			Removed metrics        `json:"removed"`
+		}{changes, removed})
+	}
+	if *format != "text" {
+		return fmt.Errorf("Unknown format %s", *format)
+	}
+	lines := make([]string, 0, len(changes)+len(removed))
+	for _, c := range changes {
+		m := metric{Name: c.Metric}
+		if len(c.Labels) > 0 {
+			m.Tags = &c.Labels
+		}
+		key := seriesKey(m)
+		if c.Old == nil {
+			lines = append(lines, fmt.Sprintf("+ %s %g", key, c.New))
+		} else {
+			lines = append(lines, fmt.Sprintf("~ %s %g -> %g (%+g)", key, *c.Old, c.New, c.New-*c.Old))
+		}
+	}
+	for _, m := range removed {
+		lines = append(lines, fmt.Sprintf("- %s %g", seriesKey(m), m.Value))
+	}
+	//sort by series, the marker is the first two characters
+	sort.Slice(lines, func(i, j int) bool { return lines[i][2:] < lines[j][2:] })
+	for _, line := range lines {
+		fmt.Fprintln(stdout, line)
+	}
+	return nil
+}
+
+func runSummary(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("summary", flag.ExitOnError)
+	applySources := sourceFlags(flags)
+	historyDir := flags.String("history-dir", "", "Directory of the daily snapshots, required for the changes and the incidence")
+	format := flags.String("format", "text", "Output format: text, markdown or slack")
+	lang := flags.String("lang", "de", "Language: de or en")
+	flags.Parse(args)
+	if err := applySources(); err != nil {
+		return err
+	}
+	var store *historyStore
+	if *historyDir != "" {
+		var err error
+		if store, err = newHistoryStore(*historyDir); err != nil {
+			return err
+		}
+	}
+	summary, err := a.getSummary(store, now())
+	if err != nil {
+		return err
+	}
+	content, err := summary.render(*format, *lang)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(content)
+	return err
+}
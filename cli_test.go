@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//withTestSources points the health ministry exporter to the dashboard fixture and adds the given exporters
+func withTestSources(t *testing.T, extra ...Exporter) (string, func()) {
+	testApi, cleanup := newTestDashboardApi(t)
+	previousURL, previousExporters := he.url, exporters
+	exporters = append(exporters[:len(exporters):len(exporters)], extra...)
+	return testApi.he.url, func() {
+		he.url, exporters = previousURL, previousExporters
+		cleanup()
+	}
+}
+
+func TestFetchCommand(t *testing.T) {
+	url, cleanup := withTestSources(t)
+	defer cleanup()
+
+	output := bytes.Buffer{}
+	assert.Nil(t, runFetch([]string{"-ministry-url", url, "-exporter", "healthMinistry", "-format", "influx"}, &output))
+	assert.Contains(t, output.String(), "cov19_confirmed value=1700\n")
+	assert.Contains(t, output.String(), "cov19_bezirk_infected,bezirk=Graz(Stadt),country=Austria,")
+
+	output.Reset()
+	assert.Nil(t, runFetch([]string{"-ministry-url", url, "-exporter", "healthMinistry", "-format", "json"}, &output))
+	result := metrics{}
+	assert.Nil(t, json.Unmarshal(output.Bytes(), &result))
+	assert.Equal(t, 1700.0, result.findMetric("cov19_confirmed", "").Value)
+
+	output.Reset()
+	assert.Nil(t, runFetch([]string{"-ministry-url", url, "-api", "bezirk", "-format", "csv"}, &output))
+	assert.True(t, strings.HasPrefix(output.String(), "name,location_lat,location_long,population,infected\r\n"), output.String())
+	assert.Contains(t, output.String(), "\nGraz(Stadt),47.070714,15.439504,288806,340\r\n")
+
+	assert.NotNil(t, runFetch([]string{"-ministry-url", url, "-exporter", "healthMinistry", "-format", "xml"}, &output))
+	assert.NotNil(t, runFetch([]string{"-ministry-url", url, "-exporter", "unknown"}, &output))
+}
+
+func TestCheckCommand(t *testing.T) {
+	healthy, unhealthy := &staticExporter{}, &unhealthyExporter{errors: []error{os.ErrNotExist}}
+	url, cleanup := withTestSources(t, healthy, unhealthy)
+	defer cleanup()
+
+	output := bytes.Buffer{}
+	assert.Nil(t, runCheck([]string{"-ministry-url", url, "-exporter", "static"}, &output))
+	assert.Equal(t, "OK   static\n", output.String())
+
+	output.Reset()
+	err := runCheck([]string{"-ministry-url", url, "-exporter", "static,unhealthy", "-quiet"}, &output)
+	assert.Equal(t, "1 of 2 exporters are unhealthy", err.Error())
+	assert.Equal(t, "FAIL unhealthy: file does not exist\n", output.String())
+}
+
+func TestDiffCommand(t *testing.T) {
+	store, cleanup := newTestHistory(t)
+	defer cleanup()
+	assert.Nil(t, store.record(time.Date(2020, 11, 8, 10, 0, 0, 0, time.UTC), metrics{
+		{"cov19_confirmed", nil, 1600},
+		{"cov19_detail", &map[string]string{"province": "Wien"}, 1100},
+		{"cov19_tests", nil, 20000},
+	}))
+	assert.Nil(t, store.record(time.Date(2020, 11, 9, 10, 0, 0, 0, time.UTC), metrics{
+		{"cov19_confirmed", nil, 1700},
+		{"cov19_detail", &map[string]string{"province": "Wien"}, 1100},
+		{"cov19_detail", &map[string]string{"province": "Tirol"}, 300},
+	}))
+
+	output := bytes.Buffer{}
+	assert.Nil(t, runDiff([]string{"-history-dir", store.dir, "2020-11-08", filepath.Join(store.dir, "2020-11-09.json")}, &output))
+	assert.Equal(t, `~ cov19_confirmed 1600 -> 1700 (+100)
++ cov19_detail{province=Tirol} 300
+- cov19_tests 20000
+`, output.String())
+
+	output.Reset()
+	assert.Nil(t, runDiff([]string{"-history-dir", store.dir, "-format", "json", "2020-11-08", "2020-11-09"}, &output))
+	assert.Contains(t, output.String(), `"removed": [`)
+
+	assert.NotNil(t, runDiff([]string{"2020-11-08", "2020-11-09"}, ioutil.Discard))
+	assert.NotNil(t, runDiff([]string{"2020-11-08"}, ioutil.Discard))
+}
+
+func TestSummaryCommand(t *testing.T) {
+	url, cleanup := withTestSources(t)
+	defer cleanup()
+
+	output := bytes.Buffer{}
+	assert.Nil(t, runSummary([]string{"-ministry-url", url, "-lang", "en"}, &output))
+	assert.Contains(t, output.String(), "\nInfected: 1,700\n")
+	assert.NotNil(t, runSummary([]string{"-ministry-url", url, "-format", "pdf"}, ioutil.Discard))
+}
@@ -0,0 +1,221 @@
+//Package client is a typed client for the JSON api of covid19-at, the types are generated from openapi.json
+package client
+
+//go:generate go run ../cmd/openapi-client -spec ../openapi.json -out types.go
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Client requests the api of a covid19-at server
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+//HistoryQuery selects the values of the history endpoints, empty fields use the server defaults
+type HistoryQuery struct {
+	Metric string
+	Name   string
+	From   time.Time
+	To     time.Time
+}
+
+//New returns a client for the server at baseURL, e.g. http://localhost:8282
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, result interface{}) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s: %s", path, response.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, result)
+}
+
+//Total returns the national statistics
+func (c *Client) Total(ctx context.Context) (Total, error) {
+	result := Total{}
+	err := c.get(ctx, "/api/total", nil, &result)
+	return result, err
+}
+
+//Bundeslaender returns the statistics of all provinces
+func (c *Client) Bundeslaender(ctx context.Context) ([]Bundesland, error) {
+	result := make([]Bundesland, 0)
+	err := c.get(ctx, "/api/bundesland", nil, &result)
+	return result, err
+}
+
+//Bezirke returns the statistics of all districts
+func (c *Client) Bezirke(ctx context.Context) ([]Bezirk, error) {
+	result := make([]Bezirk, 0)
+	err := c.get(ctx, "/api/bezirk", nil, &result)
+	return result, err
+}
+
+//Bezirk returns the statistics of a district by name or GKZ
+func (c *Client) Bezirk(ctx context.Context, id string) (Bezirk, error) {
+	result := Bezirk{}
+	err := c.get(ctx, "/api/bezirk/"+url.PathEscape(id), nil, &result)
+	return result, err
+}
+
+//Bundesland returns the statistics of a province by name, GKZ or ISO 3166-2 code
+func (c *Client) Bundesland(ctx context.Context, id string) (Bundesland, error) {
+	result := Bundesland{}
+	err := c.get(ctx, "/api/bundesland/"+url.PathEscape(id), nil, &result)
+	return result, err
+}
+
+//GeoBezirke returns the districts as GeoJSON with their boundaries
+func (c *Client) GeoBezirke(ctx context.Context) (GeoFeatureCollection, error) {
+	result := GeoFeatureCollection{}
+	err := c.get(ctx, "/api/geo/bezirk", nil, &result)
+	return result, err
+}
+
+//GeoBundeslaender returns the provinces as GeoJSON with their boundaries
+func (c *Client) GeoBundeslaender(ctx context.Context) (GeoFeatureCollection, error) {
+	result := GeoFeatureCollection{}
+	err := c.get(ctx, "/api/geo/bundesland", nil, &result)
+	return result, err
+}
+
+func locateValues(lat float64, lon float64) url.Values {
+	return url.Values{"lat": {strconv.FormatFloat(lat, 'f', -1, 64)}, "lon": {strconv.FormatFloat(lon, 'f', -1, 64)}}
+}
+
+//Locate returns the statistics of the district containing a point
+func (c *Client) Locate(ctx context.Context, lat float64, lon float64) (Locate, error) {
+	result := Locate{}
+	err := c.get(ctx, "/api/locate", locateValues(lat, lon), &result)
+	return result, err
+}
+
+func (q HistoryQuery) values() url.Values {
+	values := url.Values{}
+	if q.Metric != "" {
+		values.Set("metric", q.Metric)
+	}
+	if q.Name != "" {
+		values.Set("name", q.Name)
+	}
+	if !q.From.IsZero() {
+		values.Set("from", q.From.Format("2006-01-02"))
+	}
+	if !q.To.IsZero() {
+		values.Set("to", q.To.Format("2006-01-02"))
+	}
+	return values
+}
+
+func (c *Client) history(ctx context.Context, path string, q HistoryQuery) ([]HistoryPoint, error) {
+	result := make([]HistoryPoint, 0)
+	err := c.get(ctx, path, q.values(), &result)
+	return result, err
+}
+
+//HistoryTotal returns the daily values of a national metric
+func (c *Client) HistoryTotal(ctx context.Context, q HistoryQuery) ([]HistoryPoint, error) {
+	return c.history(ctx, "/api/history/total", q)
+}
+
+//HistoryBundesland returns the daily values of a province metric
+func (c *Client) HistoryBundesland(ctx context.Context, q HistoryQuery) ([]HistoryPoint, error) {
+	return c.history(ctx, "/api/history/bundesland", q)
+}
+
+//HistoryBezirk returns the daily values of a district metric
+func (c *Client) HistoryBezirk(ctx context.Context, q HistoryQuery) ([]HistoryPoint, error) {
+	return c.history(ctx, "/api/history/bezirk", q)
+}
+
+//TotalV2 returns the national statistics of the v2 api
+func (c *Client) TotalV2(ctx context.Context) (TotalResponseV2, error) {
+	result := TotalResponseV2{}
+	err := c.get(ctx, "/api/v2/total", nil, &result)
+	return result, err
+}
+
+//BundeslaenderV2 returns the statistics of all provinces of the v2 api
+func (c *Client) BundeslaenderV2(ctx context.Context) (BundeslandResponseV2, error) {
+	result := BundeslandResponseV2{}
+	err := c.get(ctx, "/api/v2/bundesland", nil, &result)
+	return result, err
+}
+
+//BezirkeV2 returns the statistics of all districts of the v2 api
+func (c *Client) BezirkeV2(ctx context.Context) (BezirkResponseV2, error) {
+	result := BezirkResponseV2{}
+	err := c.get(ctx, "/api/v2/bezirk", nil, &result)
+	return result, err
+}
+
+//BezirkV2 returns the statistics of a district by name or GKZ of the v2 api
+func (c *Client) BezirkV2(ctx context.Context, id string) (BezirkItemResponseV2, error) {
+	result := BezirkItemResponseV2{}
+	err := c.get(ctx, "/api/v2/bezirk/"+url.PathEscape(id), nil, &result)
+	return result, err
+}
+
+//BundeslandV2 returns the statistics of a province by name, GKZ or ISO 3166-2 code of the v2 api
+func (c *Client) BundeslandV2(ctx context.Context, id string) (BundeslandItemResponseV2, error) {
+	result := BundeslandItemResponseV2{}
+	err := c.get(ctx, "/api/v2/bundesland/"+url.PathEscape(id), nil, &result)
+	return result, err
+}
+
+//LocateV2 returns the statistics of the district containing a point of the v2 api
+func (c *Client) LocateV2(ctx context.Context, lat float64, lon float64) (LocateResponseV2, error) {
+	result := LocateResponseV2{}
+	err := c.get(ctx, "/api/v2/locate", locateValues(lat, lon), &result)
+	return result, err
+}
+
+func (c *Client) historyV2(ctx context.Context, path string, q HistoryQuery) (HistoryResponseV2, error) {
+	result := HistoryResponseV2{}
+	err := c.get(ctx, path, q.values(), &result)
+	return result, err
+}
+
+//HistoryTotalV2 returns the daily values of a national metric of the v2 api
+func (c *Client) HistoryTotalV2(ctx context.Context, q HistoryQuery) (HistoryResponseV2, error) {
+	return c.historyV2(ctx, "/api/v2/history/total", q)
+}
+
+//HistoryBundeslandV2 returns the daily values of a province metric of the v2 api
+func (c *Client) HistoryBundeslandV2(ctx context.Context, q HistoryQuery) (HistoryResponseV2, error) {
+	return c.historyV2(ctx, "/api/v2/history/bundesland", q)
+}
+
+//HistoryBezirkV2 returns the daily values of a district metric of the v2 api
+func (c *Client) HistoryBezirkV2(ctx context.Context, q HistoryQuery) (HistoryResponseV2, error) {
+	return c.historyV2(ctx, "/api/v2/history/bezirk", q)
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		switch r.URL.Path {
+		case "/api/total":
+			w.Write([]byte(`{"TotalInfected":1700,"AgeDistributionInfection":{"<5":10},"Vaccination":{"Doses":5,"SecondDosePercent":1.5}}`))
+		case "/api/bundesland":
+			w.Write([]byte(`[{"Name":"Wien","Location":{"Lat":48.2,"Long":16.37},"Population":1889100,"Infected":1200,"TestsPCR":30}]`))
+		case "/api/bezirk":
+			w.Write([]byte(`[{"Name":"Graz(Stadt)","Location":{"Lat":47.07,"Long":15.43},"Population":288806,"Infected":340}]`))
+		case "/api/v2/bezirk":
+			w.Write([]byte(`{"meta":{"source":["Bundesministerium für Soziales, Gesundheit, Pflege und Konsumentenschutz"],"as_of":"2020-11-09T10:00:00+01:00","license":"CC BY 4.0"},` +
+				`"data":[{"id":"601","name":"Graz(Stadt)","province_id":"AT-6","location":{"latitude":47.07,"longitude":15.43},"infected_per_100k":117.7}]}`))
+		case "/api/bezirk/Graz(Stadt)":
+			w.Write([]byte(`{"Name":"Graz(Stadt)","Location":{"Lat":47.07,"Long":15.43},"Population":288806,"Infected":340}`))
+		case "/api/geo/bezirk":
+			w.Write([]byte(`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[15.43,47.07]},` +
+				`"properties":{"id":"601","name":"Graz(Stadt)","population":288806,"infected":340,"infected_per_100k":117.7,"incidence_7d_per_100k":null}}]}`))
+		case "/api/locate":
+			assert.Equal(t, "lat=47.07&lon=15.44", r.URL.RawQuery)
+			w.Write([]byte(`{"Bezirk":{"Name":"Graz(Stadt)"}}`))
+		case "/api/history/bundesland":
+			assert.Equal(t, "from=2020-03-20&metric=cov19_detail_dead&name=Wien", r.URL.RawQuery)
+			w.Write([]byte(`[{"Date":"2020-03-20","Name":"cov19_detail_dead","Tags":{"province":"Wien"},"Value":3}]`))
+		default:
+			http.Error(w, "History is disabled", http.StatusInternalServerError)
+		}
+	}))
+}
+
+func TestClient(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	c := New(ts.URL + "/")
+	ctx := context.Background()
+
+	total, err := c.Total(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1700), total.TotalInfected)
+	assert.Equal(t, uint64(10), total.AgeDistributionInfection["<5"])
+	assert.Equal(t, 1.5, total.Vaccination.SecondDosePercent)
+
+	provinces, err := c.Bundeslaender(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []Bundesland{{Name: "Wien", Location: Location{48.2, 16.37}, Population: 1889100, Infected: 1200, TestsPCR: 30}}, provinces)
+
+	districts, err := c.Bezirke(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "Graz(Stadt)", districts[0].Name)
+
+	district, err := c.Bezirk(ctx, "Graz(Stadt)")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(340), district.Infected)
+	located, err := c.Locate(ctx, 47.07, 15.44)
+	assert.Nil(t, err)
+	assert.Equal(t, Locate{Bezirk: Bezirk{Name: "Graz(Stadt)"}}, located)
+
+	geo, err := c.GeoBezirke(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "Point", geo.Features[0].Geometry.Type)
+	assert.Equal(t, []interface{}{15.43, 47.07}, geo.Features[0].Geometry.Coordinates)
+	assert.Equal(t, GeoProperties{ID: "601", Name: "Graz(Stadt)", Population: 288806, Infected: 340, InfectedPer100k: 117.7}, geo.Features[0].Properties)
+
+	points, err := c.HistoryBundesland(ctx, HistoryQuery{Metric: "cov19_detail_dead", Name: "Wien", From: time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC)})
+	assert.Nil(t, err)
+	assert.Equal(t, []HistoryPoint{{"2020-03-20", "cov19_detail_dead", map[string]string{"province": "Wien"}, 3}}, points)
+
+	v2, err := c.BezirkeV2(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "CC BY 4.0", v2.Meta.License)
+	assert.Equal(t, time.Date(2020, 11, 9, 9, 0, 0, 0, time.UTC), v2.Meta.AsOf.UTC())
+	assert.Equal(t, []BezirkV2{{ID: "601", Name: "Graz(Stadt)", ProvinceID: "AT-6", Location: LocationV2{47.07, 15.43}, InfectedPer100k: 117.7}}, v2.Data)
+
+	_, err = c.HistoryTotal(ctx, HistoryQuery{})
+	assert.EqualError(t, err, "/api/history/total: 500 Internal Server Error: History is disabled")
+}
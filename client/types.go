@@ -0,0 +1,322 @@
+// Code generated by go run ./cmd/openapi-client; DO NOT EDIT.
+
+package client
+
+import "time"
+
+// Bezirk defines the Bezirk schema
+// Statistics of a district
+type Bezirk struct {
+	Name       string   `json:"Name"`
+	Location   Location `json:"Location"`
+	Population uint64   `json:"Population"`
+	Infected   uint64   `json:"Infected"`
+}
+
+// BezirkItemResponseV2 defines the BezirkItemResponseV2 schema
+// Response of /api/v2/bezirk/{id}
+type BezirkItemResponseV2 struct {
+	Meta MetaV2   `json:"meta"`
+	Data BezirkV2 `json:"data"`
+}
+
+// BezirkResponseV2 defines the BezirkResponseV2 schema
+// Response of /api/v2/bezirk
+type BezirkResponseV2 struct {
+	Meta MetaV2     `json:"meta"`
+	Data []BezirkV2 `json:"data"`
+}
+
+// BezirkV2 defines the BezirkV2 schema
+// Statistics of a district
+type BezirkV2 struct {
+	//Gemeindekennziffer of Statistik Austria, e.g. 601
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	//ISO 3166-2 code of the province
+	ProvinceID string     `json:"province_id"`
+	Location   LocationV2 `json:"location"`
+	Population uint64     `json:"population"`
+	Infected   uint64     `json:"infected"`
+	//Infected per 100.000 inhabitants
+	InfectedPer100k float64 `json:"infected_per_100k"`
+	//New cases of the last 7 days per 100.000 inhabitants, null without history
+	Incidence7dPer100k *float64 `json:"incidence_7d_per_100k"`
+}
+
+// Bundesland defines the Bundesland schema
+// Statistics of a province
+type Bundesland struct {
+	Name              string      `json:"Name"`
+	Location          Location    `json:"Location"`
+	Population        uint64      `json:"Population"`
+	Infected          uint64      `json:"Infected"`
+	Dead              uint64      `json:"Dead"`
+	Hospitalized      uint64      `json:"Hospitalized"`
+	IntensiveCare     uint64      `json:"IntensiveCare"`
+	Healed            uint64      `json:"Healed"`
+	Vaccination       Vaccination `json:"Vaccination"`
+	HospitalFree      uint64      `json:"HospitalFree"`
+	IntensiveCareFree uint64      `json:"IntensiveCareFree"`
+	//Share of occupied hospital beds (0-1)
+	HospitalUtilisation float64 `json:"HospitalUtilisation"`
+	//Share of occupied intensive care beds (0-1)
+	IntensiveCareUtilisation float64 `json:"IntensiveCareUtilisation"`
+	Tests                    uint64  `json:"Tests"`
+	TestsPCR                 uint64  `json:"TestsPCR"`
+	TestsAntigen             uint64  `json:"TestsAntigen"`
+	TestsDaily               uint64  `json:"TestsDaily"`
+	//Share of positive tests of the last 7 days (0-1)
+	TestPositivity float64 `json:"TestPositivity"`
+}
+
+// BundeslandItemResponseV2 defines the BundeslandItemResponseV2 schema
+// Response of /api/v2/bundesland/{id}
+type BundeslandItemResponseV2 struct {
+	Meta MetaV2       `json:"meta"`
+	Data BundeslandV2 `json:"data"`
+}
+
+// BundeslandResponseV2 defines the BundeslandResponseV2 schema
+// Response of /api/v2/bundesland
+type BundeslandResponseV2 struct {
+	Meta MetaV2         `json:"meta"`
+	Data []BundeslandV2 `json:"data"`
+}
+
+// BundeslandV2 defines the BundeslandV2 schema
+// Statistics of a province
+type BundeslandV2 struct {
+	//ISO 3166-2 code, e.g. AT-9
+	ID string `json:"id"`
+	//Gemeindekennziffer of Statistik Austria
+	GKZ        string     `json:"gkz"`
+	Name       string     `json:"name"`
+	Location   LocationV2 `json:"location"`
+	Population uint64     `json:"population"`
+	Infected   uint64     `json:"infected"`
+	//Infected per 100.000 inhabitants
+	InfectedPer100k       float64 `json:"infected_per_100k"`
+	Healed                uint64  `json:"healed"`
+	Dead                  uint64  `json:"dead"`
+	Hospitalized          uint64  `json:"hospitalized"`
+	IntensiveCare         uint64  `json:"intensive_care"`
+	HospitalFreeBeds      uint64  `json:"hospital_free_beds"`
+	IntensiveCareFreeBeds uint64  `json:"intensive_care_free_beds"`
+	//Occupied hospital beds in percent
+	HospitalUtilisationPercent float64 `json:"hospital_utilisation_percent"`
+	//Occupied intensive care beds in percent
+	IntensiveCareUtilisationPercent float64       `json:"intensive_care_utilisation_percent"`
+	Tests                           TestsV2       `json:"tests"`
+	Vaccination                     VaccinationV2 `json:"vaccination"`
+}
+
+// ErrorV2 defines the ErrorV2 schema
+// Error of a v2 request
+type ErrorV2 struct {
+	Error string `json:"error"`
+}
+
+// GeoFeature defines the GeoFeature schema
+// GeoJSON Feature of a region
+type GeoFeature struct {
+	Type       string        `json:"type"`
+	Geometry   GeoGeometry   `json:"geometry"`
+	Properties GeoProperties `json:"properties"`
+}
+
+// GeoFeatureCollection defines the GeoFeatureCollection schema
+// GeoJSON FeatureCollection of regions
+type GeoFeatureCollection struct {
+	Type     string       `json:"type"`
+	Features []GeoFeature `json:"features"`
+}
+
+// GeoGeometry defines the GeoGeometry schema
+// GeoJSON geometry in WGS84
+type GeoGeometry struct {
+	Type string `json:"type"`
+	//[longitude, latitude] of a Point, rings of points of a Polygon or polygons of a MultiPolygon
+	Coordinates []interface{} `json:"coordinates"`
+}
+
+// GeoProperties defines the GeoProperties schema
+// Statistics of a region of a GeoJSON Feature
+type GeoProperties struct {
+	//Gemeindekennziffer of Statistik Austria, e.g. 601 for districts and 6 for provinces
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	Population      uint64  `json:"population"`
+	Infected        uint64  `json:"infected"`
+	InfectedPer100k float64 `json:"infected_per_100k"`
+	//New cases of the last 7 days per 100.000 inhabitants, null without history
+	Incidence7dPer100k *float64 `json:"incidence_7d_per_100k"`
+	//Deaths, only provinces
+	Dead uint64 `json:"dead"`
+}
+
+// HistoryPoint defines the HistoryPoint schema
+// Value of a metric at the end of a day
+type HistoryPoint struct {
+	Date  string            `json:"Date"`
+	Name  string            `json:"Name"`
+	Tags  map[string]string `json:"Tags"`
+	Value float64           `json:"Value"`
+}
+
+// HistoryPointV2 defines the HistoryPointV2 schema
+// Value of a metric at the end of a day
+type HistoryPointV2 struct {
+	Date string `json:"date"`
+	//Prometheus metric name
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// HistoryResponseV2 defines the HistoryResponseV2 schema
+// Response of the /api/v2/history endpoints
+type HistoryResponseV2 struct {
+	Meta MetaV2           `json:"meta"`
+	Data []HistoryPointV2 `json:"data"`
+}
+
+// Locate defines the Locate schema
+// District containing a point
+type Locate struct {
+	Bezirk Bezirk `json:"Bezirk"`
+}
+
+// LocateResponseV2 defines the LocateResponseV2 schema
+// Response of /api/v2/locate
+type LocateResponseV2 struct {
+	Meta MetaV2   `json:"meta"`
+	Data LocateV2 `json:"data"`
+}
+
+// LocateV2 defines the LocateV2 schema
+// District containing a point
+type LocateV2 struct {
+	Bezirk BezirkV2 `json:"bezirk"`
+}
+
+// Location defines the Location schema
+// Position of the capital of a region
+type Location struct {
+	Lat  float64 `json:"Lat"`
+	Long float64 `json:"Long"`
+}
+
+// LocationV2 defines the LocationV2 schema
+// Position of the capital of a region in WGS 84
+type LocationV2 struct {
+	//Degrees
+	Latitude float64 `json:"latitude"`
+	//Degrees
+	Longitude float64 `json:"longitude"`
+}
+
+// MetaV2 defines the MetaV2 schema
+// Metadata of a v2 response
+type MetaV2 struct {
+	//Public names of the sources of the data
+	Source []string `json:"source"`
+	//Time of the upstream report of the data, null if it is unknown
+	AsOf *time.Time `json:"as_of"`
+	//License of the data
+	License string `json:"license"`
+	//Required attribution of the data
+	Attribution string `json:"attribution"`
+}
+
+// SlackMessage defines the SlackMessage schema
+// Slack message, Mattermost shows the text
+type SlackMessage struct {
+	//Summary as markdown
+	Text string `json:"text"`
+	//Slack Block Kit blocks
+	Blocks []map[string]interface{} `json:"blocks"`
+}
+
+// TestsV2 defines the TestsV2 schema
+// PCR and antigen tests of a region
+type TestsV2 struct {
+	Total   uint64 `json:"total"`
+	PCR     uint64 `json:"pcr"`
+	Antigen uint64 `json:"antigen"`
+	//Tests reported on the last day
+	Daily uint64 `json:"daily"`
+	//Share of positive tests of the last 7 days in percent
+	Positivity7dPercent float64 `json:"positivity_7d_percent"`
+}
+
+// Total defines the Total schema
+// National statistics of Austria
+type Total struct {
+	TotalInfected              uint64                 `json:"TotalInfected"`
+	TotalDead                  uint64                 `json:"TotalDead"`
+	TotalHospitalized          uint64                 `json:"TotalHospitalized"`
+	TotalIntensiveCare         uint64                 `json:"TotalIntensiveCare"`
+	AgeDistributionInfection   map[string]uint64      `json:"AgeDistributionInfection"`
+	Vaccination                Vaccination            `json:"Vaccination"`
+	AgeDistributionVaccination map[string]Vaccination `json:"AgeDistributionVaccination"`
+	TotalTests                 uint64                 `json:"TotalTests"`
+	TotalTestsPCR              uint64                 `json:"TotalTestsPCR"`
+	TotalTestsAntigen          uint64                 `json:"TotalTestsAntigen"`
+	TestsDaily                 uint64                 `json:"TestsDaily"`
+	//Share of positive tests of the last 7 days (0-1)
+	TestPositivity float64 `json:"TestPositivity"`
+}
+
+// TotalResponseV2 defines the TotalResponseV2 schema
+// Response of /api/v2/total
+type TotalResponseV2 struct {
+	Meta MetaV2  `json:"meta"`
+	Data TotalV2 `json:"data"`
+}
+
+// TotalV2 defines the TotalV2 schema
+// National statistics of Austria
+type TotalV2 struct {
+	//ISO 3166-1 code
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Population uint64 `json:"population"`
+	Infected   uint64 `json:"infected"`
+	//Infected per 100.000 inhabitants
+	InfectedPer100k       float64                  `json:"infected_per_100k"`
+	Dead                  uint64                   `json:"dead"`
+	Hospitalized          uint64                   `json:"hospitalized"`
+	IntensiveCare         uint64                   `json:"intensive_care"`
+	InfectedByAgeGroup    map[string]uint64        `json:"infected_by_age_group"`
+	Tests                 TestsV2                  `json:"tests"`
+	Vaccination           VaccinationV2            `json:"vaccination"`
+	VaccinationByAgeGroup map[string]VaccinationV2 `json:"vaccination_by_age_group"`
+}
+
+// Vaccination defines the Vaccination schema
+// Administered vaccination doses of a region
+type Vaccination struct {
+	Doses              uint64  `json:"Doses"`
+	FirstDose          uint64  `json:"FirstDose"`
+	SecondDose         uint64  `json:"SecondDose"`
+	BoosterDose        uint64  `json:"BoosterDose"`
+	FirstDosePercent   float64 `json:"FirstDosePercent"`
+	SecondDosePercent  float64 `json:"SecondDosePercent"`
+	BoosterDosePercent float64 `json:"BoosterDosePercent"`
+}
+
+// VaccinationV2 defines the VaccinationV2 schema
+// Administered vaccination doses of a region
+type VaccinationV2 struct {
+	Doses       uint64 `json:"doses"`
+	FirstDose   uint64 `json:"first_dose"`
+	SecondDose  uint64 `json:"second_dose"`
+	BoosterDose uint64 `json:"booster_dose"`
+	//Share of the population with a first dose in percent
+	FirstDosePercent float64 `json:"first_dose_percent"`
+	//Share of the population with a second dose in percent
+	SecondDosePercent float64 `json:"second_dose_percent"`
+	//Share of the population with a booster dose in percent
+	BoosterDosePercent float64 `json:"booster_dose_percent"`
+}
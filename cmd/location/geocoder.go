@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+//geocoder resolves a place name like "Graz(Stadt), Steiermark, Austria" to coordinates
+type geocoder interface {
+	name() string
+	geocode(query string) (coordinates, error)
+}
+
+//errNotFound is returned if a geocoder knows no place for the query
+var errNotFound = errors.New("Place not found")
+
+func getJson(client *http.Client, request *http.Request, result interface{}) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", request.URL.Host, response.Status)
+	}
+	return json.Unmarshal(body, result)
+}
+
+//googleGeocoder uses the Find Place request of the Google Places API
+type googleGeocoder struct {
+	url    string
+	key    string
+	client *http.Client
+}
+
+type mapsResponse struct {
+	Status     string
+	Candidates []struct {
+		Geometry struct {
+			Location struct {
+				Lat float64
+				Lng float64
+			}
+		}
+	}
+}
+
+func newGoogleGeocoder(key string) (*googleGeocoder, error) {
+	if key == "" {
+		return nil, errors.New("The Google geocoder requires -google-key or GOOGLE_MAPS_API_KEY")
+	}
+	return &googleGeocoder{url: "https://maps.googleapis.com/maps/api/place/findplacefromtext/json", key: key, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (g *googleGeocoder) name() string {
+	return "google"
+}
+
+func (g *googleGeocoder) geocode(query string) (coordinates, error) {
+	parameters := url.Values{"input": {query}, "inputtype": {"textquery"}, "fields": {"geometry"}, "key": {g.key}}
+	request, err := http.NewRequest(http.MethodGet, g.url+"?"+parameters.Encode(), nil)
+	if err != nil {
+		return coordinates{}, err
+	}
+	result := mapsResponse{}
+	if err := getJson(g.client, request, &result); err != nil {
+		return coordinates{}, err
+	}
+	if result.Status != "" && result.Status != "OK" && result.Status != "ZERO_RESULTS" {
+		return coordinates{}, fmt.Errorf("Google Places API returned %s", result.Status)
+	}
+	if len(result.Candidates) == 0 {
+		return coordinates{}, errNotFound
+	}
+	location := result.Candidates[0].Geometry.Location
+	return coordinates{location.Lat, location.Lng}, nil
+}
+
+//nominatimGeocoder uses the search of OpenStreetMap Nominatim, requests are limited to one per interval
+//as required by the usage policy of the public instance
+type nominatimGeocoder struct {
+	url      string
+	client   *http.Client
+	interval time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func newNominatimGeocoder(baseURL string) *nominatimGeocoder {
+	return &nominatimGeocoder{url: strings.TrimSuffix(baseURL, "/"), client: &http.Client{Timeout: 10 * time.Second}, interval: time.Second}
+}
+
+func (n *nominatimGeocoder) name() string {
+	return "nominatim"
+}
+
+func (n *nominatimGeocoder) geocode(query string) (coordinates, error) {
+	n.mu.Lock()
+	if wait := n.interval - time.Since(n.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	n.last = time.Now()
+	n.mu.Unlock()
+
+	parameters := url.Values{"q": {query}, "format": {"json"}, "limit": {"1"}}
+	request, err := http.NewRequest(http.MethodGet, n.url+"/search?"+parameters.Encode(), nil)
+	if err != nil {
+		return coordinates{}, err
+	}
+	request.Header.Set("User-Agent", "covid19-at location tool (https://github.com/cinemast/covid19-at)")
+	result := []struct {
+		Lat string
+		Lon string
+	}{}
+	if err := getJson(n.client, request, &result); err != nil {
+		return coordinates{}, err
+	}
+	if len(result) == 0 {
+		return coordinates{}, errNotFound
+	}
+	lat, err := strconv.ParseFloat(result[0].Lat, 64)
+	if err != nil {
+		return coordinates{}, err
+	}
+	long, err := strconv.ParseFloat(result[0].Lon, 64)
+	return coordinates{lat, long}, err
+}
+
+//gazetteerGeocoder looks places up in a csv file with the columns name, latitude and longitude, it works offline
+type gazetteerGeocoder struct {
+	places map[string]coordinates
+}
+
+var nonLetters = regexp.MustCompile(`[^\p{L}]+`)
+
+//normalizePlace ignores case, spaces and punctuation like the metadata lookup of the exporter
+func normalizePlace(name string) string {
+	return strings.ToUpper(nonLetters.ReplaceAllString(name, ""))
+}
+
+func newGazetteerGeocoder(filename string) (*gazetteerGeocoder, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	places := make(map[string]coordinates, len(records))
+	for i, row := range records {
+		if len(row) < 3 {
+			return nil, fmt.Errorf("%s:%d: expected name, latitude and longitude", filename, i+1)
+		}
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(row[1]), 64)
+		long, longErr := strconv.ParseFloat(strings.TrimSpace(row[2]), 64)
+		if latErr != nil || longErr != nil {
+			//header
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: invalid coordinates", filename, i+1)
+		}
+		places[normalizePlace(row[0])] = coordinates{lat, long}
+	}
+	return &gazetteerGeocoder{places: places}, nil
+}
+
+func (g *gazetteerGeocoder) name() string {
+	return "gazetteer"
+}
+
+//geocode looks up the full query and then the place name without the region after the first comma
+func (g *gazetteerGeocoder) geocode(query string) (coordinates, error) {
+	if c, ok := g.places[normalizePlace(query)]; ok {
+		return c, nil
+	}
+	if c, ok := g.places[normalizePlace(strings.Split(query, ",")[0])]; ok {
+		return c, nil
+	}
+	return coordinates{}, errNotFound
+}
+
+//cachedGeocoder keeps the results of another geocoder in a json file so repeated runs do not query the service again
+type cachedGeocoder struct {
+	geocoder
+	filename string
+	entries  map[string]coordinates
+	changed  bool
+}
+
+func newCachedGeocoder(g geocoder, filename string) (*cachedGeocoder, error) {
+	c := &cachedGeocoder{geocoder: g, filename: filename, entries: make(map[string]coordinates)}
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &c.entries); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return c, nil
+}
+
+//geocode returns the cached coordinates of the geocoder, places that were not found are not cached
+func (c *cachedGeocoder) geocode(query string) (coordinates, error) {
+	key := c.geocoder.name() + ":" + query
+	if result, ok := c.entries[key]; ok {
+		return result, nil
+	}
+	result, err := c.geocoder.geocode(query)
+	if err == nil {
+		c.entries[key], c.changed = result, true
+	}
+	return result, err
+}
+
+//save writes the cache if new places were geocoded
+func (c *cachedGeocoder) save() error {
+	if !c.changed {
+		return nil
+	}
+	content, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.filename, content, 0644)
+}
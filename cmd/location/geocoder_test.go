@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoogleGeocoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.URL.Query().Get("key"))
+		assert.Equal(t, "geometry", r.URL.Query().Get("fields"))
+		switch r.URL.Query().Get("input") {
+		case "Graz(Stadt), Steiermark, Austria":
+			w.Write([]byte(`{"status":"OK","candidates":[{"geometry":{"location":{"lat":47.070714,"lng":15.439504}}}]}`))
+		case "Atlantis":
+			w.Write([]byte(`{"status":"ZERO_RESULTS","candidates":[]}`))
+		default:
+			w.Write([]byte(`{"status":"REQUEST_DENIED","candidates":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	_, err := newGoogleGeocoder("")
+	assert.NotNil(t, err)
+	g, err := newGoogleGeocoder("secret")
+	assert.Nil(t, err)
+	g.url = server.URL
+
+	c, err := g.geocode("Graz(Stadt), Steiermark, Austria")
+	assert.Nil(t, err)
+	assert.Equal(t, coordinates{47.070714, 15.439504}, c)
+	_, err = g.geocode("Atlantis")
+	assert.Equal(t, errNotFound, err)
+	_, err = g.geocode("Wien")
+	assert.EqualError(t, err, "Google Places API returned REQUEST_DENIED")
+}
+
+func TestNominatimGeocoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/search", r.URL.Path)
+		assert.NotEmpty(t, r.Header.Get("User-Agent"))
+		switch r.URL.Query().Get("q") {
+		case "Wien, Austria":
+			w.Write([]byte(`[{"lat":"48.2083537","lon":"16.3725042"}]`))
+		case "Atlantis":
+			w.Write([]byte(`[]`))
+		default:
+			http.Error(w, "", http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	n := newNominatimGeocoder(server.URL + "/")
+	n.interval = 0
+	c, err := n.geocode("Wien, Austria")
+	assert.Nil(t, err)
+	assert.Equal(t, coordinates{48.2083537, 16.3725042}, c)
+	_, err = n.geocode("Atlantis")
+	assert.Equal(t, errNotFound, err)
+	_, err = n.geocode("Linz")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "429")
+}
+
+func writeGazetteer(t *testing.T) string {
+	filename := filepath.Join(t.TempDir(), "gazetteer.csv")
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("name,lat,long\nGraz(Stadt),47.070714,15.439504\n\"Wien, Austria\",48.208354,16.372504\nAustria,47.516231,14.550072\n"), 0644))
+	return filename
+}
+
+func TestGazetteerGeocoder(t *testing.T) {
+	g, err := newGazetteerGeocoder(writeGazetteer(t))
+	assert.Nil(t, err)
+
+	c, err := g.geocode("Graz (Stadt), Steiermark, Austria")
+	assert.Nil(t, err)
+	assert.Equal(t, coordinates{47.070714, 15.439504}, c)
+	c, err = g.geocode("wien, austria")
+	assert.Nil(t, err)
+	assert.Equal(t, coordinates{48.208354, 16.372504}, c)
+	_, err = g.geocode("Linz(Stadt), Oberösterreich, Austria")
+	assert.Equal(t, errNotFound, err)
+
+	invalid := filepath.Join(t.TempDir(), "invalid.csv")
+	assert.Nil(t, ioutil.WriteFile(invalid, []byte("Graz,47.07,15.43\nLinz,north,east\n"), 0644))
+	_, err = newGazetteerGeocoder(invalid)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid.csv:2")
+}
+
+type countingGeocoder struct {
+	calls int
+}
+
+func (c *countingGeocoder) name() string {
+	return "counting"
+}
+
+func (c *countingGeocoder) geocode(query string) (coordinates, error) {
+	c.calls++
+	if query == "Atlantis" {
+		return coordinates{}, errNotFound
+	}
+	return coordinates{47.0, 15.0}, nil
+}
+
+func TestCachedGeocoder(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	counting := &countingGeocoder{}
+	cached, err := newCachedGeocoder(counting, filename)
+	assert.Nil(t, err)
+	for i := 0; i < 2; i++ {
+		c, err := cached.geocode("Graz")
+		assert.Nil(t, err)
+		assert.Equal(t, coordinates{47.0, 15.0}, c)
+		_, err = cached.geocode("Atlantis")
+		assert.True(t, errors.Is(err, errNotFound))
+	}
+	assert.Equal(t, 3, counting.calls)
+	assert.Nil(t, cached.save())
+
+	counting = &countingGeocoder{}
+	cached, err = newCachedGeocoder(counting, filename)
+	assert.Nil(t, err)
+	c, err := cached.geocode("Graz")
+	assert.Nil(t, err)
+	assert.Equal(t, coordinates{47.0, 15.0}, c)
+	assert.Equal(t, 0, counting.calls)
+}
@@ -1,82 +1,308 @@
 package main
 
 import (
+	_ "embed"
 	"encoding/csv"
-	"encoding/json"
-	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/url"
+	"io"
+	"math"
 	"os"
 	"strconv"
+	"strings"
 )
 
-type mapsResponse struct {
-	Candidates []struct {
-		Geometry struct {
-			Location struct {
-				Lat float64
-				Lng float64
+//bounds is the bounding box of a country in degrees, minLong is greater than maxLong if it crosses the antimeridian
+type bounds struct {
+	minLat, minLong, maxLat, maxLong float64
+}
+
+func (b bounds) contains(c coordinates) bool {
+	if c.Latitude < b.minLat || c.Latitude > b.maxLat {
+		return false
+	}
+	if b.minLong > b.maxLong {
+		return c.Longitude >= b.minLong || c.Longitude <= b.maxLong
+	}
+	return c.Longitude >= b.minLong && c.Longitude <= b.maxLong
+}
+
+//bundledBounds are the bounding boxes of all countries of metadata.csv
+//go:embed bounds.csv
+var bundledBounds string
+
+//countryBounds are the bounding boxes of the countries, -bounds adds more countries
+var countryBounds = map[string]bounds{}
+
+//austrianProvinces are the provinces in metadata.csv, they are checked against the bounds of Austria
+var austrianProvinces = []string{"Burgenland", "Kärnten", "Niederösterreich", "Oberösterreich", "Salzburg", "Steiermark", "Tirol", "Vorarlberg", "Wien"}
+
+func init() {
+	if err := readBounds(strings.NewReader(bundledBounds), "bounds.csv"); err != nil {
+		panic(err)
+	}
+}
+
+//readBounds reads a csv file with the columns country, min latitude, min longitude, max latitude and max longitude
+func readBounds(r io.Reader, filename string) error {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+	for i, row := range records {
+		if len(row) != 5 {
+			return fmt.Errorf("%s:%d: expected country, min latitude, min longitude, max latitude and max longitude", filename, i+1)
+		}
+		values := make([]float64, 4)
+		for j := range values {
+			if values[j], err = strconv.ParseFloat(strings.TrimSpace(row[j+1]), 64); err != nil {
+				return fmt.Errorf("%s:%d: %s", filename, i+1, err)
 			}
 		}
+		countryBounds[row[0]] = bounds{values[0], values[1], values[2], values[3]}
 	}
+	return nil
 }
 
-type mapLocation struct {
-	latitude  float64
-	longitude float64
+func loadBounds(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return readBounds(file, filename)
 }
 
-func getLocation(location string, key string) (*mapLocation, error) {
-	response, err := http.Get(fmt.Sprintf("https://maps.googleapis.com/maps/api/place/findplacefromtext/json?input=%s&inputtype=textquery&&fields=geometry&key=%s", url.QueryEscape(location), key))
-	if err != nil {
-		return nil, err
+//distance returns the great-circle distance in km
+func distance(a coordinates, b coordinates) float64 {
+	const earthRadius = 6371.0
+	rad := math.Pi / 180
+	dLat := (b.Latitude - a.Latitude) * rad
+	dLong := (b.Longitude - a.Longitude) * rad
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(a.Latitude*rad)*math.Cos(b.Latitude*rad)*math.Pow(math.Sin(dLong/2), 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+func ftos(f float64) string {
+	return strconv.FormatFloat(f, 'f', 6, 64)
+}
+
+//place is a row of bezirke.csv (name, population, latitude, longitude, gkz, province) or
+//metadata.csv (name, population, latitude, longitude), the provinces of bezirke.csv have an empty province
+type place struct {
+	row      []string
+	current  coordinates
+	province string
+	austrian bool
+}
+
+func (p place) name() string {
+	return p.row[0]
+}
+
+//country returns the country the place has to be in, districts and provinces are in Austria and the other rows of
+//metadata.csv are countries themselves. It is empty if the bounds of the country are unknown.
+func (p place) country(override string) string {
+	if override != "" {
+		return override
+	}
+	if p.austrian {
+		return "Austria"
 	}
-	defer response.Body.Close()
+	for _, province := range austrianProvinces {
+		if p.name() == province {
+			return "Austria"
+		}
+	}
+	if _, ok := countryBounds[p.name()]; ok {
+		return p.name()
+	}
+	return ""
+}
+
+//query is the search text for the geocoder, e.g. "Graz(Stadt), Steiermark, Austria" or "Kärnten, Austria"
+func (p place) query(country string) string {
+	parts := []string{p.name()}
+	if p.province != "" {
+		parts = append(parts, p.province)
+	}
+	if country != "" && country != p.name() {
+		parts = append(parts, country)
+	}
+	return strings.Join(parts, ", ")
+}
 
-	bytes, err := ioutil.ReadAll(response.Body)
+func readPlaces(filename string) ([]place, error) {
+	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
-
-	result := mapsResponse{}
-	err = json.Unmarshal(bytes, &result)
+	defer file.Close()
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
 	if err != nil {
 		return nil, err
 	}
-
-	if len(result.Candidates) == 0 {
-		return nil, errors.New("Not enough candidates for " + location)
+	result := make([]place, 0, len(records))
+	for i, row := range records {
+		if len(row) < 4 {
+			return nil, fmt.Errorf("%s:%d: expected name, population, latitude and longitude", filename, i+1)
+		}
+		lat, latErr := strconv.ParseFloat(row[2], 64)
+		long, longErr := strconv.ParseFloat(row[3], 64)
+		if latErr != nil || longErr != nil {
+			return nil, fmt.Errorf("%s:%d: invalid coordinates", filename, i+1)
+		}
+		p := place{row: row, current: coordinates{lat, long}}
+		if len(row) > 5 {
+			p.province, p.austrian = row[5], true
+		}
+		result = append(result, p)
 	}
-
-	return &mapLocation{latitude: result.Candidates[0].Geometry.Location.Lat, longitude: result.Candidates[0].Geometry.Location.Lng}, nil
+	return result, nil
 }
 
-func ftos(f float64) string {
-	return strconv.FormatFloat(f, 'f', 6, 64)
+func writePlaces(filename string, places []place) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(file)
+	for _, p := range places {
+		w.Write(p.row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
 
-func main() {
-
-	args := os.Args[1:]
-	if len(args) < 1 {
-		panic("Commandline argument for google maps apikey required")
+func newGeocoder(name string, googleKey string, nominatimURL string, gazetteer string) (geocoder, error) {
+	switch name {
+	case "google":
+		return newGoogleGeocoder(googleKey)
+	case "nominatim":
+		return newNominatimGeocoder(nominatimURL), nil
+	case "gazetteer":
+		if gazetteer == "" {
+			return nil, fmt.Errorf("The gazetteer geocoder requires -gazetteer")
+		}
+		return newGazetteerGeocoder(gazetteer)
 	}
+	return nil, fmt.Errorf("Unknown geocoder %s, available: google, nominatim and gazetteer", name)
+}
 
-	apiKey := args[0]
+//run geocodes the places of a csv file and prints the differences to the file, -write updates the file.
+//Places that are not found, fail or whose stored or geocoded coordinates are outside of their country are reported
+//and make run fail unless -write replaces the stored coordinates. Places of a country without bounds are reported as
+//unchecked.
+func run(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("location", flag.ExitOnError)
+	geocoderName := flags.String("geocoder", "gazetteer", "Geocoder: google, nominatim or gazetteer")
+	googleKey := flags.String("google-key", os.Getenv("GOOGLE_MAPS_API_KEY"), "Google Maps API key (GOOGLE_MAPS_API_KEY)")
+	nominatimURL := flags.String("nominatim-url", "https://nominatim.openstreetmap.org", "Base url of the Nominatim instance")
+	gazetteer := flags.String("gazetteer", "", "Csv file with name, latitude and longitude for the offline gazetteer geocoder")
+	cache := flags.String("cache", "", "Json file that caches the geocoding results")
+	country := flags.String("country", "", "Country the places have to be in, by default Austria for districts and the place itself for countries")
+	boundsFile := flags.String("bounds", "", "Csv file with additional country bounding boxes: country, min latitude, min longitude, max latitude, max longitude")
+	tolerance := flags.Float64("tolerance", 5, "Differences up to this distance in km are ignored")
+	write := flags.Bool("write", false, "Update the coordinates in the file instead of printing the differences only")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: location [flags] <bezirke.csv|metadata.csv>\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("location requires a csv file")
+	}
+	filename := flags.Arg(0)
 
-	csvFile, _ := os.Open("bezirke.csv")
-	r := csv.NewReader(csvFile)
-	records, _ := r.ReadAll()
+	if *boundsFile != "" {
+		if err := loadBounds(*boundsFile); err != nil {
+			return err
+		}
+	}
+	if *country != "" {
+		if _, ok := countryBounds[*country]; !ok {
+			return fmt.Errorf("No bounding box for %s, add it with -bounds", *country)
+		}
+	}
+	g, err := newGeocoder(*geocoderName, *googleKey, *nominatimURL, *gazetteer)
+	if err != nil {
+		return err
+	}
+	var cached *cachedGeocoder
+	if *cache != "" {
+		if cached, err = newCachedGeocoder(g, *cache); err != nil {
+			return err
+		}
+		g = cached
+	}
+	places, err := readPlaces(filename)
+	if err != nil {
+		return err
+	}
 
-	for _, r := range records {
-		location := r[0]
-		loc, err := getLocation(location, apiKey)
+	problems, changes, unchecked := 0, 0, 0
+	for i, p := range places {
+		c := p.country(*country)
+		b, checked := countryBounds[c]
+		outside := false
+		if !checked {
+			unchecked++
+			fmt.Fprintf(stdout, "- %s: unchecked, no bounding box for %s, add it with -bounds\n", p.name(), p.name())
+		} else if outside = !b.contains(p.current); outside {
+			fmt.Fprintf(stdout, "! %s: %s,%s in %s is outside of %s\n", p.name(), ftos(p.current.Latitude), ftos(p.current.Longitude), filename, c)
+		}
+		result, err := g.geocode(p.query(c))
 		if err != nil {
-			fmt.Println(err.Error())
+			problems++
+			fmt.Fprintf(stdout, "? %s: %s\n", p.name(), err)
+			continue
+		}
+		if checked && !b.contains(result) {
+			problems++
+			fmt.Fprintf(stdout, "! %s: %s,%s of %s is outside of %s\n", p.name(), ftos(result.Latitude), ftos(result.Longitude), g.name(), c)
+			continue
+		}
+		if d := distance(p.current, result); d > *tolerance {
+			changes++
+			fmt.Fprintf(stdout, "~ %s: %s,%s -> %s,%s (%.1f km)\n", p.name(), ftos(p.current.Latitude), ftos(p.current.Longitude), ftos(result.Latitude), ftos(result.Longitude), d)
+			places[i].row[2], places[i].row[3] = ftos(result.Latitude), ftos(result.Longitude)
+			outside = outside && !*write
+		}
+		if outside {
+			problems++
 		}
-		fmt.Println(fmt.Sprintf("%s,%s,%f,%f", location, r[1], loc.latitude, loc.longitude))
 	}
 
+	if cached != nil {
+		if err := cached.save(); err != nil {
+			return err
+		}
+	}
+	if *write && changes > 0 {
+		if err := writePlaces(filename, places); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Updated %d places in %s\n", changes, filename)
+	}
+	if unchecked > 0 {
+		fmt.Fprintf(stdout, "%d of %d places were not checked against the bounds of their country\n", unchecked, len(places))
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d of %d places could not be geocoded or are outside of their country", problems, len(places))
+	}
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	wien, graz := coordinates{48.208354, 16.372504}, coordinates{47.070714, 15.439504}
+	assert.InDelta(t, 144.8, distance(wien, graz), 0.5)
+	assert.Equal(t, 0.0, distance(wien, wien))
+}
+
+func TestPlaceQuery(t *testing.T) {
+	district := place{row: []string{"Graz(Stadt)", "291072", "47.07", "15.43", "601", "Steiermark"}, province: "Steiermark", austrian: true}
+	assert.Equal(t, "Austria", district.country(""))
+	assert.Equal(t, "Graz(Stadt), Steiermark, Austria", district.query(district.country("")))
+
+	province := place{row: []string{"Kärnten", "560900", "46.66", "14.14", "2", ""}, austrian: true}
+	assert.Equal(t, "Austria", province.country(""))
+	assert.Equal(t, "Kärnten, Austria", province.query(province.country("")))
+
+	country := place{row: []string{"Germany", "83019213", "51.16", "10.45"}}
+	assert.Equal(t, "Germany", country.country(""))
+	assert.Equal(t, "Germany", country.query("Germany"))
+	assert.Equal(t, "Seychelles", place{row: []string{"Seychelles", "94677", "-4.67", "55.49"}}.country(""))
+	assert.Equal(t, "", place{row: []string{"Cases on an international conveyance Japan", "3711", "34.22", "139.11"}}.country(""))
+
+	metadataProvince := place{row: []string{"Tirol", "751200", "47.26", "11.40"}}
+	assert.Equal(t, "Austria", metadataProvince.country(""))
+	assert.Equal(t, "Tirol, Austria", metadataProvince.query(metadataProvince.country("")))
+}
+
+func TestBoundsContains(t *testing.T) {
+	fiji := countryBounds["Fiji"]
+	assert.True(t, fiji.contains(coordinates{-16.57, 179.41}))
+	assert.True(t, fiji.contains(coordinates{-18.06, -178.5}))
+	assert.False(t, fiji.contains(coordinates{-18.06, 0}))
+	assert.False(t, countryBounds["Austria"].contains(coordinates{-4.67, 55.49}))
+}
+
+//TestBundledPlacesInBounds checks that the places of the repository are within the bounds of their country
+func TestBundledPlacesInBounds(t *testing.T) {
+	for _, filename := range []string{"../../metadata.csv", "../../bezirke.csv"} {
+		places, err := readPlaces(filename)
+		assert.Nil(t, err)
+		for _, p := range places {
+			c := p.country("")
+			if b, ok := countryBounds[c]; ok {
+				assert.True(t, b.contains(p.current), "%s of %s is outside of %s", p.name(), filename, c)
+			} else {
+				assert.Equal(t, "Cases on an international conveyance Japan", p.name())
+			}
+		}
+	}
+}
+
+func writeDistricts(t *testing.T) string {
+	filename := filepath.Join(t.TempDir(), "bezirke.csv")
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("Graz(Stadt),291072,47.070714,15.439504,601,Steiermark\n"+
+		"Wien(Stadt),1911191,47.800000,13.040000,900,Wien\n"+
+		"Linz(Stadt),206595,48.306940,14.285830,401,Oberösterreich\n"), 0644))
+	return filename
+}
+
+func TestRunDryRun(t *testing.T) {
+	gazetteer := filepath.Join(t.TempDir(), "gazetteer.csv")
+	assert.Nil(t, ioutil.WriteFile(gazetteer, []byte("Graz(Stadt),47.071,15.44\nWien(Stadt),48.208354,16.372504\nLinz(Stadt),-4.67,55.49\n"), 0644))
+	filename := writeDistricts(t)
+	before, _ := ioutil.ReadFile(filename)
+
+	out := &bytes.Buffer{}
+	err := run([]string{"-gazetteer", gazetteer, filename}, out)
+	assert.EqualError(t, err, "1 of 3 places could not be geocoded or are outside of their country")
+	assert.Equal(t, "~ Wien(Stadt): 47.800000,13.040000 -> 48.208354,16.372504 (252.0 km)\n"+
+		"! Linz(Stadt): -4.670000,55.490000 of gazetteer is outside of Austria\n", out.String())
+	after, _ := ioutil.ReadFile(filename)
+	assert.Equal(t, string(before), string(after))
+}
+
+func TestRunWrite(t *testing.T) {
+	gazetteer := filepath.Join(t.TempDir(), "gazetteer.csv")
+	assert.Nil(t, ioutil.WriteFile(gazetteer, []byte("Graz(Stadt),47.071,15.44\nWien(Stadt),48.208354,16.372504\nLinz(Stadt),48.30694,14.28583\n"), 0644))
+	filename := writeDistricts(t)
+	cache := filepath.Join(t.TempDir(), "cache.json")
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, run([]string{"-gazetteer", gazetteer, "-cache", cache, "-write", filename}, out))
+	assert.Contains(t, out.String(), "Updated 1 places")
+	after, _ := ioutil.ReadFile(filename)
+	assert.Equal(t, "Graz(Stadt),291072,47.070714,15.439504,601,Steiermark\n"+
+		"Wien(Stadt),1911191,48.208354,16.372504,900,Wien\n"+
+		"Linz(Stadt),206595,48.306940,14.285830,401,Oberösterreich\n", string(after))
+	content, err := ioutil.ReadFile(cache)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "gazetteer:Wien(Stadt), Wien, Austria")
+
+	out.Reset()
+	assert.Nil(t, run([]string{"-gazetteer", gazetteer, filename}, out))
+	assert.Equal(t, "", out.String())
+}
+
+func TestRunUnchecked(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "metadata.csv")
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("Germany,83019213,51.165691,10.451526\nDiamond Princess,3711,34.226008,139.113517\n"), 0644))
+	gazetteer := filepath.Join(t.TempDir(), "gazetteer.csv")
+	assert.Nil(t, ioutil.WriteFile(gazetteer, []byte("Germany,51.165691,10.451526\nDiamond Princess,34.226008,139.113517\n"), 0644))
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, run([]string{"-gazetteer", gazetteer, filename}, out))
+	assert.Equal(t, "- Diamond Princess: unchecked, no bounding box for Diamond Princess, add it with -bounds\n"+
+		"1 of 2 places were not checked against the bounds of their country\n", out.String())
+
+	bounds := filepath.Join(t.TempDir(), "bounds.csv")
+	assert.Nil(t, ioutil.WriteFile(bounds, []byte("Diamond Princess,20,120,40,150\n"), 0644))
+	defer delete(countryBounds, "Diamond Princess")
+	out.Reset()
+	assert.Nil(t, run([]string{"-gazetteer", gazetteer, "-bounds", bounds, filename}, out))
+	assert.Equal(t, "", out.String())
+}
+
+func TestRunStoredOutside(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "metadata.csv")
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("Holy See,1000,41.902561,0.000000\nTirol,751200,47.269028,11.402994\n"), 0644))
+	gazetteer := filepath.Join(t.TempDir(), "gazetteer.csv")
+	assert.Nil(t, ioutil.WriteFile(gazetteer, []byte("Holy See,41.902916,12.453389\nTirol,47.269028,11.402994\n"), 0644))
+
+	out := &bytes.Buffer{}
+	assert.EqualError(t, run([]string{"-gazetteer", gazetteer, filename}, out), "1 of 2 places could not be geocoded or are outside of their country")
+	assert.Equal(t, "! Holy See: 41.902561,0.000000 in "+filename+" is outside of Holy See\n"+
+		"~ Holy See: 41.902561,0.000000 -> 41.902916,12.453389 (1029.7 km)\n", out.String())
+
+	out.Reset()
+	assert.Nil(t, run([]string{"-gazetteer", gazetteer, "-write", filename}, out))
+	assert.Contains(t, out.String(), "Updated 1 places")
+	out.Reset()
+	assert.Nil(t, run([]string{"-gazetteer", gazetteer, filename}, out))
+	assert.Equal(t, "", out.String())
+}
+
+func TestRunErrors(t *testing.T) {
+	filename := writeDistricts(t)
+	assert.NotNil(t, run([]string{filename}, &bytes.Buffer{}))
+	assert.NotNil(t, run([]string{"-geocoder", "bing", filename}, &bytes.Buffer{}))
+	assert.NotNil(t, run([]string{"-geocoder", "google", "-google-key", "", filename}, &bytes.Buffer{}))
+	assert.NotNil(t, run([]string{"-gazetteer", filename, "-country", "Atlantis", filename}, &bytes.Buffer{}))
+	assert.NotNil(t, run([]string{"-gazetteer", filename, "missing.csv"}, &bytes.Buffer{}))
+}
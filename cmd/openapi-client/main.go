@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+//schema is the subset of the OpenAPI schema object used by the api
+type schema struct {
+	Ref                  string `json:"$ref"`
+	Type                 string
+	Format               string
+	Description          string
+	Minimum              *float64
+	Nullable             bool
+	Items                *schema
+	AdditionalProperties json.RawMessage
+	Properties           orderedSchemas
+	Required             []string
+}
+
+type namedSchema struct {
+	name   string
+	schema schema
+}
+
+//orderedSchemas keeps the order of the json object so the generated code follows the spec
+type orderedSchemas []namedSchema
+
+func (o *orderedSchemas) UnmarshalJSON(content []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		s := schema{}
+		if err := decoder.Decode(&s); err != nil {
+			return err
+		}
+		*o = append(*o, namedSchema{key.(string), s})
+	}
+	return nil
+}
+
+type spec struct {
+	Components struct {
+		Schemas orderedSchemas
+	}
+}
+
+var initialisms = map[string]string{"id": "ID", "gkz": "GKZ", "pcr": "PCR", "url": "URL"}
+
+//exported converts a schema or property name like infected_per_100k to a go name like InfectedPer100k
+func exported(name string) string {
+	result := ""
+	for _, part := range strings.Split(name, "_") {
+		if initialism, ok := initialisms[part]; ok {
+			result += initialism
+		} else if part != "" {
+			result += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return result
+}
+
+func goType(s schema) (string, error) {
+	if s.Nullable {
+		t, err := goType(schema{Ref: s.Ref, Type: s.Type, Format: s.Format, Minimum: s.Minimum, Items: s.Items, AdditionalProperties: s.AdditionalProperties})
+		return "*" + t, err
+	}
+	if s.Ref != "" {
+		return exported(s.Ref[strings.LastIndex(s.Ref, "/")+1:]), nil
+	}
+	switch s.Type {
+	case "":
+		//a schema without type accepts any value
+		return "interface{}", nil
+	case "integer":
+		if s.Minimum != nil && *s.Minimum >= 0 {
+			return "uint64", nil
+		}
+		return "int64", nil
+	case "number":
+		return "float64", nil
+	case "string":
+		if s.Format == "date-time" {
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("Array without items")
+		}
+		item, err := goType(*s.Items)
+		return "[]" + item, err
+	case "object":
+		if len(s.AdditionalProperties) == 0 || string(s.AdditionalProperties) == "true" || string(s.AdditionalProperties) == "false" {
+			return "map[string]interface{}", nil
+		}
+		value := schema{}
+		if err := json.Unmarshal(s.AdditionalProperties, &value); err != nil {
+			return "", err
+		}
+		valueType, err := goType(value)
+		return "map[string]" + valueType, err
+	}
+	return "", fmt.Errorf("Unsupported type %s", s.Type)
+}
+
+//generate returns the go source of the component schemas of the spec
+func generate(content []byte, pkg string) ([]byte, error) {
+	s := spec{}
+	if err := json.Unmarshal(content, &s); err != nil {
+		return nil, err
+	}
+	out := &bytes.Buffer{}
+	schemas := append(orderedSchemas{}, s.Components.Schemas...)
+	sort.SliceStable(schemas, func(i, j int) bool { return schemas[i].name < schemas[j].name })
+	for _, named := range schemas {
+		if named.schema.Type != "object" || len(named.schema.Properties) == 0 {
+			return nil, fmt.Errorf("Schema %s is not an object with properties", named.name)
+		}
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "//%s defines the %s schema\n", exported(named.name), named.name)
+		if named.schema.Description != "" {
+			fmt.Fprintf(out, "//%s\n", named.schema.Description)
+		}
+		fmt.Fprintf(out, "type %s struct {\n", exported(named.name))
+		for _, p := range named.schema.Properties {
+			t, err := goType(p.schema)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %s", named.name, p.name, err.Error())
+			}
+			if p.schema.Description != "" {
+				fmt.Fprintf(out, "//%s\n", p.schema.Description)
+			}
+			fmt.Fprintf(out, "%s %s `json:\"%s\"`\n", exported(p.name), t, p.name)
+		}
+		fmt.Fprintln(out, "}")
+	}
+	header := fmt.Sprintf("// Code generated by go run ./cmd/openapi-client; DO NOT EDIT.\n\npackage %s\n", pkg)
+	if bytes.Contains(out.Bytes(), []byte("time.Time `")) {
+		header += "\nimport \"time\"\n"
+	}
+	return format.Source(append([]byte(header), out.Bytes()...))
+}
+
+func main() {
+	specFile := flag.String("spec", "openapi.json", "OpenAPI specification")
+	outFile := flag.String("out", "client/types.go", "Generated go file")
+	pkg := flag.String("package", "client", "Package of the generated file")
+	flag.Parse()
+
+	content, err := ioutil.ReadFile(*specFile)
+	if err != nil {
+		panic(err)
+	}
+	source, err := generate(content, *pkg)
+	if err != nil {
+		panic(err)
+	}
+	err = ioutil.WriteFile(*outFile, source, 0644)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratedClientIsUpToDate(t *testing.T) {
+	spec, err := ioutil.ReadFile("../../openapi.json")
+	assert.Nil(t, err)
+	expected, err := generate(spec, "client")
+	assert.Nil(t, err)
+	actual, err := ioutil.ReadFile("../../client/types.go")
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), string(actual), "run go generate ./client")
+}
+
+func TestExported(t *testing.T) {
+	assert.Equal(t, "InfectedPer100k", exported("infected_per_100k"))
+	assert.Equal(t, "ProvinceID", exported("province_id"))
+	assert.Equal(t, "TestsPCR", exported("TestsPCR"))
+	assert.Equal(t, "Positivity7dPercent", exported("positivity_7d_percent"))
+}
+
+func TestGoType(t *testing.T) {
+	zero := 0.0
+	for expected, s := range map[string]schema{
+		"uint64":                 {Type: "integer", Minimum: &zero},
+		"int64":                  {Type: "integer"},
+		"*float64":               {Type: "number", Nullable: true},
+		"[]Bezirk":               {Type: "array", Items: &schema{Ref: "#/components/schemas/Bezirk"}},
+		"map[string]string":      {Type: "object", AdditionalProperties: []byte(`{"type":"string"}`)},
+		"map[string]interface{}": {Type: "object"},
+		"[]interface{}":          {Type: "array", Items: &schema{}},
+	} {
+		actual, err := goType(s)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, actual)
+	}
+	_, err := goType(schema{Type: "array"})
+	assert.NotNil(t, err)
+	actual, err := goType(schema{Type: "string", Format: "date-time"})
+	assert.Nil(t, err)
+	assert.Equal(t, "time.Time", actual)
+	actual, err = goType(schema{Type: "string", Format: "date-time", Nullable: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "*time.Time", actual)
+	_, err = goType(schema{Type: "null"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+//metadataUse is a label whose values an exporter looks up in a metadata csv
+type metadataUse struct {
+	filename string
+	label    string
+}
+
+//metadataUses returns the metadata lookups of an exporter, mathdro has its own coordinates
+func metadataUses(e Exporter) []metadataUse {
+	switch x := e.(type) {
+	case *ecdcExporter:
+		return []metadataUse{{"metadata.csv", "country"}}
+	case *healthMinistryExporter:
+		return []metadataUse{{"bezirke.csv", "bezirk"}, {"bezirke.csv", "province"}}
+	case *vaccinationExporter, *hospitalExporter, *testingsExporter:
+		return []metadataUse{{"bezirke.csv", "province"}}
+	case *declarativeExporter:
+		if x.config.Metadata != "" && x.config.Location != "" {
+			return []metadataUse{{x.config.Metadata, x.config.Location}}
+		}
+	}
+	return nil
+}
+
+//missingName is a region name of the exporters without a row in a metadata csv, Match is the most similar row
+type missingName struct {
+	Filename   string   `json:"filename"`
+	Name       string   `json:"name"`
+	Label      string   `json:"label"`
+	Exporters  []string `json:"exporters"`
+	Match      string   `json:"match,omitempty"`
+	Similarity float64  `json:"similarity,omitempty"`
+}
+
+//levenshtein returns the edit distance of two strings
+func levenshtein(a string, b string) int {
+	s, t := []rune(a), []rune(b)
+	previous, current := make([]int, len(t)+1), make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(t)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+//similarity compares the normalizeName output of two names, 1 is equal and 0 completely different
+func similarity(a string, b string) float64 {
+	x, y := normalizeName(a), normalizeName(b)
+	length := len(x)
+	if len(y) > length {
+		length = len(y)
+	}
+	if length == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(x, y))/float64(length)
+}
+
+//closestName returns the name of the metadata row that is most similar to name
+func (l *metadataProvider) closestName(name string) (string, float64) {
+	best, bestSimilarity := "", 0.0
+	for _, m := range l.data {
+		s := similarity(name, m.country)
+		if s > bestSimilarity || (s == bestSimilarity && m.country < best) {
+			best, bestSimilarity = m.country, s
+		}
+	}
+	return best, bestSimilarity
+}
+
+//findMissingNames runs the exporters and returns the label values that are missing in their metadata files with
+//the closest row if its similarity is at least minSimilarity. Exporters that fail or are unhealthy are returned as
+//errors.
+func findMissingNames(exporters []Exporter, minSimilarity float64) ([]missingName, []error) {
+	providers := make(map[string]*metadataProvider)
+	missing := make(map[string]*missingName)
+	errs := make([]error, 0)
+	for _, e := range exporters {
+		uses := metadataUses(e)
+		if len(uses) == 0 {
+			continue
+		}
+		result, err := e.GetMetrics()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", exporterName(e), err))
+			continue
+		}
+		//GetMetrics skips the files that fail for some exporters, the names of the other files are still checked
+		if health := e.Health(); len(health) > 0 {
+			messages := make([]string, 0, len(health))
+			for _, err := range health {
+				messages = append(messages, err.Error())
+			}
+			errs = append(errs, fmt.Errorf("%s: %s", exporterName(e), strings.Join(messages, "; ")))
+		}
+		for _, use := range uses {
+			provider, ok := providers[use.filename]
+			if !ok {
+				if provider = newMetadataProviderWithFilename(use.filename); provider == nil {
+					errs = append(errs, fmt.Errorf("Could not load metadata %s", use.filename))
+				}
+				providers[use.filename] = provider
+			}
+			if provider == nil {
+				continue
+			}
+			for _, m := range result {
+				if m.Tags == nil {
+					continue
+				}
+				name := (*m.Tags)[use.label]
+				if name == "" || provider.getMetadata(name) != nil {
+					continue
+				}
+				key := use.filename + "\x00" + normalizeName(name)
+				if _, ok := missing[key]; !ok {
+					missing[key] = &missingName{Filename: use.filename, Name: name, Label: use.label}
+					if match, s := provider.closestName(name); s >= minSimilarity {
+						missing[key].Match, missing[key].Similarity = match, s
+					}
+				}
+				if !contains(missing[key].Exporters, exporterName(e)) {
+					missing[key].Exporters = append(missing[key].Exporters, exporterName(e))
+				}
+			}
+		}
+	}
+
+	result := make([]missingName, 0, len(missing))
+	for _, m := range missing {
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Filename != result[j].Filename {
+			return result[i].Filename < result[j].Filename
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, errs
+}
+
+//addMetadataAliases appends a copy of the matched row with the missing name for every missing name with a match.
+//The id column is left empty, a GKZ belongs to a single row.
+func addMetadataAliases(filename string, missing []missingName) (int, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	file.Close()
+	if err != nil {
+		return 0, err
+	}
+	rows := make(map[string][]string, len(records))
+	for _, row := range records {
+		rows[row[0]] = row
+	}
+	added := 0
+	for _, m := range missing {
+		row, ok := rows[m.Match]
+		if m.Filename != filename || !ok {
+			continue
+		}
+		alias := append([]string{m.Name}, row[1:]...)
+		if len(alias) > 4 {
+			alias[4] = ""
+		}
+		records = append(records, alias)
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+
+	file, err = os.Create(filename)
+	if err != nil {
+		return 0, err
+	}
+	w := csv.NewWriter(file)
+	w.WriteAll(records)
+	if err := w.Error(); err != nil {
+		file.Close()
+		return 0, err
+	}
+	return added, file.Close()
+}
+
+//runMetadata is the metadata subcommand, it lists the region names of the exporters that are missing in
+//metadata.csv, bezirke.csv or the metadata of sources.yml with proposals, -write adds the proposals as rows
+func runMetadata(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("metadata", flag.ExitOnError)
+	applySources := sourceFlags(flags)
+	exporterNames := flags.String("exporter", "", "Comma separated exporters to check, all by default")
+	minSimilarity := flags.Float64("similarity", 0.75, "Minimum similarity (0-1) of the normalized names for a proposal")
+	format := flags.String("format", "text", "Output format: text or json")
+	write := flags.Bool("write", false, "Add the proposals as rows with the missing name to the metadata files")
+	flags.Parse(args)
+	if err := applySources(); err != nil {
+		return err
+	}
+	selected, err := selectExporters(splitList([]string{*exporterNames}))
+	if err != nil {
+		return err
+	}
+
+	missing, errs := findMissingNames(selected, *minSimilarity)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	switch *format {
+	case "json":
+		if err := writeFormatted(stdout, "json", missing); err != nil {
+			return err
+		}
+	case "text":
+		for _, m := range missing {
+			line := fmt.Sprintf("%s: %s %q (%s)", m.Filename, m.Label, m.Name, strings.Join(m.Exporters, ", "))
+			if m.Match != "" {
+				line += fmt.Sprintf(" -> %q (%.0f%%)", m.Match, m.Similarity*100)
+			}
+			fmt.Fprintln(stdout, line)
+		}
+	default:
+		return fmt.Errorf("Unknown format %s", *format)
+	}
+
+	unresolved := len(missing)
+	if *write {
+		filenames := make([]string, 0)
+		for _, m := range missing {
+			if !contains(filenames, m.Filename) {
+				filenames = append(filenames, m.Filename)
+			}
+		}
+		for _, filename := range filenames {
+			added, err := addMetadataAliases(filename, missing)
+			if err != nil {
+				return err
+			}
+			unresolved -= added
+			if added > 0 {
+				fmt.Fprintf(os.Stderr, "Added %d rows to %s\n", added, filename)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d exporters or metadata files failed", len(errs))
+	}
+	if unresolved > 0 {
+		return fmt.Errorf("%d names are missing in the metadata", unresolved)
+	}
+	return nil
+}
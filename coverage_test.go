@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("GRAZ", "GRAZ"))
+	assert.Equal(t, 3, levenshtein("KITTEN", "SITTING"))
+	assert.Equal(t, 1.0, similarity("Graz (Stadt)", "graz(stadt)"))
+	assert.InDelta(t, 0.9, similarity("Cote d'Ivoire", "Côte d’Ivoire"), 0.01)
+	assert.Equal(t, 0.0, similarity("", ""))
+
+	name, s := mp.closestName("Bosnia and Herzegowina")
+	assert.Equal(t, "Bosnia and Herzegovina", name)
+	assert.True(t, s > 0.9)
+}
+
+//newMetadataTestSource is a declarative source with a district that is misspelled and one that does not exist
+func newMetadataTestSource(t *testing.T) (*declarativeExporter, string, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Bezirk;AnzahlFaelle\nGraz(Stadt);340\nGraz Umgebung;120\nSankt Pölten(Stadt);80\nAtlantis;7\n"))
+	}))
+	metadata := filepath.Join(t.TempDir(), "bezirke.csv")
+	assert.Nil(t, ioutil.WriteFile(metadata, []byte("Graz(Stadt),288806,47.070714,15.439504,601,Steiermark\n"+
+		"Graz-Umgebung,157030,47.133333,15.433333,606,Steiermark\n"+
+		"St. Pölten(Stadt),54649,48.203530,15.638170,302,Niederösterreich\n"), 0644))
+	d, err := newDeclarativeExporter(sourceConfig{
+		Name:     "districts",
+		URL:      server.URL + "/bezirke.csv",
+		Format:   "csv",
+		CSV:      csvConfig{Delimiter: ";"},
+		Metrics:  map[string]string{"test_infected": "AnzahlFaelle"},
+		Labels:   map[string]string{"bezirk": "Bezirk"},
+		Metadata: metadata,
+		Location: "bezirk",
+	})
+	assert.Nil(t, err)
+	return d, metadata, server.Close
+}
+
+func TestFindMissingNames(t *testing.T) {
+	d, metadata, cleanup := newMetadataTestSource(t)
+	defer cleanup()
+
+	missing, errs := findMissingNames([]Exporter{d, &staticExporter{metrics{{"cov19_confirmed", nil, 1}}}}, 0.75)
+	assert.Empty(t, errs)
+	assert.Equal(t, []missingName{
+		{Filename: metadata, Name: "Atlantis", Label: "bezirk", Exporters: []string{"districts"}},
+		{Filename: metadata, Name: "Sankt Pölten(Stadt)", Label: "bezirk", Exporters: []string{"districts"}, Match: "St. Pölten(Stadt)", Similarity: 0.8},
+	}, missing)
+}
+
+func TestFindMissingNamesOfUnhealthyExporter(t *testing.T) {
+	d, _, cleanup := newMetadataTestSource(t)
+	defer cleanup()
+
+	d.config.Min = 10
+	missing, errs := findMissingNames([]Exporter{d}, 0.75)
+	assert.Equal(t, 2, len(missing))
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, "districts: districts: Not enough results: 4", errs[0].Error())
+}
+
+func TestMetadataCommand(t *testing.T) {
+	d, metadata, cleanupSource := newMetadataTestSource(t)
+	defer cleanupSource()
+	url, cleanup := withTestSources(t, d)
+	defer cleanup()
+
+	output := bytes.Buffer{}
+	err := runMetadata([]string{"-ministry-url", url, "-exporter", "districts"}, &output)
+	assert.EqualError(t, err, "2 names are missing in the metadata")
+	assert.Equal(t, metadata+": bezirk \"Atlantis\" (districts)\n"+
+		metadata+": bezirk \"Sankt Pölten(Stadt)\" (districts) -> \"St. Pölten(Stadt)\" (80%)\n", output.String())
+
+	output.Reset()
+	assert.NotNil(t, runMetadata([]string{"-ministry-url", url, "-exporter", "districts", "-format", "json"}, &output))
+	result := []missingName{}
+	assert.Nil(t, json.Unmarshal(output.Bytes(), &result))
+	assert.Equal(t, 2, len(result))
+
+	output.Reset()
+	err = runMetadata([]string{"-ministry-url", url, "-exporter", "districts", "-write"}, &output)
+	assert.EqualError(t, err, "1 names are missing in the metadata")
+	content, err := ioutil.ReadFile(metadata)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "\nSankt Pölten(Stadt),54649,48.203530,15.638170,,Niederösterreich\n")
+	assert.Equal(t, 1, strings.Count(string(content), ",302,"))
+
+	output.Reset()
+	assert.NotNil(t, runMetadata([]string{"-ministry-url", url, "-exporter", "districts"}, &output))
+	assert.Equal(t, metadata+": bezirk \"Atlantis\" (districts)\n", output.String())
+
+	//the mirror only has some files of the health ministry, GetMetrics skips the others
+	output.Reset()
+	err = runMetadata([]string{"-ministry-url", url, "-exporter", "healthMinistry"}, &output)
+	assert.EqualError(t, err, "1 exporters or metadata files failed")
+	assert.Equal(t, "", output.String())
+}
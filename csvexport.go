@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type csvCell struct {
+	name  []csvName
+	value string
+}
+
+//csvName is a part of a column name, either a struct field or a map key
+type csvName struct {
+	text string
+	key  bool
+}
+
+var germanHeaders = map[string]string{
+	"Name":                       "Name",
+	"Location":                   "Standort",
+	"Lat":                        "Breitengrad",
+	"Long":                       "Längengrad",
+	"Population":                 "Einwohner",
+	"Infected":                   "Infizierte",
+	"Dead":                       "Verstorbene",
+	"Hospitalized":               "Hospitalisiert",
+	"IntensiveCare":              "Intensivstation",
+	"Healed":                     "Genesen",
+	"Vaccination":                "Impfung",
+	"Doses":                      "Impfdosen",
+	"FirstDose":                  "Erststich",
+	"SecondDose":                 "Zweitstich",
+	"BoosterDose":                "Auffrischung",
+	"FirstDosePercent":           "Erststich Prozent",
+	"SecondDosePercent":          "Zweitstich Prozent",
+	"BoosterDosePercent":         "Auffrischung Prozent",
+	"HospitalFree":               "Freie Normalbetten",
+	"IntensiveCareFree":          "Freie Intensivbetten",
+	"HospitalUtilisation":        "Auslastung Normalbetten",
+	"IntensiveCareUtilisation":   "Auslastung Intensivbetten",
+	"Tests":                      "Testungen",
+	"TestsPCR":                   "PCR-Testungen",
+	"TestsAntigen":               "Antigen-Testungen",
+	"TestsDaily":                 "Testungen pro Tag",
+	"TestPositivity":             "Positivrate",
+	"TotalInfected":              "Infizierte gesamt",
+	"TotalDead":                  "Verstorbene gesamt",
+	"TotalHospitalized":          "Hospitalisiert gesamt",
+	"TotalIntensiveCare":         "Intensivstation gesamt",
+	"TotalTests":                 "Testungen gesamt",
+	"TotalTestsPCR":              "PCR-Testungen gesamt",
+	"TotalTestsAntigen":          "Antigen-Testungen gesamt",
+	"AgeDistributionInfection":   "Altersverteilung Infektionen",
+	"AgeDistributionVaccination": "Altersverteilung Impfungen",
+	"Date":                       "Datum",
+	"Tags":                       "Merkmal",
+	"Value":                      "Wert",
+}
+
+//wantsCsv checks the format query parameter and the accept header
+func wantsCsv(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "csv"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+//writeResult writes the result as csv if requested, as json otherwise
+func writeResult(w http.ResponseWriter, r *http.Request, f func() (interface{}, error)) {
+	if !wantsCsv(r) {
+		writeJson(w, f)
+		return
+	}
+	result, err := f()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	query := r.URL.Query()
+	body := bytes.Buffer{}
+	if err := writeCsv(&body, result, query.Get("lang") == "de"); err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Add("Content-type", "text/csv; charset=utf-8; header=present")
+	if query.Get("bom") == "1" || query.Get("bom") == "true" {
+		w.Write([]byte("\ufeff"))
+	}
+	w.Write(body.Bytes())
+}
+
+//writeCsv writes a struct or a slice of structs as RFC 4180 csv, nested structs and maps are flattened into columns.
+//The header is written for empty slices too.
+func writeCsv(w io.Writer, result interface{}, german bool) error {
+	v := reflect.ValueOf(result)
+	if !v.IsValid() {
+		return errors.New("No result")
+	}
+	t, values := v.Type(), []reflect.Value{v}
+	if v.Kind() == reflect.Slice {
+		t, values = v.Type().Elem(), make([]reflect.Value, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, v.Index(i))
+		}
+	}
+
+	columns := csvSchema(t, values, nil)
+	headers := make([]string, len(columns))
+	index := make(map[string]int, len(columns))
+	for i, name := range columns {
+		headers[i] = csvHeader(name, german)
+		index[csvKey(name)] = i
+	}
+
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = true
+	writer.Write(headers)
+	for _, v := range values {
+		record := make([]string, len(columns))
+		for _, cell := range flattenCsv(v, nil, nil) {
+			//a nil pointer to a struct is a single cell without column
+			if i, ok := index[csvKey(cell.name)]; ok {
+				record[i] = cell.value
+			}
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+//csvSchema returns the columns of a type in field order. The columns of a map are the sorted keys of all values, so
+//the order does not depend on the order of the rows or on the keys of the first rows.
+func csvSchema(t reflect.Type, values []reflect.Value, name []csvName) [][]csvName {
+	switch t.Kind() {
+	case reflect.Ptr:
+		elements := make([]reflect.Value, 0, len(values))
+		for _, v := range values {
+			if !v.IsNil() {
+				elements = append(elements, v.Elem())
+			}
+		}
+		return csvSchema(t.Elem(), elements, name)
+	case reflect.Interface:
+		columns := make([][]csvName, 0)
+		seen := make(map[string]bool)
+		for _, v := range values {
+			if v.IsNil() {
+				continue
+			}
+			for _, c := range csvSchema(v.Elem().Type(), []reflect.Value{v.Elem()}, name) {
+				if !seen[csvKey(c)] {
+					seen[csvKey(c)] = true
+					columns = append(columns, c)
+				}
+			}
+		}
+		if len(columns) == 0 {
+			return [][]csvName{name}
+		}
+		return columns
+	case reflect.Struct:
+		columns := make([][]csvName, 0)
+		for i := 0; i < t.NumField(); i++ {
+			text := jsonFieldName(t.Field(i))
+			if text == "" {
+				continue
+			}
+			fields := make([]reflect.Value, len(values))
+			for j, v := range values {
+				fields[j] = v.Field(i)
+			}
+			columns = append(columns, csvSchema(t.Field(i).Type, fields, append(append([]csvName{}, name...), csvName{text, false}))...)
+		}
+		return columns
+	case reflect.Map:
+		keyValues := make(map[string][]reflect.Value)
+		for _, v := range values {
+			for _, k := range v.MapKeys() {
+				keyValues[k.String()] = append(keyValues[k.String()], v.MapIndex(k))
+			}
+		}
+		keys := make([]string, 0, len(keyValues))
+		for k := range keyValues {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		columns := make([][]csvName, 0)
+		for _, k := range keys {
+			columns = append(columns, csvSchema(t.Elem(), keyValues[k], append(append([]csvName{}, name...), csvName{k, true}))...)
+		}
+		return columns
+	default:
+		return [][]csvName{name}
+	}
+}
+
+func csvKey(name []csvName) string {
+	parts := make([]string, len(name))
+	for i, n := range name {
+		parts[i] = n.text
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func flattenCsv(v reflect.Value, name []csvName, cells []csvCell) []csvCell {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return append(cells, csvCell{name, ""})
+		}
+		return flattenCsv(v.Elem(), name, cells)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			text := jsonFieldName(v.Type().Field(i))
+			if text == "" {
+				continue
+			}
+			cells = flattenCsv(v.Field(i), append(append([]csvName{}, name...), csvName{text, false}), cells)
+		}
+		return cells
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+			values[k.String()] = v.MapIndex(k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			cells = flattenCsv(values[k], append(append([]csvName{}, name...), csvName{k, true}), cells)
+		}
+		return cells
+	case reflect.Float32, reflect.Float64:
+		return append(cells, csvCell{name, strconv.FormatFloat(v.Float(), 'f', -1, 64)})
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return append(cells, csvCell{name, strconv.FormatUint(v.Uint(), 10)})
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return append(cells, csvCell{name, strconv.FormatInt(v.Int(), 10)})
+	case reflect.Bool:
+		return append(cells, csvCell{name, strconv.FormatBool(v.Bool())})
+	default:
+		return append(cells, csvCell{name, v.String()})
+	}
+}
+
+func csvHeader(name []csvName, german bool) string {
+	parts := make([]string, len(name))
+	for i, n := range name {
+		parts[i] = n.text
+		if n.key {
+			continue
+		}
+		if !german {
+			parts[i] = snakeCase(n.text)
+		} else if translated, ok := germanHeaders[n.text]; ok {
+			parts[i] = translated
+		}
+	}
+	if german {
+		return strings.Join(parts, " ")
+	}
+	return strings.Join(parts, "_")
+}
+
+//snakeCase converts a go field name like IntensiveCare or TestsPCR to intensive_care or tests_pcr
+func snakeCase(s string) string {
+	runes := []rune(s)
+	result := make([]rune, 0, len(runes)+4)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				result = append(result, '_')
+			}
+			r = unicode.ToLower(r)
+		}
+		result = append(result, r)
+	}
+	return string(result)
+}
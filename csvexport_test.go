@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteCsv(t *testing.T) {
+	buffer := bytes.Buffer{}
+	stats := []bezirkStat{
+		{"Graz(Stadt)", apiLocaiton{47.07, 15.43}, 288806, 52},
+		{"Wien, Innere Stadt", apiLocaiton{48.2, 16.3}, 16306, 3},
+	}
+	assert.Nil(t, writeCsv(&buffer, stats, false))
+	assert.Equal(t, "name,location_lat,location_long,population,infected\r\n"+
+		"Graz(Stadt),47.07,15.43,288806,52\r\n"+
+		"\"Wien, Innere Stadt\",48.2,16.3,16306,3\r\n", buffer.String())
+
+	buffer.Reset()
+	assert.Nil(t, writeCsv(&buffer, stats, true))
+	assert.True(t, strings.HasPrefix(buffer.String(), "Name,Standort Breitengrad,Standort Längengrad,Einwohner,Infizierte\r\n"))
+
+	buffer.Reset()
+	assert.Nil(t, writeCsv(&buffer, []bezirkStat{}, false))
+	assert.Equal(t, "name,location_lat,location_long,population,infected\r\n", buffer.String())
+	assert.NotNil(t, writeCsv(&buffer, nil, false))
+}
+
+func TestWriteCsvMaps(t *testing.T) {
+	buffer := bytes.Buffer{}
+	points := []historyPoint{
+		{"2020-03-20", "cov19_detail", map[string]string{"province": "Wien", "country": "Austria"}, 40},
+		{"2020-03-20", "cov19_bezirk_infected", map[string]string{"bezirk": "Graz", "country": "Austria"}, 10},
+	}
+	assert.Nil(t, writeCsv(&buffer, points, false))
+	records, err := csv.NewReader(&buffer).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"date", "name", "tags_bezirk", "tags_country", "tags_province", "value"},
+		{"2020-03-20", "cov19_detail", "", "Austria", "Wien", "40"},
+		{"2020-03-20", "cov19_bezirk_infected", "Graz", "Austria", "", "10"},
+	}, records)
+
+	//the columns do not depend on the order of the rows
+	buffer.Reset()
+	assert.Nil(t, writeCsv(&buffer, []historyPoint{points[1], points[0]}, false))
+	records, err = csv.NewReader(&buffer).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"date", "name", "tags_bezirk", "tags_country", "tags_province", "value"}, records[0])
+
+	buffer.Reset()
+	assert.Nil(t, writeCsv(&buffer, overallStat{TotalInfected: 5, AgeDistributionInfection: map[string]uint64{"<5": 1, ">84": 2}}, false))
+	records, err = csv.NewReader(&buffer).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, "total_infected", records[0][0])
+	assert.Contains(t, records[0], "age_distribution_infection_<5")
+}
+
+func TestWriteResult(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, r, func() (interface{}, error) {
+			if r.URL.Query().Get("empty") != "" {
+				return nil, nil
+			}
+			return []bezirkStat{{"Mödling", apiLocaiton{48.08, 16.28}, 118998, 100}}, nil
+		})
+	}))
+	defer ts.Close()
+
+	response, err := ts.Client().Get(ts.URL + "?format=csv&bom=1&lang=de")
+	assert.Nil(t, err)
+	assert.Equal(t, "text/csv; charset=utf-8; header=present", response.Header.Get("Content-type"))
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.True(t, strings.HasPrefix(string(body), "\ufeffName,Standort Breitengrad"))
+	assert.True(t, strings.HasSuffix(string(body), "Mödling,48.08,16.28,118998,100\r\n"))
+
+	request, _ := http.NewRequest("GET", ts.URL, nil)
+	request.Header.Set("Accept", "text/csv")
+	response, err = ts.Client().Do(request)
+	assert.Nil(t, err)
+	body, _ = ioutil.ReadAll(response.Body)
+	assert.True(t, strings.HasPrefix(string(body), "name,location_lat"))
+
+	response, err = ts.Client().Get(ts.URL + "?format=csv&empty=1")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+
+	response, err = ts.Client().Get(ts.URL + "?format=json")
+	assert.Nil(t, err)
+	assert.Equal(t, "application/json; charset=utf-8", response.Header.Get("Content-type"))
+}
+
+func TestSnakeCase(t *testing.T) {
+	assert.Equal(t, "intensive_care", snakeCase("IntensiveCare"))
+	assert.Equal(t, "tests_pcr", snakeCase("TestsPCR"))
+	assert.Equal(t, "pcr_tests", snakeCase("PCRTests"))
+	assert.Equal(t, "name", snakeCase("Name"))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed impressum.html web
+var webFiles embed.FS
+
+var staticFiles, _ = fs.Sub(webFiles, "web/static")
+
+var dashboardTemplate = template.Must(template.New("index.html").Funcs(template.FuncMap{
+	"count":     formatCount,
+	"decimal":   formatDecimal,
+	"percent":   formatPercent,
+	"sparkline": sparkline,
+}).ParseFS(webFiles, "web/index.html"))
+
+//sparklineDays is the number of days shown in the sparklines
+const sparklineDays = 28
+
+type dashboardRegion struct {
+	Name          string
+	Population    uint64
+	Infected      uint64
+	Infected100k  float64
+	Incidence     float64
+	HasIncidence  bool
+	Dead          uint64
+	Hospitalized  uint64
+	IntensiveCare uint64
+	Vaccinated    float64
+	NewCases      []float64
+}
+
+type dashboard struct {
+	Date          time.Time
+	Total         overallStat
+	Austria       dashboardRegion
+	Bundeslaender []dashboardRegion
+	Bezirke       []dashboardRegion
+}
+
+//getDashboard combines the api stats with the 7-day incidence and the daily new cases from the history
+func (a *api) getDashboard(history *historyStore, date time.Time) (dashboard, error) {
+	total, err := a.GetOverallStat()
+	if err != nil {
+		return dashboard{}, err
+	}
+	bundeslaender, err := a.GetBundeslandStat()
+	if err != nil {
+		return dashboard{}, err
+	}
+	bezirke, err := a.GetBezirkStat()
+	if err != nil {
+		return dashboard{}, err
+	}
+
+	from := calendarDay(date).AddDate(0, 0, -sparklineDays)
+	national, err := history.byRegion("cov19_confirmed", "", from, date)
+	if err != nil {
+		return dashboard{}, err
+	}
+	provinces, err := history.byRegion("cov19_detail", "province", from, date)
+	if err != nil {
+		return dashboard{}, err
+	}
+	districts, err := history.byRegion("cov19_bezirk_infected", "bezirk", from, date)
+	if err != nil {
+		return dashboard{}, err
+	}
+
+	result := dashboard{Date: date, Total: total}
+	result.Austria = newDashboardRegion("Österreich", populationOf("Austria"), total.TotalInfected, national[""], date)
+	result.Austria.Dead = total.TotalDead
+	result.Austria.Hospitalized = total.TotalHospitalized
+	result.Austria.IntensiveCare = total.TotalIntensiveCare
+	result.Austria.Vaccinated = total.Vaccination.SecondDosePercent
+
+	for _, s := range bundeslaender {
+		r := newDashboardRegion(s.Name, s.Population, s.Infected, provinces[normalizeName(s.Name)], date)
+		r.Dead = s.Dead
+		r.Hospitalized = s.Hospitalized
+		r.IntensiveCare = s.IntensiveCare
+		r.Vaccinated = s.Vaccination.SecondDosePercent
+		result.Bundeslaender = append(result.Bundeslaender, r)
+	}
+	for _, s := range bezirke {
+		result.Bezirke = append(result.Bezirke, newDashboardRegion(s.Name, s.Population, s.Infected, districts[normalizeName(s.Name)], date))
+	}
+	sort.SliceStable(result.Bezirke, func(i, j int) bool {
+		if result.Bezirke[i].Incidence != result.Bezirke[j].Incidence {
+			return result.Bezirke[i].Incidence > result.Bezirke[j].Incidence
+		}
+		return result.Bezirke[i].Infected100k > result.Bezirke[j].Infected100k
+	})
+	return result, nil
+}
+
+func newDashboardRegion(name string, population uint64, infected uint64, points []historyPoint, date time.Time) dashboardRegion {
+	r := dashboardRegion{Name: name, Population: population, Infected: infected, Infected100k: geoInfection100k(infected, population)}
+	weekAgo := calendarDay(date).AddDate(0, 0, -7).Format(snapshotLayout)
+	for i, p := range points {
+		if p.Date == weekAgo && population > 0 {
+			r.Incidence = (float64(infected) - p.Value) / float64(population) * 100000
+			r.HasIncidence = true
+		}
+		if i > 0 {
+			r.NewCases = append(r.NewCases, math.Max(0, p.Value-points[i-1].Value))
+		}
+	}
+	return r
+}
+
+//formatCount formats a number with German thousands separators
+func formatCount(v uint64) string {
+	digits := strconv.FormatUint(v, 10)
+	result := strings.Builder{}
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			result.WriteRune('.')
+		}
+		result.WriteRune(d)
+	}
+	return result.String()
+}
+
+//formatDecimal formats a number with one decimal and German decimal comma
+func formatDecimal(v float64) string {
+	return strings.Replace(strconv.FormatFloat(v, 'f', 1, 64), ".", ",", 1)
+}
+
+//formatPercent formats a share between 0 and 1 as percent
+func formatPercent(ratio float64) string {
+	return formatDecimal(ratio * 100)
+}
+
+//sparkline draws the values as small inline SVG line
+func sparkline(values []float64) template.HTML {
+	if len(values) < 2 {
+		return ""
+	}
+	const width, height = 100.0, 24.0
+	max := 0.0
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	if max == 0 {
+		max = 1
+	}
+	points := make([]string, len(values))
+	for i, v := range values {
+		x := float64(i) / float64(len(values)-1) * width
+		y := height - 1 - v/max*(height-2)
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	return template.HTML(fmt.Sprintf(`<svg class="sparkline" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f"><polyline points="%s"/></svg>`,
+		width, height, width, height, strings.Join(points, " ")))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDashboardApi(t *testing.T) (*api, func()) {
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.Nil(t, err)
+	for name, content := range map[string]string{
+		"Bezirke.js":                     `var dpBezirke = [{"label":"Graz(Stadt)","y":340},{"label":"Wien(Stadt)","y":1200}];`,
+		"Bundesland.js":                  `var dpBundesland = [{"label":"W","y":1200},{"label":"Stmk","y":500}];`,
+		"GenesenTodesFaelleBL.js":        `var dpGenTodBL = [{"label":"Wien","y":800,"z":12}];`,
+		"Altersverteilung.js":            `var dpAltersverteilung = [{"label":"<5","y":10}];`,
+		"SimpleData.js":                  `var Erkrankungen = "1.700";`,
+		"Genesen.js":                     `var dpGenesen = "1.000";`,
+		"VerstorbenGemeldet.js":          `var dpTotGemeldet = "20";`,
+		"GesamtzahlNormalbettenBel.js":   `var dpGesNBBel = "80";`,
+		"GesamtzahlIntensivBettenBel.js": `var dpGesIBBel = "15";`,
+		"GesamtzahlTestungen.js":         `var dpGesTestungen = "25.000";`,
+	} {
+		writeSnapshot(t, dir, "", name, content)
+	}
+	url := "file://" + filepath.ToSlash(dir)
+	dashboardApi := &api{
+		he: &healthMinistryExporter{mp: e.mp, url: url},
+		ve: &vaccinationExporter{mp: e.mp, url: url},
+		hc: &hospitalExporter{mp: e.mp, url: url},
+		te: &testingsExporter{mp: e.mp, url: url},
+	}
+	return dashboardApi, func() { os.RemoveAll(dir) }
+}
+
+func TestDashboard(t *testing.T) {
+	dashboardApi, cleanup := newTestDashboardApi(t)
+	defer cleanup()
+	store, cleanupHistory := newTestHistory(t)
+	defer cleanupHistory()
+	date := time.Date(2020, 11, 9, 10, 0, 0, 0, time.UTC)
+	for i := 7; i >= 1; i-- {
+		assert.Nil(t, store.record(date.AddDate(0, 0, -i), metrics{
+			{"cov19_confirmed", nil, float64(1700 - 100*i)},
+			{"cov19_detail", &map[string]string{"province": "Wien", "country": "Austria"}, float64(1200 - 50*i)},
+			{"cov19_bezirk_infected", &map[string]string{"bezirk": "Graz(Stadt)", "country": "Austria"}, 51},
+		}))
+	}
+
+	d, err := dashboardApi.getDashboard(store, date)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1700), d.Total.TotalInfected)
+	assert.True(t, d.Austria.HasIncidence)
+	assert.InDelta(t, 700/float64(populationOf("Austria"))*100000, d.Austria.Incidence, 0.0001)
+	assert.Equal(t, []float64{100, 100, 100, 100, 100, 100}, d.Austria.NewCases)
+
+	assert.Equal(t, 2, len(d.Bundeslaender))
+	assert.Equal(t, "Wien", d.Bundeslaender[0].Name)
+	assert.Equal(t, uint64(12), d.Bundeslaender[0].Dead)
+	assert.True(t, d.Bundeslaender[0].HasIncidence)
+	assert.False(t, d.Bundeslaender[1].HasIncidence)
+
+	assert.Equal(t, 2, len(d.Bezirke))
+	assert.Equal(t, "Graz(Stadt)", d.Bezirke[0].Name)
+	assert.InDelta(t, 289.0/288806*100000, d.Bezirke[0].Incidence, 0.0001)
+	assert.False(t, d.Bezirke[1].HasIncidence)
+
+	html := strings.Builder{}
+	assert.Nil(t, dashboardTemplate.Execute(&html, d))
+	assert.Contains(t, html.String(), "Stand: 09.11.2020 10:00")
+	assert.Contains(t, html.String(), "<td>Graz(Stadt)</td>")
+	assert.Contains(t, html.String(), `<svg class="sparkline"`)
+	assert.Contains(t, html.String(), `href="impressum.html"`)
+
+	d, err = dashboardApi.getDashboard(nil, date)
+	assert.Nil(t, err)
+	assert.False(t, d.Austria.HasIncidence)
+	assert.Nil(t, d.Austria.NewCases)
+}
+
+func TestDashboardFromScheduler(t *testing.T) {
+	dashboardApi, cleanup := newTestDashboardApi(t)
+	defer replay.set(time.Time{})
+	defer func(previous *scheduler) { sched = previous }(sched)
+
+	replay.set(time.Date(2020, 11, 9, 10, 0, 0, 0, time.UTC))
+	sched = newScheduler([]Exporter{&staticExporter{testSnapshot(100, 40)}}, nil)
+	sched.api = dashboardApi
+	sched.started.Do(func() {})
+	assert.Nil(t, sched.cachedDashboard())
+	sched.refresh()
+	assert.NotNil(t, sched.cachedDashboard())
+
+	//the page is served from the refresh even after the sources are gone
+	cleanup()
+	recorder := httptest.NewRecorder()
+	handleDashboard(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Stand: 09.11.2020 10:00")
+}
+
+func TestDashboardAssets(t *testing.T) {
+	for _, name := range []string{"dashboard.css", "dashboard.js"} {
+		_, err := fs.Stat(staticFiles, name)
+		assert.Nil(t, err, name)
+	}
+	impressum, err := webFiles.ReadFile("impressum.html")
+	assert.Nil(t, err)
+	assert.Contains(t, string(impressum), "<h1>Impressum</h1>")
+}
+
+func TestDashboardFormatting(t *testing.T) {
+	assert.Equal(t, "0", formatCount(0))
+	assert.Equal(t, "999", formatCount(999))
+	assert.Equal(t, "1.000", formatCount(1000))
+	assert.Equal(t, "8.901.064", formatCount(8901064))
+	assert.Equal(t, "12,3", formatDecimal(12.34))
+	assert.Equal(t, "8,5", formatPercent(0.085))
+
+	assert.Equal(t, "", string(sparkline([]float64{1})))
+	assert.Equal(t, `<svg class="sparkline" width="100" height="24" viewBox="0 0 100 24"><polyline points="0.0,23.0 50.0,1.0 100.0,12.0"/></svg>`,
+		string(sparkline([]float64{0, 10, 5})))
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+//sourceConfig describes a data source that is exported without writing code
+type sourceConfig struct {
+	Name     string            `yaml:"name"`
+	URL      string            `yaml:"url"`
+	Format   string            `yaml:"format"`
+	CSV      csvConfig         `yaml:"csv"`
+	Root     string            `yaml:"root"`
+	Variable string            `yaml:"variable"`
+	Metrics  map[string]string `yaml:"metrics"`
+	Labels   map[string]string `yaml:"labels"`
+	Static   map[string]string `yaml:"static"`
+	Metadata string            `yaml:"metadata"`
+	Location string            `yaml:"location"`
+	Per100k  []string          `yaml:"per100k"`
+	Min      int               `yaml:"min"`
+}
+
+type csvConfig struct {
+	Delimiter string `yaml:"delimiter"`
+	Decimal   string `yaml:"decimal"`
+}
+
+type sourcesConfig struct {
+	Sources []sourceConfig `yaml:"sources"`
+}
+
+type declarativeExporter struct {
+	config sourceConfig
+	mp     *metadataProvider
+}
+
+//loadSourcesConfig reads the declarative sources from a yaml file
+func loadSourcesConfig(filename string) ([]*declarativeExporter, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := sourcesConfig{}
+	err = yaml.UnmarshalStrict(content, &config)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*declarativeExporter, 0, len(config.Sources))
+	for _, c := range config.Sources {
+		e, err := newDeclarativeExporter(c)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func newDeclarativeExporter(c sourceConfig) (*declarativeExporter, error) {
+	if c.Name == "" || c.URL == "" {
+		return nil, errors.New("Source requires a name and an url")
+	}
+	switch c.Format {
+	case "csv", "json":
+		if len(c.Metrics) == 0 {
+			return nil, fmt.Errorf("Source %s has no metrics", c.Name)
+		}
+	case "jsvar":
+		if c.Variable == "" || len(c.Metrics) != 1 {
+			return nil, fmt.Errorf("Source %s requires a variable and exactly one metric", c.Name)
+		}
+	default:
+		return nil, fmt.Errorf("Unknown format %s for source %s", c.Format, c.Name)
+	}
+	if c.CSV.Delimiter == "" {
+		c.CSV.Delimiter = ","
+	}
+	if c.CSV.Decimal == "" {
+		c.CSV.Decimal = "."
+	}
+	if c.Min == 0 {
+		c.Min = 1
+	}
+	e := &declarativeExporter{config: c}
+	if c.Metadata != "" {
+		e.mp = newMetadataProviderWithFilename(c.Metadata)
+		if e.mp == nil {
+			return nil, fmt.Errorf("Could not load metadata %s for source %s", c.Metadata, c.Name)
+		}
+	}
+	return e, nil
+}
+
+func (d *declarativeExporter) GetMetrics() (metrics, error) {
+	switch d.config.Format {
+	case "csv":
+		records, err := readCsvFromGet(d.config.URL, []rune(d.config.CSV.Delimiter)[0])
+		if err != nil {
+			return nil, err
+		}
+		c, err := csvColumns(records[0])
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]func(string) (string, bool), 0, len(records)-1)
+		for _, row := range records[1:] {
+			row := row
+			rows = append(rows, func(column string) (string, bool) {
+				if i, ok := c[column]; ok && i < len(row) {
+					return row[i], true
+				}
+				return "", false
+			})
+		}
+		return d.toMetrics(rows, d.config.CSV.Decimal)
+	case "json":
+		body, err := readFromGet(d.config.URL)
+		if err != nil {
+			return nil, err
+		}
+		var document interface{}
+		err = json.Unmarshal(body, &document)
+		if err != nil {
+			return nil, err
+		}
+		items := selectJSON(document, d.config.Root)
+		rows := make([]func(string) (string, bool), 0, len(items))
+		for _, item := range items {
+			item := item
+			rows = append(rows, func(selector string) (string, bool) {
+				values := selectJSON(item, selector)
+				if len(values) == 0 || values[0] == nil {
+					return "", false
+				}
+				if f, ok := values[0].(float64); ok {
+					return strconv.FormatFloat(f, 'f', -1, 64), true
+				}
+				return fmt.Sprint(values[0]), true
+			})
+		}
+		return d.toMetrics(rows, ".")
+	default:
+		value, err := readJsVarFromGet(d.config.URL, d.config.Variable)
+		if err != nil {
+			return nil, err
+		}
+		return d.toMetrics([]func(string) (string, bool){func(string) (string, bool) { return value, true }}, ".")
+	}
+}
+
+func (d *declarativeExporter) Health() []error {
+	result, err := d.GetMetrics()
+	if err != nil {
+		return []error{fmt.Errorf("%s: %s", d.config.Name, err.Error())}
+	}
+	if len(result) < d.config.Min {
+		return []error{fmt.Errorf("%s: Not enough results: %d", d.config.Name, len(result))}
+	}
+	return nil
+}
+
+func (d *declarativeExporter) toMetrics(rows []func(string) (string, bool), decimal string) (metrics, error) {
+	result := make(metrics, 0)
+	for _, row := range rows {
+		tags := make(map[string]string, len(d.config.Labels)+len(d.config.Static)+2)
+		for k, v := range d.config.Static {
+			tags[k] = v
+		}
+		for _, label := range sortedKeys(d.config.Labels) {
+			column := d.config.Labels[label]
+			if value, ok := row(column); ok {
+				tags[label] = strings.TrimSpace(value)
+			}
+		}
+		var data *metaData
+		if d.mp != nil {
+			data = d.mp.getMetadata(tags[d.config.Location])
+			if data != nil {
+				tags["latitude"] = ftos(data.location.lat)
+				tags["longitude"] = ftos(data.location.long)
+			}
+		}
+		var metricTags *map[string]string
+		if len(tags) > 0 {
+			metricTags = &tags
+		}
+		for _, name := range sortedKeys(d.config.Metrics) {
+			column := d.config.Metrics[name]
+			raw, ok := row(column)
+			if !ok {
+				return nil, fmt.Errorf("%s: Could not find %s", d.config.Name, column)
+			}
+			value, err := parseNumber(raw, decimal)
+			if err != nil {
+				return nil, fmt.Errorf("%s: Invalid value for %s: %s", d.config.Name, name, raw)
+			}
+			result = append(result, metric{name, metricTags, value})
+			if data != nil && data.population > 0 && contains(d.config.Per100k, name) {
+				result = append(result, metric{name + "_per_100k", metricTags, value / float64(data.population) * 100000})
+			}
+		}
+	}
+	return result, nil
+}
+
+//parseNumber parses numbers with a configurable decimal separator, the other separator is treated as grouping
+func parseNumber(s string, decimal string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if decimal == "," {
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.ReplaceAll(s, ",", ".")
+	} else {
+		s = strings.ReplaceAll(s, ",", "")
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+//selectJSON evaluates a simple JSONPath like "$.features[*].attributes.name" against a decoded document
+func selectJSON(document interface{}, path string) []interface{} {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	current := []interface{}{document}
+	if path == "" {
+		return current
+	}
+	for _, part := range strings.Split(path, ".") {
+		key, index := part, ""
+		if i := strings.Index(part, "["); i >= 0 && strings.HasSuffix(part, "]") {
+			key, index = part[:i], part[i+1:len(part)-1]
+		}
+		next := make([]interface{}, 0, len(current))
+		for _, c := range current {
+			if key != "" {
+				object, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if c, ok = object[key]; !ok {
+					continue
+				}
+			}
+			if index == "" {
+				next = append(next, c)
+				continue
+			}
+			array, ok := c.([]interface{})
+			if !ok {
+				continue
+			}
+			if index == "*" {
+				next = append(next, array...)
+			} else if i, err := strconv.Atoi(index); err == nil && i >= 0 && i < len(array) {
+				next = append(next, array[i])
+			}
+		}
+		current = next
+	}
+	return current
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func declarativeTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bezirke.csv", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Bezirk;GKZ;AnzahlFaelle;Inzidenz\nGraz(Stadt);601;1.234;12,5\nUnbekannt;999;7;1,0\n"))
+	})
+	mux.HandleFunc("/regions.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"features":[{"attributes":{"name":"Wien","cases":[10,20]}},{"attributes":{"name":"Tirol","cases":[5,7.5]}}]}`))
+	})
+	mux.HandleFunc("/SimpleData.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`var Erkrankungen = "1.016";`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestDeclarativeCsv(t *testing.T) {
+	ts := declarativeTestServer()
+	defer ts.Close()
+
+	d, err := newDeclarativeExporter(sourceConfig{
+		Name:     "test",
+		URL:      ts.URL + "/bezirke.csv",
+		Format:   "csv",
+		CSV:      csvConfig{Delimiter: ";", Decimal: ","},
+		Metrics:  map[string]string{"test_infected": "AnzahlFaelle", "test_incidence": "Inzidenz"},
+		Labels:   map[string]string{"bezirk": "Bezirk", "gkz": "GKZ"},
+		Static:   map[string]string{"country": "Austria"},
+		Metadata: "bezirke.csv",
+		Location: "bezirk",
+		Per100k:  []string{"test_infected"},
+	})
+	assert.Nil(t, err)
+
+	result, err := d.GetMetrics()
+	assert.Nil(t, err)
+	names := make([]string, 0, len(result))
+	for _, m := range result {
+		names = append(names, m.Name)
+	}
+	assert.Equal(t, []string{"test_incidence", "test_infected", "test_infected_per_100k", "test_incidence", "test_infected"}, names)
+
+	graz := result.findMetric("test_infected", "bezirk=Graz(Stadt)")
+	assert.NotNil(t, graz)
+	assert.Equal(t, float64(1234), graz.Value)
+	assert.Equal(t, "601", (*graz.Tags)["gkz"])
+	assert.Equal(t, "Austria", (*graz.Tags)["country"])
+	assert.Equal(t, "47.070714", (*graz.Tags)["latitude"])
+
+	incidence := result.findMetric("test_incidence", "bezirk=Graz(Stadt)")
+	assert.NotNil(t, incidence)
+	assert.Equal(t, 12.5, incidence.Value)
+
+	per100k := result.findMetric("test_infected_per_100k", "bezirk=Graz(Stadt)")
+	assert.NotNil(t, per100k)
+	assert.InDelta(t, 1234.0/288806*100000, per100k.Value, 0.0001)
+
+	unknown := result.findMetric("test_infected", "bezirk=Unbekannt")
+	assert.NotNil(t, unknown)
+	assert.Equal(t, 3, len(*unknown.Tags))
+	assert.Equal(t, 0, len(d.Health()))
+}
+
+func TestDeclarativeJson(t *testing.T) {
+	ts := declarativeTestServer()
+	defer ts.Close()
+
+	d, err := newDeclarativeExporter(sourceConfig{
+		Name:    "test",
+		URL:     ts.URL + "/regions.json",
+		Format:  "json",
+		Root:    "$.features[*].attributes",
+		Metrics: map[string]string{"test_cases": "cases[1]"},
+		Labels:  map[string]string{"province": "name"},
+	})
+	assert.Nil(t, err)
+
+	result, err := d.GetMetrics()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, float64(20), result.findMetric("test_cases", "province=Wien").Value)
+	assert.Equal(t, 7.5, result.findMetric("test_cases", "province=Tirol").Value)
+}
+
+func TestDeclarativeJsVar(t *testing.T) {
+	ts := declarativeTestServer()
+	defer ts.Close()
+
+	d, err := newDeclarativeExporter(sourceConfig{
+		Name:     "test",
+		URL:      ts.URL + "/SimpleData.js",
+		Format:   "jsvar",
+		Variable: "Erkrankungen",
+		Metrics:  map[string]string{"test_confirmed": ""},
+	})
+	assert.Nil(t, err)
+
+	result, err := d.GetMetrics()
+	assert.Nil(t, err)
+	assert.Equal(t, metrics{{"test_confirmed", nil, 1016}}, result)
+
+	d.config.Variable = "Unknown"
+	assert.Equal(t, 1, len(d.Health()))
+}
+
+func TestDeclarativeInvalidConfig(t *testing.T) {
+	_, err := newDeclarativeExporter(sourceConfig{Name: "test", URL: "http://localhost", Format: "xml"})
+	assert.NotNil(t, err)
+	_, err = newDeclarativeExporter(sourceConfig{Name: "test", URL: "http://localhost", Format: "csv"})
+	assert.NotNil(t, err)
+	_, err = newDeclarativeExporter(sourceConfig{Name: "test", URL: "http://localhost", Format: "jsvar", Metrics: map[string]string{"a": ""}})
+	assert.NotNil(t, err)
+}
+
+func TestLoadSourcesConfig(t *testing.T) {
+	sources, err := loadSourcesConfig("config/sources.yml")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(sources))
+	assert.Equal(t, ";", sources[0].config.CSV.Delimiter)
+	assert.NotNil(t, sources[0].mp)
+
+	file, err := ioutil.TempFile("", "sources*.yml")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString("sources:\n  - name: test\n    unknown: field\n")
+	file.Close()
+	_, err = loadSourcesConfig(file.Name())
+	assert.NotNil(t, err)
+}
+
+func TestSelectJSON(t *testing.T) {
+	document := map[string]interface{}{"a": []interface{}{map[string]interface{}{"b": 1.0}, map[string]interface{}{"b": 2.0}}}
+	assert.Equal(t, []interface{}{1.0, 2.0}, selectJSON(document, "$.a[*].b"))
+	assert.Equal(t, []interface{}{2.0}, selectJSON(document, "a[1].b"))
+	assert.Equal(t, 0, len(selectJSON(document, "a[5].b")))
+	assert.Equal(t, 0, len(selectJSON(document, "c")))
+}
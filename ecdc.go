@@ -1,9 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"net/http"
 	"strings"
 	"time"
 	"unicode"
@@ -31,6 +31,11 @@ func (e *ecdcExporter) GetMetrics() (metrics, error) {
 	if err != nil {
 		return nil, err
 	}
+	return e.statMetrics(stats), nil
+}
+
+//statMetrics returns the metrics of the infections and deaths by country
+func (e *ecdcExporter) statMetrics(stats []ecdcStat) metrics {
 	result := make([]metric, 0)
 	for i := range stats {
 		tags := e.getTags(stats, i)
@@ -49,7 +54,7 @@ func (e *ecdcExporter) GetMetrics() (metrics, error) {
 			result = append(result, metric{Name: "cov19_world_infected_per_100k", Value: infection100k(infected, population), Tags: &tags})
 		}
 	}
-	return result, nil
+	return result
 }
 
 //Health checks the functionality of the exporter
@@ -61,9 +66,12 @@ func (e *ecdcExporter) Health() []error {
 		errors = append(errors, fmt.Errorf("World stats are failing"))
 	}
 
+	//every country has several metrics, a missing country is reported once
+	missing := make(map[string]bool)
 	for _, m := range worldStats {
 		country := (*m.Tags)["country"]
-		if mp.getLocation(country) == nil {
+		if mp.getLocation(country) == nil && !missing[country] {
+			missing[country] = true
 			errors = append(errors, fmt.Errorf("Could not find location for country: %s", country))
 		}
 	}
@@ -97,14 +105,12 @@ func (e *ecdcExporter) getTags(stats []ecdcStat, i int) map[string]string {
 }
 
 func getEcdcStat(url string) ([]ecdcStat, error) {
-	client := http.Client{Timeout: 3 * time.Second}
-	response, err := client.Get(url)
+	body, err := readFromGetWithTimeout(url, 3*time.Second)
 	if err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
 
-	document, _ := goquery.NewDocumentFromReader(response.Body)
+	document, _ := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	rows := document.Find("table").Find("tbody").Find("tr")
 	if rows.Size() == 0 {
 		return nil, errors.New("Could not find table")
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//sortedTags returns the tag names of a metric in alphabetical order
+func sortedTags(m metric) []string {
+	if m.Tags == nil {
+		return nil
+	}
+	names := make([]string, 0, len(*m.Tags))
+	for k := range *m.Tags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//influxEncoder writes the InfluxDB line protocol with the metric name as measurement, the tags as tags and the value in
+//the field value. Names are UTF-8, only commas, spaces and equal signs are escaped.
+type influxEncoder struct{}
+
+var influxMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+var influxTagEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+
+func (influxEncoder) contentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (influxEncoder) encode(w io.Writer, metrics metrics, timestamp time.Time) error {
+	for _, m := range metrics {
+		if math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+			continue
+		}
+		line := strings.Builder{}
+		line.WriteString(influxMeasurementEscaper.Replace(m.Name))
+		for _, k := range sortedTags(m) {
+			//Influx rejects empty tag values
+			if v := (*m.Tags)[k]; v != "" {
+				line.WriteString("," + influxTagEscaper.Replace(k) + "=" + influxTagEscaper.Replace(v))
+			}
+		}
+		line.WriteString(" value=" + strconv.FormatFloat(m.Value, 'f', -1, 64))
+		if !timestamp.IsZero() {
+			line.WriteString(" " + strconv.FormatInt(timestamp.UnixNano(), 10))
+		}
+		line.WriteString("\n")
+		if _, err := io.WriteString(w, line.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//graphiteEncoder writes the Graphite plaintext protocol. The tags are appended to the path as name and value segments
+//in alphabetical order, e.g. covid19.cov19_detail.country.Austria.province.Niederoesterreich.
+type graphiteEncoder struct {
+	prefix string
+}
+
+var graphiteTransliterator = strings.NewReplacer("ä", "ae", "ö", "oe", "ü", "ue", "Ä", "Ae", "Ö", "Oe", "Ü", "Ue", "ß", "ss")
+
+//graphiteSegment transliterates umlauts and replaces everything that is not allowed in a path segment with _
+func graphiteSegment(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, graphiteTransliterator.Replace(s))
+}
+
+func (graphiteEncoder) contentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+//graphitePath returns the path of a metric
+func (g graphiteEncoder) graphitePath(m metric) string {
+	segments := make([]string, 0)
+	if g.prefix != "" {
+		segments = append(segments, g.prefix)
+	}
+	segments = append(segments, graphiteSegment(m.Name))
+	for _, k := range sortedTags(m) {
+		//empty segments are not allowed
+		if v := (*m.Tags)[k]; v != "" {
+			segments = append(segments, graphiteSegment(k), graphiteSegment(v))
+		}
+	}
+	return strings.Join(segments, ".")
+}
+
+//encode uses the current time if timestamp is zero, Graphite requires a timestamp
+func (g graphiteEncoder) encode(w io.Writer, metrics metrics, timestamp time.Time) error {
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	for _, m := range metrics {
+		if math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s %s %d\n", g.graphitePath(m), strconv.FormatFloat(m.Value, 'f', -1, 64), timestamp.Unix()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
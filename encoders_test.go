@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var encoderTestMetrics = metrics{
+	{"cov19_detail", &map[string]string{"country": "Austria", "province": "Niederösterreich"}, 1200},
+	{"cov19_bezirk_infected", &map[string]string{"bezirk": "Sankt Pölten(Land), Umgebung", "province": "", "x=y": "1"}, 42.5},
+	{"cov19_confirmed", nil, 1700},
+}
+
+func TestPrometheusEncoder(t *testing.T) {
+	buffer := bytes.Buffer{}
+	assert.Nil(t, prometheusEncoder{}.encode(&buffer, metrics{{"cov19_confirmed", nil, 1700}}, time.Unix(1604912400, 0)))
+	assert.Equal(t, "cov19_confirmed 1700.000000 1604912400000\n", buffer.String())
+}
+
+func TestInfluxEncoder(t *testing.T) {
+	buffer := bytes.Buffer{}
+	assert.Nil(t, influxEncoder{}.encode(&buffer, encoderTestMetrics, time.Unix(1604912400, 0)))
+	assert.Equal(t, `cov19_detail,country=Austria,province=Niederösterreich value=1200 1604912400000000000
+cov19_bezirk_infected,bezirk=Sankt\ Pölten(Land)\,\ Umgebung,x\=y=1 value=42.5 1604912400000000000
+cov19_confirmed value=1700 1604912400000000000
+`, buffer.String())
+
+	buffer.Reset()
+	assert.Nil(t, influxEncoder{}.encode(&buffer, metrics{{"cov19 confirmed,total", nil, 1}}, time.Time{}))
+	assert.Equal(t, "cov19\\ confirmed\\,total value=1\n", buffer.String())
+}
+
+func TestGraphiteEncoder(t *testing.T) {
+	buffer := bytes.Buffer{}
+	assert.Nil(t, graphiteEncoder{prefix: "covid19"}.encode(&buffer, encoderTestMetrics, time.Unix(1604912400, 0)))
+	assert.Equal(t, `covid19.cov19_detail.country.Austria.province.Niederoesterreich 1200 1604912400
+covid19.cov19_bezirk_infected.bezirk.Sankt_Poelten_Land___Umgebung.x_y.1 42.5 1604912400
+covid19.cov19_confirmed 1700 1604912400
+`, buffer.String())
+}
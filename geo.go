@@ -0,0 +1,256 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+)
+
+//geoFiles are the boundaries that were in geo/ at build time, see geo/README.md
+//go:embed geo
+var geoFiles embed.FS
+
+type geoFeatureCollection struct {
+	Type     string       `json:"type"`
+	Features []geoFeature `json:"features"`
+}
+
+type geoFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   json.RawMessage        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+//boundary is the outline of a region, polygons contain rings of [longitude, latitude] points
+type boundary struct {
+	name     string
+	id       string
+	geometry json.RawMessage
+	polygons [][][][2]float64
+}
+
+type boundaryProvider struct {
+	boundaries []*boundary
+	byName     map[string]*boundary
+	byID       map[string]*boundary
+}
+
+//viennaID is the GKZ of Wien(Stadt), the official boundaries split it into the districts 901 to 923 that are combined
+//to the boundary of Wien(Stadt)
+const viennaID = "900"
+
+//newBoundaryProvider loads region boundaries from a GeoJSON FeatureCollection with name and id properties. A file in
+//the working directory replaces the bundled file with the same name, without both there are no boundaries.
+func newBoundaryProvider(filename string) *boundaryProvider {
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		if content, err = geoFiles.ReadFile(filename); err != nil {
+			return nil
+		}
+	}
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+	result, err := parseBoundaries(content)
+	if err != nil {
+		log.Print(filename + ": " + err.Error())
+		return nil
+	}
+	return result
+}
+
+func parseBoundaries(content []byte) (*boundaryProvider, error) {
+	collection := geoFeatureCollection{}
+	err := json.Unmarshal(content, &collection)
+	if err != nil {
+		return nil, err
+	}
+	result := &boundaryProvider{byName: make(map[string]*boundary), byID: make(map[string]*boundary)}
+	var vienna *boundary
+	for _, f := range collection.Features {
+		name, id := featureProperty(f, "name", "g_name"), featureProperty(f, "id", "g_id")
+		if name == "" {
+			return nil, fmt.Errorf("Feature without name")
+		}
+		polygons, err := parsePolygons(f.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid geometry for %s: %s", name, err.Error())
+		}
+		if len(id) == 3 && id[0] == '9' && id != viennaID {
+			if vienna == nil {
+				vienna = &boundary{name: "Wien(Stadt)", id: viennaID}
+			}
+			vienna.polygons = append(vienna.polygons, polygons...)
+		}
+		result.add(&boundary{name: name, id: id, geometry: f.Geometry, polygons: polygons})
+	}
+	if vienna != nil && result.byID[viennaID] == nil {
+		vienna.geometry, err = json.Marshal(map[string]interface{}{"type": "MultiPolygon", "coordinates": vienna.polygons})
+		if err != nil {
+			return nil, err
+		}
+		result.add(vienna)
+	}
+	return result, nil
+}
+
+//featureProperty returns the first of the properties a feature has, the files of Statistik Austria use g_id and g_name
+func featureProperty(f geoFeature, names ...string) string {
+	for _, name := range names {
+		if v := f.Properties[name]; v != nil {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func (b *boundaryProvider) add(region *boundary) {
+	b.boundaries = append(b.boundaries, region)
+	b.byName[normalizeName(region.name)] = region
+	if region.id != "" {
+		b.byID[region.id] = region
+	}
+}
+
+func parsePolygons(raw json.RawMessage) ([][][][2]float64, error) {
+	geometry := geoGeometry{}
+	err := json.Unmarshal(raw, &geometry)
+	if err != nil {
+		return nil, err
+	}
+	switch geometry.Type {
+	case "Polygon":
+		polygon := [][][2]float64{}
+		err = json.Unmarshal(geometry.Coordinates, &polygon)
+		return [][][][2]float64{polygon}, err
+	case "MultiPolygon":
+		polygons := [][][][2]float64{}
+		err = json.Unmarshal(geometry.Coordinates, &polygons)
+		return polygons, err
+	}
+	return nil, fmt.Errorf("Unsupported geometry type %s", geometry.Type)
+}
+
+//getBoundary returns the boundary of a region by its GKZ, the name is used for boundaries without id
+func (b *boundaryProvider) getBoundary(id string, name string) *boundary {
+	if b == nil {
+		return nil
+	}
+	if region, ok := b.byID[id]; ok && id != "" {
+		return region
+	}
+	return b.byName[normalizeName(name)]
+}
+
+//geoFeatureFor uses the boundary of a region as geometry and falls back to its location as point
+func geoFeatureFor(boundaries *boundaryProvider, id string, name string, location apiLocaiton, properties map[string]interface{}) geoFeature {
+	properties["name"] = name
+	properties["id"] = id
+	if b := boundaries.getBoundary(id, name); b != nil {
+		return geoFeature{"Feature", b.geometry, properties}
+	}
+	point, _ := json.Marshal(map[string]interface{}{"type": "Point", "coordinates": []float64{location.Long, location.Lat}})
+	return geoFeature{"Feature", point, properties}
+}
+
+func geoInfection100k(infected uint64, population uint64) float64 {
+	if population == 0 {
+		return 0
+	}
+	return infection100k(infected, population)
+}
+
+//weekIncidence returns the 7-day incidence per 100.000 inhabitants from the value of a week ago, nil without history
+func weekIncidence(weekAgo map[string][]historyPoint, name string, infected uint64, population uint64) *float64 {
+	points, ok := weekAgo[normalizeName(name)]
+	if !ok || population == 0 {
+		return nil
+	}
+	incidence := (float64(infected) - points[0].Value) / float64(population) * 100000
+	return &incidence
+}
+
+//GetBezirkGeo returns the districts with their boundaries, the ministry publishes no deaths per district
+func (a *api) GetBezirkGeo(history *historyStore, date time.Time) (geoFeatureCollection, error) {
+	rows, err := a.getBezirkRows(history, date)
+	if err != nil {
+		return geoFeatureCollection{}, err
+	}
+	result := geoFeatureCollection{Type: "FeatureCollection", Features: make([]geoFeature, 0, len(rows))}
+	for _, s := range rows {
+		var incidence *float64
+		if s.hasIncidence {
+			value := s.incidence
+			incidence = &value
+		}
+		result.Features = append(result.Features, geoFeatureFor(a.bezirkBoundaries, s.gkz, s.Name, s.Location, map[string]interface{}{
+			"population":            s.Population,
+			"infected":              s.Infected,
+			"infected_per_100k":     geoInfection100k(s.Infected, s.Population),
+			"incidence_7d_per_100k": incidence,
+		}))
+	}
+	return result, nil
+}
+
+func (a *api) GetBundeslandGeo(history *historyStore, date time.Time) (geoFeatureCollection, error) {
+	stats, err := a.GetBundeslandStat()
+	if err != nil {
+		return geoFeatureCollection{}, err
+	}
+	day := calendarDay(date).AddDate(0, 0, -7)
+	weekAgo, err := history.byRegion("cov19_detail", "province", day, day)
+	if err != nil {
+		return geoFeatureCollection{}, err
+	}
+	result := geoFeatureCollection{Type: "FeatureCollection", Features: make([]geoFeature, 0, len(stats))}
+	for _, s := range stats {
+		result.Features = append(result.Features, geoFeatureFor(a.bundeslandBoundaries, a.he.mp.getId(s.Name), s.Name, s.Location, map[string]interface{}{
+			"population":            s.Population,
+			"infected":              s.Infected,
+			"infected_per_100k":     geoInfection100k(s.Infected, s.Population),
+			"incidence_7d_per_100k": weekIncidence(weekAgo, s.Name, s.Infected, s.Population),
+			"dead":                  s.Dead,
+		}))
+	}
+	return result, nil
+}
+
+//insideRing checks with the even-odd rule if a point is inside a ring of [longitude, latitude] points
+func insideRing(ring [][2]float64, long float64, lat float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > lat) != (b[1] > lat) && long < (b[0]-a[0])*(lat-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+//contains checks if a point is inside one of the polygons and not inside one of their holes
+func (b *boundary) contains(long float64, lat float64) bool {
+	for _, polygon := range b.polygons {
+		if len(polygon) == 0 || !insideRing(polygon[0], long, lat) {
+			continue
+		}
+		hole := false
+		for _, ring := range polygon[1:] {
+			hole = hole || insideRing(ring, long, lat)
+		}
+		if !hole {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testBoundaries = `{"type":"FeatureCollection","features":[
+{"type":"Feature","properties":{"name":"Graz(Stadt)","id":601},"geometry":{"type":"Polygon","coordinates":[[[15.35,47.0],[15.5,47.0],[15.5,47.15],[15.35,47.15],[15.35,47.0]]]}},
+{"type":"Feature","properties":{"name":"Wien(Stadt)","id":"900"},"geometry":{"type":"MultiPolygon","coordinates":[[[[16.2,48.1],[16.5,48.1],[16.5,48.3],[16.2,48.3],[16.2,48.1]],[[16.3,48.15],[16.35,48.15],[16.35,48.2],[16.3,48.15]]]]}}
+]}`
+
+//testOfficialBoundaries uses the properties of the Statistik Austria files and splits Vienna into its districts
+const testOfficialBoundaries = `{"type":"FeatureCollection","features":[
+{"type":"Feature","properties":{"g_name":"Graz(Stadt)","g_id":"601"},"geometry":{"type":"Polygon","coordinates":[[[15.35,47.0],[15.5,47.0],[15.5,47.15],[15.35,47.15],[15.35,47.0]]]}},
+{"type":"Feature","properties":{"g_name":"Wien 1.,Innere Stadt","g_id":"901"},"geometry":{"type":"Polygon","coordinates":[[[16.36,48.2],[16.38,48.2],[16.38,48.22],[16.36,48.22],[16.36,48.2]]]}},
+{"type":"Feature","properties":{"g_name":"Wien 2.,Leopoldstadt","g_id":"902"},"geometry":{"type":"Polygon","coordinates":[[[16.38,48.2],[16.42,48.2],[16.42,48.23],[16.38,48.23],[16.38,48.2]]]}}
+]}`
+
+func TestParseBoundaries(t *testing.T) {
+	b, err := parseBoundaries([]byte(testBoundaries))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(b.boundaries))
+
+	graz := b.getBoundary("", "Graz (Stadt)")
+	assert.NotNil(t, graz)
+	assert.Equal(t, graz, b.getBoundary("601", "Graz"))
+	assert.Equal(t, "601", graz.id)
+	assert.Equal(t, 1, len(graz.polygons))
+	assert.Equal(t, [2]float64{15.35, 47.0}, graz.polygons[0][0][0])
+
+	vienna := b.getBoundary("", "Wien(Stadt)")
+	assert.Equal(t, "900", vienna.id)
+	assert.Equal(t, 2, len(vienna.polygons[0]))
+
+	assert.Nil(t, b.getBoundary("401", "Linz"))
+	assert.Nil(t, (*boundaryProvider)(nil).getBoundary("601", "Graz(Stadt)"))
+
+	_, err = parseBoundaries([]byte(`{"features":[{"properties":{"name":"x"},"geometry":{"type":"LineString","coordinates":[]}}]}`))
+	assert.NotNil(t, err)
+	_, err = parseBoundaries([]byte(`{"features":[{"properties":{},"geometry":{"type":"Point","coordinates":[1,2]}}]}`))
+	assert.NotNil(t, err)
+}
+
+func TestParseOfficialBoundaries(t *testing.T) {
+	b, err := parseBoundaries([]byte(testOfficialBoundaries))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(b.boundaries))
+	assert.Equal(t, "Graz(Stadt)", b.getBoundary("601", "").name)
+
+	vienna := b.getBoundary("900", "Wien(Stadt)")
+	assert.Equal(t, "Wien(Stadt)", vienna.name)
+	assert.Equal(t, 2, len(vienna.polygons))
+	assert.True(t, vienna.contains(16.37, 48.21))
+	assert.True(t, vienna.contains(16.4, 48.22))
+	assert.False(t, vienna.contains(16.44, 48.21))
+	assert.JSONEq(t, `{"type":"MultiPolygon","coordinates":[[[[16.36,48.2],[16.38,48.2],[16.38,48.22],[16.36,48.22],[16.36,48.2]]],`+
+		`[[[16.38,48.2],[16.42,48.2],[16.42,48.23],[16.38,48.23],[16.38,48.2]]]]}`, string(vienna.geometry))
+	assert.False(t, b.getBoundary("901", "").contains(16.4, 48.22))
+}
+
+func TestNewBoundaryProvider(t *testing.T) {
+	assert.Nil(t, newBoundaryProvider("geo/missing.geojson"))
+
+	file, err := ioutil.TempFile("", "boundaries*.geojson")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString(testBoundaries)
+	file.Close()
+	assert.NotNil(t, newBoundaryProvider(file.Name()))
+}
+
+func TestBezirkGeo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	writeSnapshot(t, dir, "", "Bezirke.js", `var dpBezirke = [{"label":"Graz(Stadt)","y":52},{"label":"Linz(Stadt)","y":20}];`)
+
+	boundaries, err := parseBoundaries([]byte(testBoundaries))
+	assert.Nil(t, err)
+	geoApi := &api{he: &healthMinistryExporter{mp: e.mp, url: "file://" + filepath.ToSlash(dir)}, bezirkBoundaries: boundaries}
+	store, cleanupHistory := newTestHistory(t)
+	defer cleanupHistory()
+	date := time.Date(2020, 11, 9, 10, 0, 0, 0, time.UTC)
+	assert.Nil(t, store.record(date.AddDate(0, 0, -7), metrics{{"cov19_bezirk_infected", &map[string]string{"bezirk": "Graz(Stadt)"}, 40}}))
+
+	result, err := geoApi.GetBezirkGeo(store, date)
+	assert.Nil(t, err)
+	assert.Equal(t, "FeatureCollection", result.Type)
+	assert.Equal(t, 2, len(result.Features))
+
+	graz := result.Features[0]
+	assert.Equal(t, "Graz(Stadt)", graz.Properties["name"])
+	assert.Equal(t, "601", graz.Properties["id"])
+	assert.Equal(t, uint64(52), graz.Properties["infected"])
+	assert.InDelta(t, 52.0/288806*100000, graz.Properties["infected_per_100k"], 0.0001)
+	assert.InDelta(t, 12.0/288806*100000, *graz.Properties["incidence_7d_per_100k"].(*float64), 0.0001)
+	_, dead := graz.Properties["dead"]
+	assert.False(t, dead)
+	assert.JSONEq(t, `{"type":"Polygon","coordinates":[[[15.35,47.0],[15.5,47.0],[15.5,47.15],[15.35,47.15],[15.35,47.0]]]}`, string(graz.Geometry))
+
+	linz := result.Features[1]
+	assert.JSONEq(t, `{"type":"Point","coordinates":[14.286967,48.305948]}`, string(linz.Geometry))
+	assert.Nil(t, linz.Properties["incidence_7d_per_100k"])
+
+	content, err := json.Marshal(result)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(content), `"dead"`)
+}
+
+func TestBundeslandGeo(t *testing.T) {
+	geoApi, cleanup := newTestDashboardApi(t)
+	defer cleanup()
+	var err error
+	geoApi.bundeslandBoundaries, err = parseBoundaries([]byte(`{"type":"FeatureCollection","features":[` +
+		`{"type":"Feature","properties":{"g_name":"Wien","g_id":"9"},"geometry":{"type":"Polygon","coordinates":[[[16.2,48.1],[16.5,48.1],[16.5,48.3],[16.2,48.1]]]}}]}`))
+	assert.Nil(t, err)
+	store, cleanupHistory := newTestHistory(t)
+	defer cleanupHistory()
+	date := time.Date(2020, 11, 9, 10, 0, 0, 0, time.UTC)
+	assert.Nil(t, store.record(date.AddDate(0, 0, -7), metrics{{"cov19_detail", &map[string]string{"province": "Wien"}, 1000}}))
+
+	result, err := geoApi.GetBundeslandGeo(store, date)
+	assert.Nil(t, err)
+	var vienna geoFeature
+	for _, f := range result.Features {
+		if f.Properties["name"] == "Wien" {
+			vienna = f
+		}
+	}
+	assert.Equal(t, "9", vienna.Properties["id"])
+	assert.Equal(t, uint64(1200), vienna.Properties["infected"])
+	assert.Equal(t, uint64(12), vienna.Properties["dead"])
+	assert.InDelta(t, 200.0/1889100*100000, *vienna.Properties["incidence_7d_per_100k"].(*float64), 0.0001)
+	assert.Contains(t, string(vienna.Geometry), `"Polygon"`)
+}
+
+//TestBundledBoundaries checks that every district and province of bezirke.csv has a boundary around its location
+func TestBundledBoundaries(t *testing.T) {
+	districts, provinces := newBoundaryProvider("geo/bezirke.geojson"), newBoundaryProvider("geo/bundeslaender.geojson")
+	if districts == nil || provinces == nil {
+		t.Skip("The boundaries of Statistik Austria are not in geo/, see geo/README.md")
+	}
+	for _, m := range e.mp.data {
+		boundaries := districts
+		if m.parent == "" {
+			boundaries = provinces
+		}
+		b := boundaries.getBoundary(m.id, m.country)
+		if assert.NotNil(t, b, m.country) {
+			assert.True(t, b.contains(m.location.long, m.location.lat), m.country)
+			assert.Equal(t, m.id, b.id, m.country)
+		}
+		if m.parent != "" {
+			assert.True(t, provinces.getBoundary("", m.parent).contains(m.location.long, m.location.lat), m.country)
+		}
+	}
+	//Bratislava and Munich are not in Austria
+	for _, b := range append(districts.boundaries, provinces.boundaries...) {
+		assert.False(t, b.contains(17.11, 48.14), b.name)
+		assert.False(t, b.contains(11.58, 48.14), b.name)
+	}
+}
+
+func TestBoundaryContains(t *testing.T) {
+	b, err := parseBoundaries([]byte(testBoundaries))
+	assert.Nil(t, err)
+	graz := b.getBoundary("601", "Graz(Stadt)")
+	assert.True(t, graz.contains(15.44, 47.07))
+	assert.False(t, graz.contains(15.6, 47.07))
+	assert.False(t, graz.contains(15.44, 46.9))
+
+	vienna := b.getBoundary("900", "Wien(Stadt)")
+	assert.True(t, vienna.contains(16.4, 48.2))
+	assert.False(t, vienna.contains(16.34, 48.16))
+	assert.True(t, vienna.contains(16.31, 48.19))
+}
@@ -1,8 +1,21 @@
 module github.com/cinemast/covid19-at
 
-go 1.14
+go 1.18
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.1
+	github.com/golang/snappy v0.0.4
+	github.com/gorilla/websocket v1.5.0
+	github.com/graphql-go/graphql v0.8.1
 	github.com/stretchr/testify v1.5.1
+	golang.org/x/image v0.18.0
+	gopkg.in/yaml.v2 v2.2.2
+)
+
+require (
+	github.com/andybalholm/cascadia v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.0.0-20200202094626-16171245cfb2 // indirect
+	golang.org/x/text v0.16.0 // indirect
 )
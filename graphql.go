@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+//The GraphQL schema exposes the v2 types with camelCase field names, the default resolver matches them case
+//insensitive against the go field names. All regions of a request are loaded once by the graphqlLoader.
+
+type graphqlContextKey struct{}
+
+//graphqlLoader loads the stats of a request lazily and only once, nested fields like Bundesland.bezirke reuse them
+type graphqlLoader struct {
+	api     *api
+	history *historyStore
+	date    time.Time
+
+	totalOnce sync.Once
+	total     apiV2Total
+	totalErr  error
+
+	provincesOnce sync.Once
+	provinces     []apiV2Bundesland
+	provincesErr  error
+
+	districtsOnce sync.Once
+	rows          []bezirkRow
+	districts     []apiV2Bezirk
+	districtsErr  error
+}
+
+//graphqlRequest is a GraphQL request as json body or GET query parameters
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+//graphqlHistory is a page of a history connection
+type graphqlHistory struct {
+	points      []historyPoint
+	totalCount  int
+	hasNextPage bool
+}
+
+type graphqlAgeGroup struct {
+	Group string
+	Value uint64
+}
+
+func newGraphqlLoader(a *api, history *historyStore, date time.Time) *graphqlLoader {
+	return &graphqlLoader{api: a, history: history, date: date}
+}
+
+func (l *graphqlLoader) getTotal() (apiV2Total, error) {
+	l.totalOnce.Do(func() { l.total, l.totalErr = l.api.GetOverallStatV2() })
+	return l.total, l.totalErr
+}
+
+func (l *graphqlLoader) getBundeslaender() ([]apiV2Bundesland, error) {
+	l.provincesOnce.Do(func() { l.provinces, l.provincesErr = l.api.GetBundeslandStatV2() })
+	return l.provinces, l.provincesErr
+}
+
+func (l *graphqlLoader) getBezirke() ([]apiV2Bezirk, error) {
+	l.districtsOnce.Do(func() {
+		l.rows, l.districtsErr = l.api.getBezirkRows(l.history, l.date)
+		l.districts = make([]apiV2Bezirk, 0, len(l.rows))
+		for _, row := range l.rows {
+			l.districts = append(l.districts, l.api.newApiV2Bezirk(row))
+		}
+	})
+	return l.districts, l.districtsErr
+}
+
+//getBezirkeOf returns the districts of a province by name, GKZ or ISO 3166-2 code, all districts if province is ""
+func (l *graphqlLoader) getBezirkeOf(province string) ([]apiV2Bezirk, error) {
+	districts, err := l.getBezirke()
+	if err != nil || province == "" {
+		return districts, err
+	}
+	result := make([]apiV2Bezirk, 0)
+	for i, row := range l.rows {
+		if matchesProvince(province, row.province, l.api.he.mp.getId(row.province)) {
+			result = append(result, districts[i])
+		}
+	}
+	return result, nil
+}
+
+func (l *graphqlLoader) findBundesland(id string) (interface{}, error) {
+	provinces, err := l.getBundeslaender()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range provinces {
+		if matchesProvince(id, p.Name, p.GKZ) {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+func (l *graphqlLoader) findBezirk(id string) (interface{}, error) {
+	districts, err := l.getBezirke()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range districts {
+		if matchesBezirk(id, d.Name, d.ID) {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
+
+//getHistory pages the values of a metric of a region between from and to, after is the date of the last value of
+//the previous page
+func (l *graphqlLoader) getHistory(field string, name string, args map[string]interface{}) (graphqlHistory, error) {
+	if l.history == nil {
+		return graphqlHistory{}, errors.New("History is disabled")
+	}
+	var from, to, after time.Time
+	var err error
+	for _, arg := range []struct {
+		name  string
+		value *time.Time
+	}{{"from", &from}, {"to", &to}, {"after", &after}} {
+		if s, ok := args[arg.name].(string); ok && s != "" {
+			if *arg.value, err = time.Parse(snapshotLayout, s); err != nil {
+				return graphqlHistory{}, err
+			}
+		}
+	}
+	if !after.IsZero() && after.AddDate(0, 0, 1).After(from) {
+		from = after.AddDate(0, 0, 1)
+	}
+	points, err := l.history.query(args["metric"].(string), regionMatcher(field, name), from, to)
+	if err != nil {
+		return graphqlHistory{}, err
+	}
+	result := graphqlHistory{points: points, totalCount: len(points)}
+	if first, ok := args["first"].(int); ok && first >= 0 && first < len(points) {
+		result.points, result.hasNextPage = points[:first], true
+	}
+	return result, nil
+}
+
+func graphqlLoaderOf(p graphql.ResolveParams) *graphqlLoader {
+	return p.Context.Value(graphqlContextKey{}).(*graphqlLoader)
+}
+
+var graphqlLocation = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Location",
+	Fields: graphql.Fields{
+		"latitude":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"longitude": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+	},
+})
+
+var graphqlTests = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Tests",
+	Fields: graphql.Fields{
+		"total":               &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"pcr":                 &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"antigen":             &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"daily":               &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"positivity7dPercent": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+	},
+})
+
+var graphqlVaccination = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Vaccination",
+	Fields: graphql.Fields{
+		"doses":              &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"firstDose":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"secondDose":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"boosterDose":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"firstDosePercent":   &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"secondDosePercent":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"boosterDosePercent": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+	},
+})
+
+var graphqlAgeGroupType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AgeGroup",
+	Fields: graphql.Fields{
+		"group": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"value": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var graphqlHistoryPoint = graphql.NewObject(graphql.ObjectConfig{
+	Name: "HistoryPoint",
+	Fields: graphql.Fields{
+		"date":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"metric": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(historyPoint).Name, nil }},
+		"value":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+	},
+})
+
+var graphqlHistoryEdge = graphql.NewObject(graphql.ObjectConfig{
+	Name: "HistoryEdge",
+	Fields: graphql.Fields{
+		"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(historyPoint).Date, nil }},
+		"node":   &graphql.Field{Type: graphql.NewNonNull(graphqlHistoryPoint), Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source, nil }},
+	},
+})
+
+var graphqlPageInfo = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(graphqlHistory).hasNextPage, nil }},
+		"endCursor": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			points := p.Source.(graphqlHistory).points
+			if len(points) == 0 {
+				return nil, nil
+			}
+			return points[len(points)-1].Date, nil
+		}},
+	},
+})
+
+var graphqlHistoryConnection = graphql.NewObject(graphql.ObjectConfig{
+	Name: "HistoryConnection",
+	Fields: graphql.Fields{
+		"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(graphqlHistory).totalCount, nil }},
+		"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(graphqlPageInfo), Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source, nil }},
+		"edges":      &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphqlHistoryEdge)), Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(graphqlHistory).points, nil }},
+		"nodes":      &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphqlHistoryPoint)), Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(graphqlHistory).points, nil }},
+	},
+})
+
+//graphqlHistoryField is the history connection of a region, region returns the history field and name of the source
+func graphqlHistoryField(defaultMetric string, region func(source interface{}) (string, string)) *graphql.Field {
+	return &graphql.Field{
+		Type:        graphql.NewNonNull(graphqlHistoryConnection),
+		Description: "Daily values, requires -history-dir",
+		Args: graphql.FieldConfigArgument{
+			"metric": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: defaultMetric},
+			"from":   &graphql.ArgumentConfig{Type: graphql.String, Description: "YYYY-MM-DD"},
+			"to":     &graphql.ArgumentConfig{Type: graphql.String, Description: "YYYY-MM-DD"},
+			"first":  &graphql.ArgumentConfig{Type: graphql.Int},
+			"after":  &graphql.ArgumentConfig{Type: graphql.String, Description: "endCursor of the previous page"},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			field, name := region(p.Source)
+			return graphqlLoaderOf(p).getHistory(field, name, p.Args)
+		},
+	}
+}
+
+func newGraphqlSchema() graphql.Schema {
+	var bundesland, bezirk *graphql.Object
+	bundesland = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Bundesland",
+		Description: "Province, id is the ISO 3166-2 code",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":                              &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"gkz":                             &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"name":                            &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"location":                        &graphql.Field{Type: graphql.NewNonNull(graphqlLocation)},
+				"population":                      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"infected":                        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"infectedPer100k":                 &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+				"healed":                          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"dead":                            &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"hospitalized":                    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"intensiveCare":                   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"hospitalFreeBeds":                &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"intensiveCareFreeBeds":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"hospitalUtilisationPercent":      &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+				"intensiveCareUtilisationPercent": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+				"tests":                           &graphql.Field{Type: graphql.NewNonNull(graphqlTests)},
+				"vaccination":                     &graphql.Field{Type: graphql.NewNonNull(graphqlVaccination)},
+				"bezirke": &graphql.Field{
+					Type: graphql.NewList(graphql.NewNonNull(bezirk)),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return graphqlLoaderOf(p).getBezirkeOf(p.Source.(apiV2Bundesland).ID)
+					},
+				},
+				"history": graphqlHistoryField("cov19_detail", func(source interface{}) (string, string) {
+					return "province", source.(apiV2Bundesland).Name
+				}),
+			}
+		}),
+	})
+	bezirk = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Bezirk",
+		Description: "District, id is the GKZ",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":                 &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"name":               &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"location":           &graphql.Field{Type: graphql.NewNonNull(graphqlLocation)},
+				"population":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"infected":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"infectedPer100k":    &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+				"incidence7dPer100k": &graphql.Field{Type: graphql.Float, Description: "null without history"},
+				"bundesland": &graphql.Field{
+					Type: bundesland,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return graphqlLoaderOf(p).findBundesland(p.Source.(apiV2Bezirk).ProvinceID)
+					},
+				},
+				"history": graphqlHistoryField("cov19_bezirk_infected", func(source interface{}) (string, string) {
+					return "bezirk", source.(apiV2Bezirk).Name
+				}),
+			}
+		}),
+	})
+	country := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Country",
+		Fields: graphql.Fields{
+			"id":              &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":            &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"population":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"infected":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"infectedPer100k": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"dead":            &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"hospitalized":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"intensiveCare":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"tests":           &graphql.Field{Type: graphql.NewNonNull(graphqlTests)},
+			"vaccination":     &graphql.Field{Type: graphql.NewNonNull(graphqlVaccination)},
+			"infectedByAgeGroup": &graphql.Field{
+				Type: graphql.NewList(graphql.NewNonNull(graphqlAgeGroupType)),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					groups := p.Source.(apiV2Total).InfectedByAgeGroup
+					result := make([]graphqlAgeGroup, 0, len(groups))
+					for group, value := range groups {
+						result = append(result, graphqlAgeGroup{group, value})
+					}
+					sort.Slice(result, func(i, j int) bool { return result[i].Group < result[j].Group })
+					return result, nil
+				},
+			},
+			"bundeslaender": &graphql.Field{
+				Type:    graphql.NewList(graphql.NewNonNull(bundesland)),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return graphqlLoaderOf(p).getBundeslaender() },
+			},
+			"history": graphqlHistoryField("cov19_confirmed", func(interface{}) (string, string) { return "", "" }),
+		},
+	})
+	id := graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String), Description: "Name, GKZ or ISO 3166-2 code"}}
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"country": &graphql.Field{
+				Type:    country,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return graphqlLoaderOf(p).getTotal() },
+			},
+			"bundeslaender": &graphql.Field{
+				Type:    graphql.NewList(graphql.NewNonNull(bundesland)),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return graphqlLoaderOf(p).getBundeslaender() },
+			},
+			"bundesland": &graphql.Field{
+				Type: bundesland,
+				Args: id,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return graphqlLoaderOf(p).findBundesland(p.Args["id"].(string))
+				},
+			},
+			"bezirke": &graphql.Field{
+				Type: graphql.NewList(graphql.NewNonNull(bezirk)),
+				Args: graphql.FieldConfigArgument{"bundesland": &graphql.ArgumentConfig{Type: graphql.String, Description: "Name, GKZ or ISO 3166-2 code of the province"}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					province, _ := p.Args["bundesland"].(string)
+					return graphqlLoaderOf(p).getBezirkeOf(province)
+				},
+			},
+			"bezirk": &graphql.Field{
+				Type: bezirk,
+				Args: id,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return graphqlLoaderOf(p).findBezirk(p.Args["id"].(string))
+				},
+			},
+		},
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query})
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+var graphqlSchema = newGraphqlSchema()
+
+//parseGraphqlRequest reads the request from a json body or the query, operationName and variables parameters
+func parseGraphqlRequest(r *http.Request) (graphqlRequest, error) {
+	request := graphqlRequest{}
+	if r.Method == http.MethodPost {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return request, err
+		}
+		err = json.Unmarshal(body, &request)
+		return request, err
+	}
+	values := r.URL.Query()
+	request.Query = values.Get("query")
+	request.OperationName = values.Get("operationName")
+	if v := values.Get("variables"); v != "" {
+		if err := json.Unmarshal([]byte(v), &request.Variables); err != nil {
+			return request, err
+		}
+	}
+	return request, nil
+}
+
+//executeGraphql runs a request against the current stats and history
+func executeGraphql(ctx context.Context, loader *graphqlLoader, request graphqlRequest) *graphql.Result {
+	return graphql.Do(graphql.Params{
+		Schema:         graphqlSchema,
+		RequestString:  request.Query,
+		OperationName:  request.OperationName,
+		VariableValues: request.Variables,
+		Context:        context.WithValue(ctx, graphqlContextKey{}, loader),
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runGraphql(t *testing.T, loader *graphqlLoader, query string, variables map[string]interface{}) string {
+	result := executeGraphql(context.Background(), loader, graphqlRequest{Query: query, Variables: variables})
+	content, err := json.Marshal(result)
+	assert.Nil(t, err)
+	return string(content)
+}
+
+func TestGraphql(t *testing.T) {
+	testApi, cleanup := newTestDashboardApi(t)
+	defer cleanup()
+	store, cleanupHistory := newTestHistory(t)
+	defer cleanupHistory()
+	date := time.Date(2020, 11, 9, 10, 0, 0, 0, time.UTC)
+	for i := 3; i >= 1; i-- {
+		assert.Nil(t, store.record(date.AddDate(0, 0, -i), metrics{
+			{"cov19_confirmed", nil, float64(1700 - 100*i)},
+			{"cov19_detail", &map[string]string{"province": "Wien", "country": "Austria"}, float64(1200 - 50*i)},
+		}))
+	}
+	loader := newGraphqlLoader(testApi, store, date)
+
+	assert.JSONEq(t, `{"data":{"country":{"id":"AT","infected":1700,"infectedByAgeGroup":[{"group":"<5","value":10}],
+		"bundeslaender":[{"id":"AT-9","name":"Wien","bezirke":[{"id":"900","name":"Wien(Stadt)"}]},{"id":"AT-6","name":"Steiermark","bezirke":[{"id":"601","name":"Graz(Stadt)"}]}]}}}`,
+		runGraphql(t, loader, `{ country { id infected infectedByAgeGroup { group value } bundeslaender { id name bezirke { id name } } } }`, nil))
+
+	assert.JSONEq(t, `{"data":{"bezirk":{"name":"Graz(Stadt)","population":288806,"location":{"latitude":47.070714},"incidence7dPer100k":null,"bundesland":{"gkz":"6"}}}}`,
+		runGraphql(t, loader, `query($id: String!) { bezirk(id: $id) { name population location { latitude } incidence7dPer100k bundesland { gkz } } }`, map[string]interface{}{"id": "601"}))
+
+	assert.JSONEq(t, `{"data":{"bezirke":[{"name":"Graz(Stadt)"}],"bundesland":null}}`,
+		runGraphql(t, loader, `{ bezirke(bundesland: "Steiermark") { name } bundesland(id: "AT-4") { name } }`, nil))
+
+	assert.JSONEq(t, `{"data":{"bundesland":{"history":{"totalCount":3,"pageInfo":{"hasNextPage":true,"endCursor":"2020-11-07"},
+		"edges":[{"cursor":"2020-11-06","node":{"date":"2020-11-06","metric":"cov19_detail","value":1050}},{"cursor":"2020-11-07","node":{"date":"2020-11-07","metric":"cov19_detail","value":1100}}]}}}}`,
+		runGraphql(t, loader, `{ bundesland(id: "wien") { history(first: 2) { totalCount pageInfo { hasNextPage endCursor } edges { cursor node { date metric value } } } } }`, nil))
+
+	assert.JSONEq(t, `{"data":{"country":{"history":{"totalCount":1,"nodes":[{"value":1600}]}}}}`,
+		runGraphql(t, loader, `{ country { history(after: "2020-11-07", to: "2020-11-09") { totalCount nodes { value } } } }`, nil))
+
+	content := runGraphql(t, newGraphqlLoader(testApi, nil, date), `{ country { history { totalCount } } }`, nil)
+	assert.Contains(t, content, `"message":"History is disabled"`)
+	content = runGraphql(t, loader, `{ country { altitude } }`, nil)
+	assert.Contains(t, content, `Cannot query field \"altitude\" on type \"Country\".`)
+}
+
+func TestHandleGraphql(t *testing.T) {
+	testApi, cleanup := newTestDashboardApi(t)
+	defer cleanup()
+	defer func(previousApi *api, previousHistory *historyStore) { a, history = previousApi, previousHistory }(a, history)
+	a, history = testApi, nil
+
+	recorder := httptest.NewRecorder()
+	handleGraphql(recorder, httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"query Total { country { infected } }","operationName":"Total"}`)))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json; charset=utf-8", recorder.Header().Get("Content-type"))
+	assert.JSONEq(t, `{"data":{"country":{"infected":1700}}}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	handleGraphql(recorder, httptest.NewRequest("GET", `/graphql?query=query($id:String!){bezirk(id:$id){infected}}&variables={"id":"900"}`, nil))
+	assert.JSONEq(t, `{"data":{"bezirk":{"infected":1200}}}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	handleGraphql(recorder, httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":`)))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"time"
 )
 
 type healthMinistryExporter struct {
@@ -10,7 +13,9 @@ type healthMinistryExporter struct {
 	url string
 }
 
-type ministryStat []struct {
+type ministryStat []ministryEntry
+
+type ministryEntry struct {
 	Label string
 	Y     uint64
 	Z     uint64
@@ -98,7 +103,7 @@ func (h *healthMinistryExporter) Health() []error {
 	return errors
 }
 
-func (h *healthMinistryExporter) getTags(location string, fieldName string, data *metaData) *map[string]string {
+func getAustrianTags(location string, fieldName string, data *metaData) *map[string]string {
 	if data != nil {
 		return &map[string]string{fieldName: location, "country": "Austria", "longitude": ftos(data.location.long), "latitude": ftos(data.location.lat)}
 	}
@@ -115,16 +120,21 @@ func (h *healthMinistryExporter) getBezirke() (metrics, error) {
 	if err != nil {
 		return nil, err
 	}
+	return h.bezirkMetrics(bezirkeStats), nil
+}
+
+//bezirkMetrics returns the metrics of the infections by district, also used to backfill historical data
+func (h *healthMinistryExporter) bezirkMetrics(stats ministryStat) metrics {
 	result := make(metrics, 0)
-	for _, s := range bezirkeStats {
+	for _, s := range stats {
 		data := h.mp.getMetadata(s.Label)
-		tags := h.getTags(s.Label, "bezirk", data)
+		tags := getAustrianTags(s.Label, "bezirk", data)
 		result = append(result, metric{"cov19_bezirk_infected", tags, float64(s.Y)})
 		if data != nil {
 			result = append(result, metric{"cov19_bezirk_infected_100k", tags, float64(infection100k(s.Y, data.population))})
 		}
 	}
-	return result, nil
+	return result
 }
 
 func (h *healthMinistryExporter) getBezirkStat() ([]bezirkStat, error) {
@@ -179,17 +189,65 @@ func (h *healthMinistryExporter) getBundeslandInfections() (metrics, error) {
 	if err != nil {
 		return nil, err
 	}
+	for i := range provinceStats {
+		provinceStats[i].Label = mapBundeslandLabel(provinceStats[i].Label)
+	}
+	return h.bundeslandMetrics(provinceStats), nil
+}
+
+//bundeslandMetrics returns the metrics of the infections by province, the labels are the full province names
+func (h *healthMinistryExporter) bundeslandMetrics(stats ministryStat) metrics {
 	result := make(metrics, 0)
-	for _, s := range provinceStats {
-		s.Label = mapBundeslandLabel(s.Label)
+	for _, s := range stats {
 		data := h.mp.getMetadata(s.Label)
-		tags := h.getTags(s.Label, "province", data)
+		tags := getAustrianTags(s.Label, "province", data)
 		result = append(result, metric{"cov19_detail", tags, float64(s.Y)})
 		if data != nil {
 			result = append(result, metric{"cov19_detail_infected_per_100k", tags, float64(infection100k(s.Y, data.population))})
 			result = append(result, metric{"cov19_detail_infection_rate", tags, float64(infectionRate(s.Y, data.population))})
 		}
 	}
+	return result
+}
+
+func (h *healthMinistryExporter) getBundeslandStat() ([]bundeslandStat, error) {
+	arrayString, err := readArrayFromGet(h.url + "/Bundesland.js")
+	if err != nil {
+		return nil, err
+	}
+	provinceStats := ministryStat{}
+	err = json.Unmarshal([]byte(arrayString), &provinceStats)
+	if err != nil {
+		return nil, err
+	}
+	arrayString, err = readArrayFromGet(h.url + "/GenesenTodesFaelleBL.js")
+	if err != nil {
+		return nil, err
+	}
+	healedDeathStats := ministryStat{}
+	err = json.Unmarshal([]byte(arrayString), &healedDeathStats)
+	if err != nil {
+		return nil, err
+	}
+	healedDeaths := make(map[string]struct{ healed, dead uint64 })
+	for _, s := range healedDeathStats {
+		healedDeaths[s.Label] = struct{ healed, dead uint64 }{s.Y, s.Z}
+	}
+
+	result := make([]bundeslandStat, 0)
+	for _, s := range provinceStats {
+		name := mapBundeslandLabel(s.Label)
+		stat := bundeslandStat{Name: name, Infected: s.Y}
+		if data := h.mp.getMetadata(name); data != nil {
+			stat.Location = apiLocaiton{Lat: data.location.lat, Long: data.location.long}
+			stat.Population = data.population
+		}
+		if hd, ok := healedDeaths[name]; ok {
+			stat.Healed = hd.healed
+			stat.Dead = hd.dead
+		}
+		result = append(result, stat)
+	}
 	return result, nil
 }
 
@@ -203,14 +261,19 @@ func (h *healthMinistryExporter) getBundeslandHealedDeaths() (metrics, error) {
 	if err != nil {
 		return nil, err
 	}
+	return h.healedDeathMetrics(provinceStats), nil
+}
+
+//healedDeathMetrics returns the metrics of the healed (Y) and dead (Z) by province
+func (h *healthMinistryExporter) healedDeathMetrics(stats ministryStat) metrics {
 	result := make(metrics, 0)
-	for _, s := range provinceStats {
+	for _, s := range stats {
 		data := h.mp.getMetadata(s.Label)
-		tags := h.getTags(s.Label, "province", data)
+		tags := getAustrianTags(s.Label, "province", data)
 		result = append(result, metric{"cov19_detail_healed", tags, float64(s.Y)})
 		result = append(result, metric{"cov19_detail_dead", tags, float64(s.Z)})
 	}
-	return result, nil
+	return result
 }
 
 func (h *healthMinistryExporter) getAgeStat() (map[string]uint64, error) {
@@ -276,6 +339,19 @@ func addVarIfValid(errors []error, result metrics, url string, varName string, m
 	return errors, result
 }
 
+//getReportTime returns the time of the last update of the ministry data, e.g. LetzteAktualisierung = "09.11.2020 10:00.00"
+func (h *healthMinistryExporter) getReportTime() (time.Time, error) {
+	body, err := readFromGet(h.url + "/SimpleData.js")
+	if err != nil {
+		return time.Time{}, err
+	}
+	match := regexp.MustCompile(`LetzteAktualisierung = "(\d\d\.\d\d\.\d{4} \d\d:\d\d)`).FindStringSubmatch(string(body))
+	if len(match) != 2 {
+		return time.Time{}, errors.New("LetzteAktualisierung not found in /SimpleData.js")
+	}
+	return time.ParseInLocation("02.01.2006 15:04", match[1], viennaLocation())
+}
+
 func (h *healthMinistryExporter) getSimpleData() (metrics, []error) {
 	errors := make([]error, 0)
 	result := make(metrics, 0)
@@ -1,8 +1,12 @@
 package main
 
 import (
-	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 var e = newHealthMinistryExporter()
@@ -102,3 +106,20 @@ func TestHealthMinistryGetMetrics(t *testing.T) {
 	assert.Nil(t, err, err)
 	assert.NotNil(t, result)
 }
+
+func TestHealthMinistryReportTime(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/SimpleData.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`var Erkrankungen = "1.700"; var LetzteAktualisierung = "09.11.2020 10:00.00";`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	h := &healthMinistryExporter{url: ts.URL}
+	date, err := h.getReportTime()
+	assert.Nil(t, err)
+	assert.Equal(t, "2020-11-09T10:00:00+01:00", date.Format(time.RFC3339))
+
+	h.url = ts.URL + "/missing"
+	_, err = h.getReportTime()
+	assert.NotNil(t, err)
+}
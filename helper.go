@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"encoding/csv"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -48,14 +53,48 @@ func infection100k(infections uint64, population uint64) float64 {
 	return infectionRate(infections, population) * float64(100000)
 }
 
-func readArrayFromGet(url string) (string, error) {
-	client := http.Client{Timeout: 5 * time.Second}
+//sortedKeys returns the keys of a map in a stable order for the metrics output
+func sortedKeys[V any](m map[string]V) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func readFromGet(url string) ([]byte, error) {
+	return readFromGetWithTimeout(url, 5*time.Second)
+}
+
+func readFromGetWithTimeout(url string, timeout time.Duration) ([]byte, error) {
+	if isFileURL(url) {
+		return readFromFile(url)
+	}
+	if upstreamArchive != nil && upstreamArchive.isReplaying() {
+		return upstreamArchive.lookup(url, replay.get())
+	}
+	client := http.Client{Timeout: timeout}
 	response, err := client.Get(url)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer response.Body.Close()
-	json, err := ioutil.ReadAll(response.Body)
+	//Error pages are neither parsed nor archived, they would replace the data on replay
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("%s returned %s", url, response.Status)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err == nil && upstreamArchive != nil {
+		if err := upstreamArchive.store(url, response.Header, body, time.Now()); err != nil {
+			logger.Print(err)
+		}
+	}
+	return body, err
+}
+
+func readArrayFromGet(url string) (string, error) {
+	json, err := readFromGet(url)
 	if err != nil {
 		return "", err
 	}
@@ -74,13 +113,10 @@ func readArrayFromGet(url string) (string, error) {
 }
 
 func readJsVarFromGet(url string, varName string) (string, error) {
-	client := http.Client{Timeout: 5 * time.Second}
-	response, err := client.Get(url)
+	lines, err := readFromGet(url)
 	if err != nil {
 		return "", err
 	}
-	defer response.Body.Close()
-	lines, err := ioutil.ReadAll(response.Body)
 
 	match := regexp.MustCompile(varName + ` = "([0-9\.]+)"`).FindStringSubmatch(string(lines))
 	if len(match) != 2 {
@@ -88,3 +124,78 @@ func readJsVarFromGet(url string, varName string) (string, error) {
 	}
 	return strings.Replace(match[1], ".", "", 1), nil
 }
+
+func readCsvFromGet(url string, delimiter rune) ([][]string, error) {
+	body, err := readFromGet(url)
+	if err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(bytes.NewReader(body))
+	r.Comma = delimiter
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("Empty csv in " + url[strings.LastIndex(url, "/"):])
+	}
+	records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+	return records, nil
+}
+
+//csvColumns maps the header names of a csv file to their column index
+func csvColumns(header []string, required ...string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.TrimSpace(h)] = i
+	}
+	for _, r := range required {
+		if _, ok := columns[r]; !ok {
+			return nil, errors.New("Could not find column " + r)
+		}
+	}
+	return columns, nil
+}
+
+func percent(part uint64, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+//viennaLocation is the time zone of the Austrian data, the local time zone if the zone database is missing
+var vienna = loadViennaLocation()
+
+func loadViennaLocation() *time.Location {
+	location, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+//viennaLocation returns the same location on every call, times of a day can be compared with ==
+func viennaLocation() *time.Location {
+	return vienna
+}
+
+//calendarDay returns the start of the day in Vienna, the days of the history are Vienna days
+func calendarDay(t time.Time) time.Time {
+	year, month, day := t.In(viennaLocation()).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, viennaLocation())
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
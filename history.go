@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//historyStore keeps the last metrics snapshot of every day as json file. The recorded days and the snapshots that
+//were read are cached, so queries only read a file once.
+type historyStore struct {
+	dir string
+
+	mu    sync.Mutex
+	days  []time.Time
+	cache map[string]*historyDay
+}
+
+//historyDay is a snapshot indexed by metric name
+type historyDay struct {
+	snapshot metrics
+	byName   map[string]metrics
+}
+
+type historyPoint struct {
+	Date  string
+	Name  string
+	Tags  map[string]string
+	Value float64
+}
+
+func newHistoryStore(dir string) (*historyStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &historyStore{dir: dir, cache: make(map[string]*historyDay)}, nil
+}
+
+func newHistoryDay(snapshot metrics) *historyDay {
+	result := &historyDay{snapshot, make(map[string]metrics)}
+	for _, m := range snapshot {
+		result.byName[m.Name] = append(result.byName[m.Name], m)
+	}
+	return result
+}
+
+func (h *historyStore) filename(day string) string {
+	return filepath.Join(h.dir, day+".json")
+}
+
+func historyDayKey(date time.Time) string {
+	return date.In(viennaLocation()).Format(snapshotLayout)
+}
+
+//record replaces the snapshot of the given day, empty snapshots are ignored
+func (h *historyStore) record(date time.Time, m metrics) error {
+	if len(m) == 0 {
+		return nil
+	}
+	content, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	key := historyDayKey(date)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	tmp := h.filename(key) + ".tmp"
+	err = ioutil.WriteFile(tmp, content, 0644)
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, h.filename(key)); err != nil {
+		return err
+	}
+	if h.days != nil {
+		day, _ := time.ParseInLocation(snapshotLayout, key, viennaLocation())
+		h.days = insertDay(h.days, day)
+	}
+	h.cache[key] = newHistoryDay(m)
+	return nil
+}
+
+//insertDay adds a day to the sorted days unless it is already there
+func insertDay(days []time.Time, day time.Time) []time.Time {
+	i := sort.Search(len(days), func(i int) bool { return !days[i].Before(day) })
+	if i < len(days) && days[i].Equal(day) {
+		return days
+	}
+	days = append(days, time.Time{})
+	copy(days[i+1:], days[i:])
+	days[i] = day
+	return days
+}
+
+//day returns the snapshot of a day, from the cache if it was read before
+func (h *historyStore) day(date time.Time) (*historyDay, error) {
+	key := historyDayKey(date)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if cached, ok := h.cache[key]; ok {
+		return cached, nil
+	}
+	content, err := ioutil.ReadFile(h.filename(key))
+	if err != nil {
+		return nil, err
+	}
+	snapshot := make(metrics, 0)
+	if err = json.Unmarshal(content, &snapshot); err != nil {
+		return nil, err
+	}
+	result := newHistoryDay(snapshot)
+	h.cache[key] = result
+	return result, nil
+}
+
+func (h *historyStore) load(date time.Time) (metrics, error) {
+	day, err := h.day(date)
+	if err != nil {
+		return nil, err
+	}
+	return day.snapshot, nil
+}
+
+//dates returns all recorded days in ascending order, the directory is only read once
+func (h *historyStore) dates() ([]time.Time, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.days == nil {
+		entries, err := ioutil.ReadDir(h.dir)
+		if err != nil {
+			return nil, err
+		}
+		days := make([]time.Time, 0, len(entries))
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+				continue
+			}
+			if date, err := time.ParseInLocation(snapshotLayout, strings.TrimSuffix(e.Name(), ".json"), viennaLocation()); err == nil {
+				days = append(days, date)
+			}
+		}
+		sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+		h.days = days
+	}
+	return append([]time.Time(nil), h.days...), nil
+}
+
+//query returns the values of a metric between the days of from and to (inclusive) whose tags match
+func (h *historyStore) query(name string, match func(tags map[string]string) bool, from time.Time, to time.Time) ([]historyPoint, error) {
+	dates, err := h.dates()
+	if err != nil {
+		return nil, err
+	}
+	if !from.IsZero() {
+		from = calendarDay(from)
+	}
+	if !to.IsZero() {
+		to = calendarDay(to)
+	}
+	result := make([]historyPoint, 0)
+	for _, date := range dates {
+		if date.Before(from) || (!to.IsZero() && date.After(to)) {
+			continue
+		}
+		day, err := h.day(date)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range day.byName[name] {
+			tags := map[string]string{}
+			if m.Tags != nil {
+				tags = *m.Tags
+			}
+			if match(tags) {
+				result = append(result, historyPoint{date.Format(snapshotLayout), m.Name, tags, m.Value})
+			}
+		}
+	}
+	return result, nil
+}
+
+//byRegion groups the values of a metric between from and to by the normalized region name ("" for national metrics)
+func (h *historyStore) byRegion(name string, field string, from time.Time, to time.Time) (map[string][]historyPoint, error) {
+	result := make(map[string][]historyPoint)
+	if h == nil {
+		return result, nil
+	}
+	points, err := h.query(name, regionMatcher(field, ""), from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range points {
+		region := ""
+		if field != "" {
+			region = normalizeName(p.Tags[field])
+		}
+		result[region] = append(result[region], p)
+	}
+	return result, nil
+}
+
+//regionMatcher matches metrics of a region type (province, bezirk or "" for national metrics) and an optional name
+func regionMatcher(field string, name string) func(tags map[string]string) bool {
+	return func(tags map[string]string) bool {
+		if field == "" {
+			_, province := tags["province"]
+			_, bezirk := tags["bezirk"]
+			_, group := tags["group"]
+			return !province && !bezirk && !group
+		}
+		value, ok := tags[field]
+		if !ok {
+			return false
+		}
+		if _, group := tags["group"]; group {
+			return false
+		}
+		return name == "" || normalizeName(value) == normalizeName(name)
+	}
+}
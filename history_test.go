@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type staticExporter struct {
+	metrics metrics
+}
+
+func (s *staticExporter) GetMetrics() (metrics, error) {
+	return s.metrics, nil
+}
+
+func (s *staticExporter) Health() []error {
+	return nil
+}
+
+func newTestHistory(t *testing.T) (*historyStore, func()) {
+	dir, err := ioutil.TempDir("", "history")
+	assert.Nil(t, err)
+	store, err := newHistoryStore(dir)
+	assert.Nil(t, err)
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func testSnapshot(confirmed float64, vienna float64) metrics {
+	return metrics{
+		{"cov19_confirmed", nil, confirmed},
+		{"cov19_detail", &map[string]string{"province": "Wien", "country": "Austria"}, vienna},
+		{"cov19_detail", &map[string]string{"province": "Tirol", "country": "Austria"}, confirmed - vienna},
+		{"cov19_bezirk_infected", &map[string]string{"bezirk": "Graz(Stadt)", "country": "Austria"}, 10},
+		{"cov19_vaccination_age_doses", &map[string]string{"province": "Wien", "group": "15-24"}, 1},
+	}
+}
+
+func TestHistoryQuery(t *testing.T) {
+	store, cleanup := newTestHistory(t)
+	defer cleanup()
+
+	day := time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC)
+	assert.Nil(t, store.record(day, testSnapshot(100, 40)))
+	assert.Nil(t, store.record(day.Add(24*time.Hour), testSnapshot(150, 60)))
+	assert.Nil(t, store.record(day.Add(48*time.Hour), testSnapshot(210, 90)))
+	assert.Nil(t, store.record(day.Add(72*time.Hour), metrics{}))
+
+	dates, err := store.dates()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(dates))
+
+	points, err := store.query("cov19_detail", regionMatcher("province", "wien"), day.Add(24*time.Hour), time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(points))
+	assert.Equal(t, "2020-03-21", points[0].Date)
+	assert.Equal(t, float64(60), points[0].Value)
+
+	points, err = store.query("cov19_detail", regionMatcher("province", ""), time.Time{}, day)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(points))
+
+	points, err = store.query("cov19_vaccination_age_doses", regionMatcher("province", ""), time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(points))
+
+	points, err = store.query("cov19_confirmed", regionMatcher("", ""), time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(points))
+	assert.Equal(t, float64(210), points[2].Value)
+}
+
+func TestHistoryCache(t *testing.T) {
+	store, cleanup := newTestHistory(t)
+	defer cleanup()
+
+	day := time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(store.dir, "2020-03-21.json"), []byte(`[{"Name":"cov19_confirmed","Value":150}]`), 0644))
+	assert.Nil(t, store.record(day.Add(48*time.Hour), testSnapshot(210, 90)))
+	points, err := store.query("cov19_confirmed", regionMatcher("", ""), time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(points))
+
+	//the days and the snapshots are only read once
+	assert.Nil(t, os.Remove(filepath.Join(store.dir, "2020-03-21.json")))
+	assert.Nil(t, store.record(day, testSnapshot(100, 40)))
+	points, err = store.query("cov19_confirmed", regionMatcher("", ""), time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{100, 150, 210}, []float64{points[0].Value, points[1].Value, points[2].Value})
+}
+
+func TestSchedulerRecordsHistory(t *testing.T) {
+	store, cleanup := newTestHistory(t)
+	defer cleanup()
+	defer replay.set(time.Time{})
+
+	replay.set(time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC))
+	s := newScheduler([]Exporter{&staticExporter{testSnapshot(100, 40)}}, store)
+	assert.Equal(t, 5, len(s.refresh()))
+
+	current, updated := s.snapshot()
+	assert.Equal(t, 5, len(current))
+	assert.Equal(t, replay.get(), updated)
+
+	recorded, err := store.load(updated)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(recorded))
+}
+
+func TestSchedulerStart(t *testing.T) {
+	s := newScheduler([]Exporter{&staticExporter{testSnapshot(100, 40)}}, nil)
+	s.interval = time.Hour
+	current, _ := s.snapshot()
+	assert.Nil(t, current)
+
+	s.start()
+	s.start()
+	assert.Eventually(t, func() bool {
+		current, _ := s.snapshot()
+		return len(current) == 5
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestApiHistory(t *testing.T) {
+	store, cleanup := newTestHistory(t)
+	defer cleanup()
+	assert.Nil(t, store.record(time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC), testSnapshot(100, 40)))
+
+	ts := httptest.NewServer(http.HandlerFunc(handleApiHistoryBundesland))
+	defer ts.Close()
+
+	response, err := ts.Client().Get(ts.URL + "?name=Wien")
+	assert.Nil(t, err)
+	assert.Equal(t, 500, response.StatusCode)
+
+	history = store
+	defer func() { history = nil }()
+
+	response, err = ts.Client().Get(ts.URL + "?name=Wien&from=2020-03-01&to=2020-03-31")
+	assert.Nil(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	points := []historyPoint{}
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&points))
+	assert.Equal(t, []historyPoint{{"2020-03-20", "cov19_detail", map[string]string{"province": "Wien", "country": "Austria"}, 40}}, points)
+
+	response, err = ts.Client().Get(ts.URL + "?from=yesterday")
+	assert.Nil(t, err)
+	assert.Equal(t, 500, response.StatusCode)
+}
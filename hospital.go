@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type hospitalExporter struct {
+	mp  *metadataProvider
+	url string
+}
+
+type hospitalStat struct {
+	hospitalized      uint64
+	hospitalFree      uint64
+	intensiveCare     uint64
+	intensiveCareFree uint64
+}
+
+func newHospitalExporter(mp *metadataProvider) *hospitalExporter {
+	return &hospitalExporter{mp: mp, url: "https://covid19-dashboard.ages.at/data"}
+}
+
+func utilisation(occupied uint64, free uint64) float64 {
+	if occupied+free == 0 {
+		return 0
+	}
+	return float64(occupied) / float64(occupied+free)
+}
+
+func (h *hospitalExporter) GetMetrics() (metrics, error) {
+	stats, err := h.getHospitalStat()
+	if err != nil {
+		return nil, err
+	}
+	result := make(metrics, 0)
+	for _, province := range sortedKeys(stats) {
+		s := stats[province]
+		tags := getAustrianTags(province, "province", h.mp.getMetadata(province))
+		result = append(result, metric{"cov19_hospitalized_detail", tags, float64(s.hospitalized)})
+		result = append(result, metric{"cov19_hospital_free_detail", tags, float64(s.hospitalFree)})
+		result = append(result, metric{"cov19_hospital_utilisation_detail", tags, utilisation(s.hospitalized, s.hospitalFree)})
+		result = append(result, metric{"cov19_intensive_care_detail", tags, float64(s.intensiveCare)})
+		result = append(result, metric{"cov19_intensive_care_free_detail", tags, float64(s.intensiveCareFree)})
+		result = append(result, metric{"cov19_intensive_care_utilisation_detail", tags, utilisation(s.intensiveCare, s.intensiveCareFree)})
+	}
+	return result, nil
+}
+
+func (h *hospitalExporter) Health() []error {
+	errors := make([]error, 0)
+	stats, err := h.getHospitalStat()
+	if err != nil {
+		return append(errors, err)
+	}
+	if len(stats) != 9 {
+		errors = append(errors, fmt.Errorf("Missing hospital results for provinces: %d", len(stats)))
+	}
+	return errors
+}
+
+//getHospitalStat returns the bed occupancy and capacity of the latest report per province
+func (h *hospitalExporter) getHospitalStat() (map[string]hospitalStat, error) {
+	records, err := readCsvFromGet(h.url+"/CovidFallzahlen.csv", ';')
+	if err != nil {
+		return nil, err
+	}
+	c, err := csvColumns(records[0], "Meldedat", "FZHosp", "FZICU", "FZHospFree", "FZICUFree", "BundeslandID", "Bundesland")
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]time.Time)
+	result := make(map[string]hospitalStat)
+	for _, row := range records[1:] {
+		if row[c["BundeslandID"]] == "10" {
+			continue
+		}
+		date, err := time.Parse("02.01.2006", row[c["Meldedat"]])
+		if err != nil {
+			return nil, err
+		}
+		province := row[c["Bundesland"]]
+		if date.Before(latest[province]) {
+			continue
+		}
+		latest[province] = date
+		result[province] = hospitalStat{
+			hospitalized:      atoi(row[c["FZHosp"]]),
+			hospitalFree:      atoi(row[c["FZHospFree"]]),
+			intensiveCare:     atoi(row[c["FZICU"]]),
+			intensiveCareFree: atoi(row[c["FZICUFree"]]),
+		}
+	}
+	return result, nil
+}
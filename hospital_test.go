@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const hospitalCsv = "Meldedat;TestGesamt;MeldeDatum;FZHosp;FZICU;FZHospFree;FZICUFree;BundeslandID;Bundesland\n" +
+	"01.04.2020;1000;01.04.2020 00:00:00;300;100;900;100;9;Wien\n" +
+	"02.04.2020;1200;02.04.2020 00:00:00;350;120;850;80;9;Wien\n" +
+	"02.04.2020;500;02.04.2020 00:00:00;40;10;160;30;5;Salzburg\n" +
+	"02.04.2020;1700;02.04.2020 00:00:00;390;130;1010;110;10;Alle\n"
+
+func TestHospitalStat(t *testing.T) {
+	hc := newHospitalExporter(e.mp)
+	serveTestContent(t, &hc.url, hospitalCsv)
+
+	result, err := hc.getHospitalStat()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, hospitalStat{350, 850, 120, 80}, result["Wien"])
+	assert.Equal(t, hospitalStat{40, 160, 10, 30}, result["Salzburg"])
+}
+
+func TestHospitalMetrics(t *testing.T) {
+	hc := newHospitalExporter(e.mp)
+	serveTestContent(t, &hc.url, hospitalCsv)
+
+	result, err := hc.GetMetrics()
+	assert.Nil(t, err)
+	assert.Equal(t, 12, len(result))
+	assert.Equal(t, "Salzburg", (*result[0].Tags)["province"])
+	assert.Equal(t, "Wien", (*result[6].Tags)["province"])
+
+	icu := assertMetric(t, result, "cov19_intensive_care_detail", "province=Wien", 120)
+	assert.Equal(t, "16.374817", (*icu.Tags)["longitude"])
+	assertMetric(t, result, "cov19_intensive_care_utilisation_detail", "province=Wien", 0.6)
+	assertMetric(t, result, "cov19_hospitalized_detail", "province=Salzburg", 40)
+	assertMetric(t, result, "cov19_hospital_free_detail", "province=Salzburg", 160)
+}
+
+func TestHospitalHealth(t *testing.T) {
+	hc := newHospitalExporter(e.mp)
+	serveTestContent(t, &hc.url, hospitalCsv)
+	assert.Equal(t, 1, len(hc.Health()))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type locateResult struct {
+	Bezirk bezirkStat
+}
+
+//matchesBezirk checks if a district id is its name or GKZ
+func matchesBezirk(id string, name string, gkz string) bool {
+	return normalizeName(id) == normalizeName(name) || (gkz != "" && id == gkz)
+}
+
+//matchesProvince checks if a province id is its name, GKZ or ISO 3166-2 code
+func matchesProvince(id string, name string, gkz string) bool {
+	if name != "" && normalizeName(id) == normalizeName(name) {
+		return true
+	}
+	return gkz != "" && (id == gkz || strings.EqualFold(id, provinceIso(gkz)))
+}
+
+//findBezirk returns the district with the given name or GKZ
+func (a *api) findBezirk(history *historyStore, date time.Time, id string) (bezirkRow, bool, error) {
+	rows, err := a.getBezirkRows(history, date)
+	if err != nil {
+		return bezirkRow{}, false, err
+	}
+	for _, row := range rows {
+		if matchesBezirk(id, row.Name, row.gkz) {
+			return row, true, nil
+		}
+	}
+	return bezirkRow{}, false, nil
+}
+
+//findBundesland returns the province with the given name, GKZ or ISO 3166-2 code
+func (a *api) findBundesland(id string) (bundeslandStat, bool, error) {
+	stats, err := a.GetBundeslandStat()
+	if err != nil {
+		return bundeslandStat{}, false, err
+	}
+	for _, s := range stats {
+		if matchesProvince(id, s.Name, a.he.mp.getId(s.Name)) {
+			return s, true, nil
+		}
+	}
+	return bundeslandStat{}, false, nil
+}
+
+func (a *api) findBundeslandV2(id string) (apiV2Bundesland, bool, error) {
+	stats, err := a.GetBundeslandStatV2()
+	if err != nil {
+		return apiV2Bundesland{}, false, err
+	}
+	for _, s := range stats {
+		if matchesProvince(id, s.Name, s.GKZ) {
+			return s, true, nil
+		}
+	}
+	return apiV2Bundesland{}, false, nil
+}
+
+//locateBezirk returns the district whose boundary contains the point, points outside of all boundaries are not found
+func (a *api) locateBezirk(history *historyStore, date time.Time, point apiLocaiton) (bezirkRow, bool, error) {
+	if a.bezirkBoundaries == nil {
+		return bezirkRow{}, false, errors.New("No district boundaries, see geo/README.md")
+	}
+	rows, err := a.getBezirkRows(history, date)
+	if err != nil {
+		return bezirkRow{}, false, err
+	}
+	for _, row := range rows {
+		if b := a.bezirkBoundaries.getBoundary(row.gkz, row.Name); b != nil && b.contains(point.Long, point.Lat) {
+			return row, true, nil
+		}
+	}
+	return bezirkRow{}, false, nil
+}
+
+//parsePoint reads the lat and lon query parameters
+func parsePoint(values url.Values) (apiLocaiton, error) {
+	lat, err := strconv.ParseFloat(values.Get("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return apiLocaiton{}, errors.New("lat must be a latitude")
+	}
+	long, err := strconv.ParseFloat(values.Get("lon"), 64)
+	if err != nil || long < -180 || long > 180 {
+		return apiLocaiton{}, errors.New("lon must be a longitude")
+	}
+	return apiLocaiton{lat, long}, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesRegion(t *testing.T) {
+	assert.True(t, matchesBezirk("graz (stadt)", "Graz(Stadt)", "601"))
+	assert.True(t, matchesBezirk("601", "Graz(Stadt)", "601"))
+	assert.False(t, matchesBezirk("AT-6", "Graz(Stadt)", "601"))
+	assert.False(t, matchesBezirk("", "Gröbming", ""))
+
+	assert.True(t, matchesProvince("steiermark", "Steiermark", "6"))
+	assert.True(t, matchesProvince("6", "Steiermark", "6"))
+	assert.True(t, matchesProvince("at-6", "Steiermark", "6"))
+	assert.False(t, matchesProvince("AT-9", "Steiermark", "6"))
+}
+
+func TestParsePoint(t *testing.T) {
+	point, err := parsePoint(url.Values{"lat": {"47.07"}, "lon": {"15.44"}})
+	assert.Nil(t, err)
+	assert.Equal(t, apiLocaiton{47.07, 15.44}, point)
+	_, err = parsePoint(url.Values{"lat": {"91"}, "lon": {"15.44"}})
+	assert.EqualError(t, err, "lat must be a latitude")
+	_, err = parsePoint(url.Values{"lat": {"47.07"}})
+	assert.EqualError(t, err, "lon must be a longitude")
+}
+
+func TestFind(t *testing.T) {
+	testApi, cleanup := newTestDashboardApi(t)
+	defer cleanup()
+
+	row, found, err := testApi.findBezirk(nil, time.Now(), "900")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "Wien(Stadt)", row.Name)
+	_, found, err = testApi.findBezirk(nil, time.Now(), "Linz(Stadt)")
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	for _, id := range []string{"Steiermark", "6", "AT-6"} {
+		stat, found, err := testApi.findBundesland(id)
+		assert.Nil(t, err)
+		assert.True(t, found, id)
+		assert.Equal(t, "Steiermark", stat.Name, id)
+	}
+	stat, found, err := testApi.findBundeslandV2("wien")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "AT-9", stat.ID)
+	_, found, _ = testApi.findBundesland("AT-4")
+	assert.False(t, found)
+}
+
+func TestLocateBezirk(t *testing.T) {
+	testApi, cleanup := newTestDashboardApi(t)
+	defer cleanup()
+
+	_, _, err := testApi.locateBezirk(nil, time.Now(), apiLocaiton{47.1, 15.45})
+	assert.NotNil(t, err)
+
+	testApi.bezirkBoundaries, err = parseBoundaries([]byte(testBoundaries))
+	assert.Nil(t, err)
+	located, found, err := testApi.locateBezirk(nil, time.Now(), apiLocaiton{48.29, 16.49})
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "Wien(Stadt)", located.Name)
+
+	located, found, err = testApi.locateBezirk(nil, time.Now(), apiLocaiton{47.1, 15.45})
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "Graz(Stadt)", located.Name)
+
+	//close to Graz but outside of its boundary
+	_, found, err = testApi.locateBezirk(nil, time.Now(), apiLocaiton{47.2, 15.45})
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	_, found, err = testApi.locateBezirk(nil, time.Now(), apiLocaiton{52.52, 13.4})
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+//newLocateTestApi serves the districts from a mirror and locates them with the boundaries
+func newLocateTestApi(t *testing.T, boundaries *boundaryProvider, districts ...string) *api {
+	labels := make([]string, 0, len(districts))
+	for _, d := range districts {
+		labels = append(labels, `{"label":"`+d+`","y":10}`)
+	}
+	dir := t.TempDir()
+	writeSnapshot(t, dir, "", "Bezirke.js", "var dpBezirke = ["+strings.Join(labels, ",")+"];")
+	return &api{he: &healthMinistryExporter{mp: e.mp, url: "file://" + filepath.ToSlash(dir)}, bezirkBoundaries: boundaries}
+}
+
+func assertLocated(t *testing.T, locateApi *api, lat float64, long float64, expected string) {
+	located, found, err := locateApi.locateBezirk(nil, time.Now(), apiLocaiton{lat, long})
+	assert.Nil(t, err)
+	if assert.True(t, found, "%f,%f", lat, long) {
+		assert.Equal(t, expected, located.Name, "%f,%f", lat, long)
+	}
+}
+
+//TestLocateAtBorder locates points 300 m on either side of the border between Graz(Stadt) and Graz-Umgebung in the
+//official format, the border runs from 15.5,47.02 to 15.52,47.13
+func TestLocateAtBorder(t *testing.T) {
+	boundaries, err := parseBoundaries([]byte(`{"type":"FeatureCollection","features":[
+{"type":"Feature","properties":{"g_name":"Graz(Stadt)","g_id":"601"},"geometry":{"type":"Polygon","coordinates":[[[15.35,47.0],[15.5,47.02],[15.52,47.13],[15.36,47.12],[15.35,47.0]]]}},
+{"type":"Feature","properties":{"g_name":"Graz-Umgebung","g_id":"606"},"geometry":{"type":"Polygon","coordinates":[[[15.5,47.02],[15.7,47.0],[15.7,47.15],[15.52,47.13],[15.5,47.02]]]}}
+]}`))
+	assert.Nil(t, err)
+	locateApi := newLocateTestApi(t, boundaries, "Graz(Stadt)", "Graz-Umgebung")
+
+	//the border crosses 47.075 at 15.51, 0.004° of longitude are 300 m
+	assertLocated(t, locateApi, 47.075, 15.506, "Graz(Stadt)")
+	assertLocated(t, locateApi, 47.075, 15.514, "Graz-Umgebung")
+	//the nearest location would be Graz-Umgebung (47.166,15.334) for this point in Graz(Stadt)
+	assertLocated(t, locateApi, 47.115, 15.365, "Graz(Stadt)")
+	//and Graz(Stadt) (47.071,15.44) for this one
+	assertLocated(t, locateApi, 47.03, 15.53, "Graz-Umgebung")
+}
+
+//TestLocateAtOfficialBorder locates the centres of Enns (Linz-Land) and Ennsdorf (Amstetten) on either side of the
+//river Enns with the boundaries of Statistik Austria
+func TestLocateAtOfficialBorder(t *testing.T) {
+	boundaries := newBoundaryProvider("geo/bezirke.geojson")
+	if boundaries == nil {
+		t.Skip("The boundaries of Statistik Austria are not in geo/, see geo/README.md")
+	}
+	locateApi := newLocateTestApi(t, boundaries, "Linz-Land", "Amstetten")
+	assertLocated(t, locateApi, 48.2133, 14.4754, "Linz-Land")
+	assertLocated(t, locateApi, 48.2110, 14.4975, "Amstetten")
+}
+
+func TestHandleLookup(t *testing.T) {
+	testApi, cleanup := newTestDashboardApi(t)
+	defer cleanup()
+	defer func(previousApi *api, previousHistory *historyStore) { a, history = previousApi, previousHistory }(a, history)
+	a, history = testApi, nil
+	var err error
+	testApi.bezirkBoundaries, err = parseBoundaries([]byte(testBoundaries))
+	assert.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	handleApiBezirkById(recorder, httptest.NewRequest("GET", "/api/bezirk/Graz%20(Stadt)", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"Name":"Graz(Stadt)","Location":{"Lat":47.070714,"Long":15.439504},"Population":288806,"Infected":340}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	handleApiBundeslandById(recorder, httptest.NewRequest("GET", "/api/bundesland/AT-4", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "Unknown province AT-4\n", recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	handleApiLocate(recorder, httptest.NewRequest("GET", "/api/locate?lat=x&lon=15", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handleApiV2BezirkById(recorder, httptest.NewRequest("GET", "/api/v2/bezirk/123", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.JSONEq(t, `{"error":"Unknown district 123"}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	handleApiLocate(recorder, httptest.NewRequest("GET", "/api/locate?lat=52.52&lon=13.4", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handleApiV2Locate(recorder, httptest.NewRequest("GET", "/api/v2/locate?lat=48.25&lon=16.4", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	response := struct {
+		Data apiV2Locate `json:"data"`
+	}{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "900", response.Data.Bezirk.ID)
+	assert.Equal(t, "AT-9", response.Data.Bezirk.ProvinceID)
+}
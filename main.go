@@ -1,23 +1,41 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var logger = log.New(os.Stdout, "covid19-at", 0)
 var mp = newMetadataProvider()
 var he = newHealthMinistryExporter()
+var ve = newVaccinationExporter(he.mp)
+var hc = newHospitalExporter(he.mp)
+var te = newTestingsExporter(he.mp)
+var ec = newEcdcExporter(mp)
+var md = newMathdroExporter()
 var exporters = []Exporter{
 	he,
-	newEcdcExporter(mp),
-	newMathdroExporter(),
+	ec,
+	md,
+	ve,
+	hc,
+	te,
 }
 
-var a = newApi(he)
+var a = newApi(he, ve, hc, te)
+var history *historyStore
+var sched *scheduler
 
 func writeJson(w http.ResponseWriter, f func() (interface{}, error)) {
 	result, err := f()
@@ -36,27 +54,364 @@ func writeJson(w http.ResponseWriter, f func() (interface{}, error)) {
 	}
 }
 
-func handleApiBundesland(w http.ResponseWriter, _ *http.Request) {
-	writeJson(w, func() (interface{}, error) { return a.GetBundeslandStat() })
+func handleApiBundesland(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, r, func() (interface{}, error) { return a.GetBundeslandStat() })
 }
 
-func handleApiBezirk(w http.ResponseWriter, _ *http.Request) {
-	writeJson(w, func() (interface{}, error) { return a.GetBezirkStat() })
+//handleBezirkQuery answers a district request with the filter, sort, pagination and field parameters
+func handleBezirkQuery(w http.ResponseWriter, r *http.Request, result reflect.Type, alwaysIncidence bool, write func(func() (interface{}, error)), convert func(bezirkRow) interface{}) {
+	query, err := parseBezirkQuery(r, result)
+	if err == nil && query.filterIncidence && history == nil {
+		err = errors.New("min_incidence requires -history-dir")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	write(func() (interface{}, error) {
+		var h *historyStore
+		if alwaysIncidence || query.needsIncidence() {
+			h = history
+		}
+		rows, err := a.getBezirkRows(h, now())
+		if err != nil {
+			return nil, err
+		}
+		items, total := query.apply(rows, a.he.mp, result, convert)
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		return items, nil
+	})
 }
 
-func handleApiTotal(w http.ResponseWriter, _ *http.Request) {
-	writeJson(w, func() (interface{}, error) { return a.GetOverallStat() })
+func handleApiBezirk(w http.ResponseWriter, r *http.Request) {
+	handleBezirkQuery(w, r, reflect.TypeOf(bezirkStat{}), false,
+		func(f func() (interface{}, error)) { writeResult(w, r, f) },
+		func(row bezirkRow) interface{} { return row.bezirkStat })
 }
 
-func handleMetrics(w http.ResponseWriter, _ *http.Request) {
+func handleApiBezirkById(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/bezirk/")
+	row, found, err := a.findBezirk(nil, now(), id)
+	if err == nil && !found {
+		http.Error(w, "Unknown district "+id, http.StatusNotFound)
+		return
+	}
+	writeResult(w, r, func() (interface{}, error) { return row.bezirkStat, err })
+}
+
+func handleApiBundeslandById(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/bundesland/")
+	stat, found, err := a.findBundesland(id)
+	if err == nil && !found {
+		http.Error(w, "Unknown province "+id, http.StatusNotFound)
+		return
+	}
+	writeResult(w, r, func() (interface{}, error) { return stat, err })
+}
+
+func handleApiLocate(w http.ResponseWriter, r *http.Request) {
+	point, err := parsePoint(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	located, found, err := a.locateBezirk(nil, now(), point)
+	if err == nil && !found {
+		http.Error(w, "No district found", http.StatusNotFound)
+		return
+	}
+	writeResult(w, r, func() (interface{}, error) {
+		return locateResult{located.bezirkStat}, err
+	})
+}
+
+func handleApiTotal(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, r, func() (interface{}, error) { return a.GetOverallStat() })
+}
+
+func handleApiGeoBezirk(w http.ResponseWriter, _ *http.Request) {
+	writeJson(w, func() (interface{}, error) { return a.GetBezirkGeo(history, now()) })
+}
+
+func handleApiGeoBundesland(w http.ResponseWriter, _ *http.Request) {
+	writeJson(w, func() (interface{}, error) { return a.GetBundeslandGeo(history, now()) })
+}
+
+func handleRenderBezirkMap(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("metric")
+	if kind == "" {
+		kind = "per100k"
+	}
+	if _, ok := mapMetrics[kind]; !ok {
+		http.Error(w, "Unknown metric "+kind, http.StatusBadRequest)
+		return
+	}
+	date := now()
+	regions, err := a.getBezirkMapRegions(kind, history, date)
+	if err == errMapWithoutHistory {
+		http.Error(w, "The metric "+kind+" requires -history-dir", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	svg := &bytes.Buffer{}
+	if err := renderMap(svg, regions, kind, date); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-type", "image/svg+xml; charset=utf-8")
+	w.Write(svg.Bytes())
+}
+
+func handleRenderChart(w http.ResponseWriter, r *http.Request) {
+	request, err := parseChartRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	series, err := loadChartSeries(history, request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	chart := &bytes.Buffer{}
+	if err := renderChart(chart, series, request); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if request.png {
+		w.Header().Set("Content-type", "image/png")
+	} else {
+		w.Header().Set("Content-type", "image/svg+xml; charset=utf-8")
+	}
+	w.Write(chart.Bytes())
+}
+
+//dashboardFromScheduler starts the scheduler and returns its dashboard, nil until the first refresh is done
+func dashboardFromScheduler() *dashboard {
+	if sched == nil {
+		return nil
+	}
+	sched.start()
+	return sched.cachedDashboard()
+}
+
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	var d dashboard
+	if cached := dashboardFromScheduler(); cached != nil {
+		d = *cached
+	} else {
+		var err error
+		if d, err = a.getDashboard(history, now()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-type", "text/html; charset=utf-8")
+	dashboardTemplate.Execute(w, d)
+}
+
+func handleImpressum(w http.ResponseWriter, _ *http.Request) {
+	content, err := webFiles.ReadFile("impressum.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-type", "text/html; charset=utf-8")
+	w.Write(content)
+}
+
+func handleApiOpenApi(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-type", "application/json; charset=utf-8")
+	w.Write(openApiSpec)
+}
+
+//queryHistory reads the metric, name, from and to query parameters of the history endpoints
+func queryHistory(r *http.Request, field string, defaultMetric string) ([]historyPoint, error) {
+	if history == nil {
+		return nil, errors.New("History is disabled")
+	}
+	query := r.URL.Query()
+	metric := query.Get("metric")
+	if metric == "" {
+		metric = defaultMetric
+	}
+	var from, to time.Time
+	var err error
+	if query.Get("from") != "" {
+		if from, err = time.Parse(snapshotLayout, query.Get("from")); err != nil {
+			return nil, err
+		}
+	}
+	if query.Get("to") != "" {
+		if to, err = time.Parse(snapshotLayout, query.Get("to")); err != nil {
+			return nil, err
+		}
+	}
+	return history.query(metric, regionMatcher(field, query.Get("name")), from, to)
+}
+
+func handleApiHistory(w http.ResponseWriter, r *http.Request, field string, defaultMetric string) {
+	writeResult(w, r, func() (interface{}, error) { return queryHistory(r, field, defaultMetric) })
+}
+
+func handleApiHistoryTotal(w http.ResponseWriter, r *http.Request) {
+	handleApiHistory(w, r, "", "cov19_confirmed")
+}
+
+func handleApiHistoryBundesland(w http.ResponseWriter, r *http.Request) {
+	handleApiHistory(w, r, "province", "cov19_detail")
+}
+
+func handleApiHistoryBezirk(w http.ResponseWriter, r *http.Request) {
+	handleApiHistory(w, r, "bezirk", "cov19_bezirk_infected")
+}
+
+func handleApiV2Total(w http.ResponseWriter, r *http.Request) {
+	writeApiV2(w, r, []string{apiV2SourceMinistry}, func() (interface{}, error) { return a.GetOverallStatV2() })
+}
+
+func handleApiV2Bundesland(w http.ResponseWriter, r *http.Request) {
+	writeApiV2(w, r, []string{apiV2SourceMinistry, apiV2SourceAges}, func() (interface{}, error) { return a.GetBundeslandStatV2() })
+}
+
+func handleApiV2Bezirk(w http.ResponseWriter, r *http.Request) {
+	handleBezirkQuery(w, r, reflect.TypeOf(apiV2Bezirk{}), true,
+		func(f func() (interface{}, error)) { writeApiV2(w, r, []string{apiV2SourceMinistry}, f) },
+		func(row bezirkRow) interface{} { return a.newApiV2Bezirk(row) })
+}
+
+func handleApiV2BezirkById(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/v2/bezirk/")
+	row, found, err := a.findBezirk(history, now(), id)
+	if err == nil && !found {
+		writeApiV2Error(w, http.StatusNotFound, "Unknown district "+id)
+		return
+	}
+	writeApiV2(w, r, []string{apiV2SourceMinistry}, func() (interface{}, error) { return a.newApiV2Bezirk(row), err })
+}
+
+func handleApiV2BundeslandById(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/v2/bundesland/")
+	stat, found, err := a.findBundeslandV2(id)
+	if err == nil && !found {
+		writeApiV2Error(w, http.StatusNotFound, "Unknown province "+id)
+		return
+	}
+	writeApiV2(w, r, []string{apiV2SourceMinistry, apiV2SourceAges}, func() (interface{}, error) { return stat, err })
+}
+
+func handleApiV2Locate(w http.ResponseWriter, r *http.Request) {
+	point, err := parsePoint(r.URL.Query())
+	if err != nil {
+		writeApiV2Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	located, found, err := a.locateBezirk(history, now(), point)
+	if err == nil && !found {
+		writeApiV2Error(w, http.StatusNotFound, "No district found")
+		return
+	}
+	writeApiV2(w, r, []string{apiV2SourceMinistry}, func() (interface{}, error) {
+		return apiV2Locate{a.newApiV2Bezirk(located)}, err
+	})
+}
+
+func handleApiStream(w http.ResponseWriter, r *http.Request) {
+	if sched == nil {
+		http.Error(w, "Scheduler is not running", http.StatusServiceUnavailable)
+		return
+	}
+	sched.start()
+	serveStream(w, r, sched.changes)
+}
+
+func handleApiSummary(w http.ResponseWriter, r *http.Request) {
+	format, lang := r.URL.Query().Get("format"), r.URL.Query().Get("lang")
+	if format == "" {
+		format = "text"
+	}
+	if lang == "" {
+		lang = "de"
+	}
+	if _, ok := summaryFormats[format]; !ok {
+		http.Error(w, "Unknown format "+format, http.StatusBadRequest)
+		return
+	}
+	if _, ok := summaryTexts[lang]; !ok {
+		http.Error(w, "Unknown language "+lang, http.StatusBadRequest)
+		return
+	}
+	summary, err := a.getSummary(history, now())
+	if err == nil {
+		var body []byte
+		if body, err = summary.render(format, lang); err == nil {
+			w.Header().Add("Content-type", summaryFormats[format])
+			w.Write(body)
+			return
+		}
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func handleGraphql(w http.ResponseWriter, r *http.Request) {
+	request, err := parseGraphqlRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result := executeGraphql(r.Context(), newGraphqlLoader(a, history, now()), request)
+	w.Header().Add("Content-type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(result)
+}
+
+func handleApiV2History(w http.ResponseWriter, r *http.Request, field string, defaultMetric string) {
+	writeApiV2(w, r, []string{apiV2SourceMinistry, apiV2SourceAges}, func() (interface{}, error) {
+		points, err := queryHistory(r, field, defaultMetric)
+		if err != nil {
+			return nil, err
+		}
+		return newApiV2History(points), nil
+	})
+}
+
+func handleApiV2HistoryTotal(w http.ResponseWriter, r *http.Request) {
+	handleApiV2History(w, r, "", "cov19_confirmed")
+}
+
+func handleApiV2HistoryBundesland(w http.ResponseWriter, r *http.Request) {
+	handleApiV2History(w, r, "province", "cov19_detail")
+}
+
+func handleApiV2HistoryBezirk(w http.ResponseWriter, r *http.Request) {
+	handleApiV2History(w, r, "bezirk", "cov19_bezirk_infected")
+}
+
+//serveMetrics writes the metrics of all exporters in the format of the encoder
+func serveMetrics(w http.ResponseWriter, encoder metricsEncoder) {
+	w.Header().Set("Content-type", encoder.contentType())
 	for _, e := range exporters {
 		metrics, err := e.GetMetrics()
 		if err == nil {
-			writeMetrics(metrics, w)
+			encoder.encode(w, metrics, time.Time{})
 		}
 	}
 }
 
+func handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	serveMetrics(w, prometheusEncoder{})
+}
+
+func handleMetricsInflux(w http.ResponseWriter, _ *http.Request) {
+	serveMetrics(w, influxEncoder{})
+}
+
 func handleHealth(w http.ResponseWriter, _ *http.Request) {
 	errors := make([]error, 0)
 	for _, e := range exporters {
@@ -75,14 +430,137 @@ func handleHealth(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
-func main() {
+//apiHandlers are the handlers of the json api, patterns ending with / serve the regions by id. openapi_test.go checks
+//that they are documented in openapi.json.
+var apiHandlers = map[string]http.HandlerFunc{
+	"/api/bundesland":            handleApiBundesland,
+	"/api/bezirk":                handleApiBezirk,
+	"/api/bezirk/":               handleApiBezirkById,
+	"/api/bundesland/":           handleApiBundeslandById,
+	"/api/locate":                handleApiLocate,
+	"/api/total":                 handleApiTotal,
+	"/api/openapi.json":          handleApiOpenApi,
+	"/api/geo/bezirk":            handleApiGeoBezirk,
+	"/api/geo/bundesland":        handleApiGeoBundesland,
+	"/api/history/bundesland":    handleApiHistoryBundesland,
+	"/api/history/bezirk":        handleApiHistoryBezirk,
+	"/api/history/total":         handleApiHistoryTotal,
+	"/api/v2/total":              handleApiV2Total,
+	"/api/v2/bundesland":         handleApiV2Bundesland,
+	"/api/v2/bezirk":             handleApiV2Bezirk,
+	"/api/v2/bezirk/":            handleApiV2BezirkById,
+	"/api/v2/bundesland/":        handleApiV2BundeslandById,
+	"/api/v2/locate":             handleApiV2Locate,
+	"/api/v2/history/total":      handleApiV2HistoryTotal,
+	"/api/v2/history/bundesland": handleApiV2HistoryBundesland,
+	"/api/v2/history/bezirk":     handleApiV2HistoryBezirk,
+	"/graphql":                   handleGraphql,
+	"/api/stream":                handleApiStream,
+	"/api/summary":               handleApiSummary,
+}
+
+//runServe runs the exporters periodically and serves the metrics, the api and the dashboard
+func runServe(args []string, _ io.Writer) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	applySources := sourceFlags(flags)
+	replayFrom := flags.String("replay-from", "", "Replay snapshot directories day by day starting at this date (YYYY-MM-DD)")
+	replayInterval := flags.Duration("replay-interval", time.Minute, "Time after which the replay advances to the next day")
+	archiveDir := flags.String("archive-dir", "", "Directory in which every distinct upstream response is archived")
+	archiveRetention := flags.Duration("archive-retention", 0, "Maximum age of archived responses, 0 keeps everything")
+	archiveCompress := flags.Bool("archive-compress", true, "Compress archived responses with gzip")
+	historyDir := flags.String("history-dir", "", "Directory in which a daily snapshot of all metrics is recorded")
+	rebuildHistory := flags.Bool("rebuild-history", false, "Rebuild the history from the archive and exit")
+	refreshInterval := flags.Duration("refresh-interval", 15*time.Minute, "Interval in which all exporters are refreshed")
+	output := flags.String("output", "pull", "Output mode: pull (scrape /metrics), pushgateway, remote-write, influx or graphite")
+	pushURL := flags.String("push-url", "", "Url of the Pushgateway, the remote-write endpoint, the InfluxDB v2 write endpoint or tcp://host:port of Graphite")
+	pushJob := flags.String("push-job", "covid19-at", "Job name of the metrics pushed to the Pushgateway")
+	pushToken := flags.String("push-token", os.Getenv("INFLUX_TOKEN"), "InfluxDB api token")
+	graphitePrefix := flags.String("graphite-prefix", "covid19", "First segment of the Graphite paths")
+	pushTimeout := flags.Duration("push-timeout", 10*time.Second, "Timeout of a push")
+	origins := flags.String("stream-origins", "", "Comma separated origins (e.g. https://example.org) of other sites that may connect to /api/stream with WebSocket, * allows all")
+	flags.Parse(args)
+	if err := applySources(); err != nil {
+		return err
+	}
+	streamOrigins = splitList([]string{*origins})
+
+	if *replayFrom != "" {
+		date, err := time.Parse(snapshotLayout, *replayFrom)
+		if err != nil {
+			return err
+		}
+		replay.set(date)
+		go func() {
+			for range time.Tick(*replayInterval) {
+				logger.Printf("Replaying snapshot of %s", replay.advance(24*time.Hour).Format(snapshotLayout))
+			}
+		}()
+	}
+
+	if *archiveDir != "" {
+		archive, err := openArchive(*archiveDir, *archiveRetention, *archiveCompress)
+		if err != nil {
+			return err
+		}
+		upstreamArchive = archive
+	}
+	if *historyDir != "" {
+		store, err := newHistoryStore(*historyDir)
+		if err != nil {
+			return err
+		}
+		history = store
+	}
+	if *rebuildHistory {
+		if upstreamArchive == nil || history == nil {
+			return errors.New("-rebuild-history requires -archive-dir and -history-dir")
+		}
+		days, err := upstreamArchive.rebuildHistory(exporters, history)
+		if err != nil {
+			return err
+		}
+		logger.Printf("Rebuilt history of %d days", days)
+		return nil
+	}
+
+	sched = newScheduler(exporters, history)
+	sched.api, sched.interval = a, *refreshInterval
+	if *output != "pull" {
+		config := pushConfig{Mode: *output, URL: *pushURL, Job: *pushJob, Token: *pushToken, Prefix: *graphitePrefix, Timeout: *pushTimeout}
+		push, err := newPushOutput(config)
+		if err != nil {
+			return err
+		}
+		sched.output = push
+	}
+	if fileExists("webhooks.yml") {
+		config, err := loadWebhooksConfig("webhooks.yml")
+		if err != nil {
+			return err
+		}
+		var incidences func(time.Time) (map[string]float64, error)
+		if history != nil {
+			incidences = func(date time.Time) (map[string]float64, error) { return a.getBezirkIncidences(history, date) }
+		}
+		summary := func(date time.Time) (dailySummary, error) { return a.getSummary(history, date) }
+		if sched.webhooks, err = newWebhookNotifier(config, incidences, summary); err != nil {
+			return err
+		}
+	}
+	if history != nil || sched.output != nil || sched.webhooks != nil {
+		sched.start()
+	}
+
+	http.HandleFunc("/", handleDashboard)
+	http.HandleFunc("/impressum.html", handleImpressum)
+	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticFiles))))
 	http.HandleFunc("/metrics", handleMetrics)
+	http.HandleFunc("/metrics/influx", handleMetricsInflux)
 	http.HandleFunc("/health", handleHealth)
-	http.HandleFunc("/api/bundesland", handleApiBundesland)
-	http.HandleFunc("/api/bezirk", handleApiBezirk)
-	http.HandleFunc("/api/total", handleApiTotal)
-	err := http.ListenAndServe(":8282", nil)
-	if err != nil {
-		panic(err)
+	http.HandleFunc("/render/map/bezirk.svg", handleRenderBezirkMap)
+	http.HandleFunc("/render/chart", handleRenderChart)
+	for pattern, handler := range apiHandlers {
+		http.HandleFunc(pattern, handler)
 	}
+	return http.ListenAndServe(":8282", nil)
 }
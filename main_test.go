@@ -26,17 +26,39 @@ func emptyPage(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("<html></html>"))
 }
 
+//serveTestContent points the url at a test server that serves the canned content, e.g. the csv file of an exporter.
+//The server is closed at the end of the test.
+func serveTestContent(t *testing.T, url *string, content string) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(ts.Close)
+	*url = ts.URL
+}
+
+//assertMetric asserts the value of the first metric with the name and tag and returns it
+func assertMetric(t *testing.T, result metrics, name string, tagMatch string, value float64) *metric {
+	m := result.findMetric(name, tagMatch)
+	if !assert.NotNil(t, m, "%s %s", name, tagMatch) {
+		t.FailNow()
+	}
+	assert.InDelta(t, value, m.Value, 0.0001, "%s %s", name, tagMatch)
+	return m
+}
+
 func TestErrors(t *testing.T) {
 	healthMinistryExporter := exporters[0].(*healthMinistryExporter)
 	ecdcExporter := exporters[1].(*ecdcExporter)
 
 	ecdcURL := ecdcExporter.Url
 	healthMinistryURL := healthMinistryExporter.url
+	vaccinationURL, hospitalURL, testingsURL := ve.url, hc.url, te.url
 
 	mockServer := httptest.NewServer(http.HandlerFunc(emptyPage))
 	defer mockServer.Close()
 	ecdcExporter.Url = mockServer.URL
 	healthMinistryExporter.url = mockServer.URL
+	ve.url, hc.url, te.url = mockServer.URL, mockServer.URL, mockServer.URL
 
 	ts := httptest.NewServer(http.HandlerFunc(handleHealth))
 
@@ -63,10 +85,14 @@ dpGesIBBel not found in /GesamtzahlIntensivBettenBel.js
 dpGesTestungen not found in /GesamtzahlTestungen.js
 Could not find "Bestätigte Fälle"
 World stats are failing
+Could not find column date
+Could not find column Meldedat
+Could not find column Datum
 </pre></body></html>`, string(greeting))
 
 	ecdcExporter.Url = ecdcURL
 	healthMinistryExporter.url = healthMinistryURL
+	ve.url, hc.url, te.url = vaccinationURL, hospitalURL, testingsURL
 }
 
 func TestMetrics(t *testing.T) {
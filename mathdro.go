@@ -1,17 +1,14 @@
 package main
 
-import (
-	"encoding/json"
-	"io/ioutil"
-	"net/http"
-	"time"
-)
+import "encoding/json"
 
 type mathdroExporter struct {
 	url string
 }
 
-type recoveredStats []struct {
+type recoveredStats []recoveredStat
+
+type recoveredStat struct {
 	ProvinceState *string
 	CountryRegion string
 	Recovered     uint64
@@ -28,8 +25,12 @@ func (me *mathdroExporter) GetMetrics() (metrics, error) {
 	if err != nil {
 		return nil, err
 	}
-	result := make(metrics, 0)
+	return recoveredMetrics(recovered), nil
+}
 
+//recoveredMetrics returns the metrics of the recovered by country and province
+func recoveredMetrics(recovered recoveredStats) metrics {
+	result := make(metrics, 0)
 	for _, r := range recovered {
 		tags := map[string]string{"country": r.CountryRegion, "latitude": ftos(r.Lat), "longitude": ftos(r.Long)}
 		if r.ProvinceState != nil {
@@ -37,7 +38,7 @@ func (me *mathdroExporter) GetMetrics() (metrics, error) {
 		}
 		result = append(result, metric{Name: "cov19_world_recovered", Tags: &tags, Value: float64(r.Recovered)})
 	}
-	return result, nil
+	return result
 }
 
 func (me *mathdroExporter) Health() []error {
@@ -49,13 +50,7 @@ func (me *mathdroExporter) Health() []error {
 }
 
 func (me *mathdroExporter) getRecoveredStats() (recoveredStats, error) {
-	client := http.Client{Timeout: 5 * time.Second}
-	response, err := client.Get(me.url + "recovered")
-	if err != nil {
-		return nil, err
-	}
-	defer response.Body.Close()
-	jsonString, err := ioutil.ReadAll(response.Body)
+	jsonString, err := readFromGet(me.url + "recovered")
 	if err != nil {
 		return nil, err
 	}
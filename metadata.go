@@ -21,6 +21,8 @@ type metaData struct {
 	location   location
 	country    string
 	population uint64
+	id         string
+	parent     string
 }
 
 func normalizeName(name string) string {
@@ -48,7 +50,14 @@ func newMetadataProviderWithFilename(filename string) *metadataProvider {
 	data := make(map[string]metaData, len(records))
 
 	for _, row := range records {
-		data[normalizeName(row[0])] = metaData{location{atof(row[2]), atof(row[3])}, row[0], atoi(row[1])}
+		m := metaData{location: location{atof(row[2]), atof(row[3])}, country: row[0], population: atoi(row[1])}
+		if len(row) > 4 {
+			m.id = row[4]
+		}
+		if len(row) > 5 {
+			m.parent = row[5]
+		}
+		data[normalizeName(row[0])] = m
 	}
 	return &metadataProvider{data: data}
 }
@@ -68,6 +77,22 @@ func (l *metadataProvider) getLocation(location string) *location {
 	return nil
 }
 
+//getId returns the official identifier of a location, e.g. the GKZ of Austrian provinces and districts
+func (l *metadataProvider) getId(location string) string {
+	if l, ok := l.data[normalizeName(location)]; ok {
+		return l.id
+	}
+	return ""
+}
+
+//getParent returns the region a location belongs to, e.g. the province of a district
+func (l *metadataProvider) getParent(location string) string {
+	if l, ok := l.data[normalizeName(location)]; ok {
+		return l.parent
+	}
+	return ""
+}
+
 //getPopulation for a given location by name
 func (l *metadataProvider) getPopulation(location string) uint64 {
 	if l, ok := l.data[normalizeName(location)]; ok {
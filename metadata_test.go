@@ -1,6 +1,8 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -56,3 +58,27 @@ func TestUnknownLocation(t *testing.T) {
 
 	assert.Nil(t, newMetadataProviderWithFilename("someinvalidfile"))
 }
+
+func TestAustrianIds(t *testing.T) {
+	bezirke := newMetadataProviderWithFilename("bezirke.csv")
+	assert.Equal(t, "601", bezirke.getId("Graz(Stadt)"))
+	assert.Equal(t, "923", bezirke.getId("Wien 23. Liesing"))
+	assert.Equal(t, "9", bezirke.getId("Wien"))
+	assert.Equal(t, "", bezirke.getId("Gröbming"))
+	assert.Equal(t, "", p.getId("Austria"))
+	assert.Equal(t, "Steiermark", bezirke.getParent("Gröbming"))
+	assert.Equal(t, "Wien", bezirke.getParent("Wien 23. Liesing"))
+	assert.Equal(t, "", bezirke.getParent("Wien"))
+}
+
+func TestMetadataWithoutParent(t *testing.T) {
+	file, err := ioutil.TempFile("", "metadata*.csv")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString("Graz(Stadt),288806,47.070714,15.439504,601\n")
+	file.Close()
+
+	metadata := newMetadataProviderWithFilename(file.Name())
+	assert.Equal(t, "601", metadata.getId("Graz(Stadt)"))
+	assert.Equal(t, "", metadata.getParent("Graz(Stadt)"))
+}
@@ -0,0 +1,7 @@
+package main
+
+import _ "embed"
+
+//openApiSpec documents the JSON api, the client package is generated from it
+//go:embed openapi.json
+var openApiSpec []byte
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type jsonObject = map[string]interface{}
+
+//resolveRef follows the local $ref of an OpenAPI object
+func resolveRef(doc jsonObject, node jsonObject) jsonObject {
+	for {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+		node = doc
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			node = node[part].(jsonObject)
+		}
+	}
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+//validateSchema checks a decoded json value against the subset of JSON schema used in openapi.json
+func validateSchema(doc jsonObject, schema jsonObject, value interface{}, path string) []string {
+	schema = resolveRef(doc, schema)
+	errors := make([]string, 0)
+	if value == nil && schema["nullable"] == true {
+		return errors
+	}
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(jsonObject)
+		if !ok {
+			return []string{path + ": not an object"}
+		}
+		properties, _ := schema["properties"].(jsonObject)
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				errors = append(errors, fmt.Sprintf("%s: missing %s", path, name))
+			}
+		}
+		for name, v := range object {
+			if property, ok := properties[name]; ok {
+				errors = append(errors, validateSchema(doc, property.(jsonObject), v, path+"."+name)...)
+			} else if additional, ok := schema["additionalProperties"].(jsonObject); ok {
+				errors = append(errors, validateSchema(doc, additional, v, path+"."+name)...)
+			} else if schema["additionalProperties"] == false {
+				errors = append(errors, fmt.Sprintf("%s: undocumented property %s", path, name))
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []string{path + ": not an array"}
+		}
+		for i, v := range array {
+			errors = append(errors, validateSchema(doc, schema["items"].(jsonObject), v, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			errors = append(errors, path+": not a string")
+		} else if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, text) {
+			errors = append(errors, fmt.Sprintf("%s: %s not in %v", path, text, enum))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			errors = append(errors, path+": not a number")
+		}
+	case "integer":
+		f, ok := value.(float64)
+		if !ok || f != float64(int64(f)) {
+			errors = append(errors, path+": not an integer")
+		} else if minimum, ok := schema["minimum"].(float64); ok && f < minimum {
+			errors = append(errors, fmt.Sprintf("%s: less than %v", path, minimum))
+		}
+	case nil:
+		//a schema without type accepts any value
+	default:
+		errors = append(errors, fmt.Sprintf("%s: unsupported schema type %v", path, schema["type"]))
+	}
+	return errors
+}
+
+func TestValidateSchema(t *testing.T) {
+	doc := jsonObject{}
+	assert.Nil(t, json.Unmarshal(openApiSpec, &doc))
+	bezirk := jsonObject{"$ref": "#/components/schemas/Bezirk"}
+	assert.Empty(t, validateSchema(doc, bezirk, jsonObject{"Name": "Graz", "Location": jsonObject{"Lat": 1.0, "Long": 2.0}, "Population": 3.0, "Infected": 4.0}, "$"))
+	assert.Equal(t, []string{"$: missing Infected", "$.Population: not an integer"},
+		validateSchema(doc, bezirk, jsonObject{"Name": "Graz", "Location": jsonObject{"Lat": 1.0, "Long": 2.0}, "Population": 3.5}, "$"))
+	assert.Equal(t, []string{"$.Location: undocumented property Alt"},
+		validateSchema(doc, bezirk, jsonObject{"Name": "Graz", "Location": jsonObject{"Lat": 1.0, "Long": 2.0, "Alt": 3.0}, "Population": 3.0, "Infected": 4.0}, "$"))
+}
+
+//exampleRequest fills the path and required query parameters of an operation with their examples
+func exampleRequest(doc jsonObject, path string, operation jsonObject) string {
+	query := url.Values{}
+	parameters, _ := operation["parameters"].([]interface{})
+	for _, p := range parameters {
+		parameter := resolveRef(doc, p.(jsonObject))
+		example, ok := parameter["example"]
+		if !ok {
+			continue
+		}
+		if parameter["in"] == "path" {
+			path = strings.Replace(path, "{"+parameter["name"].(string)+"}", fmt.Sprint(example), 1)
+		} else if parameter["required"] == true {
+			query.Set(parameter["name"].(string), fmt.Sprint(example))
+		}
+	}
+	if len(query) > 0 {
+		return path + "?" + query.Encode()
+	}
+	return path
+}
+
+func TestOpenApiMatchesHandlers(t *testing.T) {
+	testApi, cleanup := newTestDashboardApi(t)
+	defer cleanup()
+	store, cleanupHistory := newTestHistory(t)
+	defer cleanupHistory()
+	assert.Nil(t, store.record(time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC), testSnapshot(100, 40)))
+	defer func(previousApi *api, previousHistory *historyStore) { a, history = previousApi, previousHistory }(a, history)
+	a, history = testApi, store
+	var err error
+	testApi.bezirkBoundaries, err = parseBoundaries([]byte(testBoundaries))
+	assert.Nil(t, err)
+
+	//undocumented are the api handlers that are not described in openapi.json on purpose
+	undocumented := map[string]string{
+		"/api/openapi.json": "the specification itself",
+		"/api/stream":       "Server-Sent Events and WebSocket messages, documented in the README",
+		"/graphql":          "GraphQL queries, the schema is in graphql.go and the README",
+	}
+
+	recorder := httptest.NewRecorder()
+	handleApiOpenApi(recorder, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	assert.Equal(t, "application/json; charset=utf-8", recorder.Header().Get("Content-type"))
+	doc := jsonObject{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &doc))
+
+	paths := doc["paths"].(jsonObject)
+	for pattern := range apiHandlers {
+		//the patterns ending with / serve the paths with {id}
+		path := pattern
+		if strings.HasSuffix(pattern, "/") {
+			path += "{id}"
+		}
+		_, documented := paths[path]
+		_, excluded := undocumented[pattern]
+		assert.True(t, documented != excluded, "%s has to be documented in openapi.json or listed as undocumented", pattern)
+	}
+
+	for path, item := range paths {
+		handler, ok := apiHandlers[strings.TrimSuffix(path, "{id}")]
+		if !assert.True(t, ok, "no handler for "+path) {
+			continue
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest("GET", exampleRequest(doc, path, item.(jsonObject)["get"].(jsonObject)), nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, path)
+		assert.NotEmpty(t, recorder.Body.String(), path)
+
+		response := resolveRef(doc, item.(jsonObject)["get"].(jsonObject)["responses"].(jsonObject)["200"].(jsonObject))
+		mediaType := strings.Split(recorder.Header().Get("Content-type"), ";")[0]
+		content, ok := response["content"].(jsonObject)[mediaType].(jsonObject)
+		if !assert.True(t, ok, "%s returns undocumented %s", path, mediaType) || mediaType != "application/json" {
+			continue
+		}
+		var value interface{}
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &value), path)
+		assert.NotEmpty(t, value, path)
+		assert.Empty(t, validateSchema(doc, content["schema"].(jsonObject), value, path), path)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 )
 
 type metrics []metric
@@ -25,9 +26,59 @@ type Exporter interface {
 	Health() []error
 }
 
-func writeMetrics(metrics metrics, w io.Writer) error {
+//exporterResult is the outcome of GetMetrics of one exporter
+type exporterResult struct {
+	exporter Exporter
+	metrics  metrics
+	err      error
+}
+
+//runExporters calls GetMetrics of all exporters
+func runExporters(exporters []Exporter) []exporterResult {
+	result := make([]exporterResult, 0, len(exporters))
+	for _, e := range exporters {
+		m, err := e.GetMetrics()
+		result = append(result, exporterResult{e, m, err})
+	}
+	return result
+}
+
+//mergeResults returns the metrics of all exporters that did not fail
+func mergeResults(results []exporterResult) metrics {
+	result := make(metrics, 0)
+	for _, r := range results {
+		if r.err == nil {
+			result = append(result, r.metrics...)
+		}
+	}
+	return result
+}
+
+//collectMetrics returns the metrics of all exporters that did not fail
+func collectMetrics(exporters []Exporter) metrics {
+	return mergeResults(runExporters(exporters))
+}
+
+//metricsEncoder writes metrics in the format of a monitoring system, a zero timestamp is omitted where the format allows it
+type metricsEncoder interface {
+	encode(w io.Writer, metrics metrics, timestamp time.Time) error
+	contentType() string
+}
+
+//prometheusEncoder writes the Prometheus text format
+type prometheusEncoder struct{}
+
+func (prometheusEncoder) contentType() string {
+	return "text/plain; version=0.0.4; charset=utf-8"
+}
+
+func (prometheusEncoder) encode(w io.Writer, metrics metrics, timestamp time.Time) error {
 	for _, m := range metrics {
-		_, err := io.WriteString(w, formatMetric(m))
+		line := formatMetric(m)
+		if !timestamp.IsZero() {
+			line = fmt.Sprintf("%s %d\n", strings.TrimSuffix(line, "\n"), timestamp.UnixNano()/int64(time.Millisecond))
+		}
+		_, err := io.WriteString(w, line)
 		if err != nil {
 			return err
 		}
@@ -35,6 +86,10 @@ func writeMetrics(metrics metrics, w io.Writer) error {
 	return nil
 }
 
+func writeMetrics(metrics metrics, w io.Writer) error {
+	return prometheusEncoder{}.encode(w, metrics, time.Time{})
+}
+
 func formatMetric(m metric) string {
 	tags := []string{}
 	if m.Tags != nil {
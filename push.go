@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+//pushModes are the output modes besides the default pull mode on /metrics
+var pushModes = []string{"pushgateway", "remote-write", "influx", "graphite"}
+
+//pushConfig configures where the metrics are pushed to
+type pushConfig struct {
+	Mode string
+	//URL is the Pushgateway, the remote-write endpoint, the InfluxDB v2 write url with org and bucket or tcp://host:port of Graphite
+	URL string
+	//Job is the group of the Pushgateway
+	Job string
+	//Token authorizes the InfluxDB writes
+	Token string
+	//Prefix is the first segment of the Graphite paths
+	Prefix  string
+	Timeout time.Duration
+}
+
+//pushOutput pushes the metrics after every refresh to a Pushgateway, a Prometheus remote-write endpoint, InfluxDB or Graphite
+type pushOutput struct {
+	config pushConfig
+	client *http.Client
+}
+
+//reportTimer is an exporter that knows when its upstream published the data, like the ministry with LetzteAktualisierung
+type reportTimer interface {
+	getReportTime() (time.Time, error)
+}
+
+//pushBatch are the metrics of an exporter with their timestamp, reported is set if it is the upstream report time
+type pushBatch struct {
+	timestamp time.Time
+	reported  bool
+	metrics   metrics
+}
+
+func newPushOutput(config pushConfig) (*pushOutput, error) {
+	if !contains(pushModes, config.Mode) {
+		return nil, fmt.Errorf("Unknown output mode %s", config.Mode)
+	}
+	u, err := url.Parse(config.URL)
+	schemes := []string{"http", "https"}
+	if config.Mode == "graphite" {
+		schemes = []string{"tcp"}
+	}
+	if err != nil || !contains(schemes, u.Scheme) || u.Host == "" {
+		return nil, fmt.Errorf("Invalid push url %s", config.URL)
+	}
+	if config.Mode == "pushgateway" && config.Job == "" {
+		return nil, fmt.Errorf("The pushgateway requires a job name")
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	return &pushOutput{config: config, client: &http.Client{Timeout: config.Timeout}}, nil
+}
+
+//uniqueMetrics removes repeated series, the last value wins like in a scrape
+func uniqueMetrics(m metrics) metrics {
+	index := make(map[string]int, len(m))
+	result := make(metrics, 0, len(m))
+	for _, x := range m {
+		key := seriesKey(x)
+		if i, ok := index[key]; ok {
+			result[i] = x
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, x)
+	}
+	return result
+}
+
+//pushBatches stamps the metrics of exporters with a report time with it and all others with the refresh date,
+//failed exporters are left out
+func pushBatches(date time.Time, results []exporterResult) []pushBatch {
+	batches := make([]pushBatch, 0, len(results))
+	for _, r := range results {
+		if r.err != nil {
+			continue
+		}
+		batch := pushBatch{timestamp: date, metrics: uniqueMetrics(r.metrics)}
+		if reported, ok := r.exporter.(reportTimer); ok {
+			if t, err := reported.getReportTime(); err == nil {
+				batch.timestamp, batch.reported = t, true
+			} else {
+				logger.Print(err)
+			}
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+//push sends the metrics of the exporters of a refresh
+func (p *pushOutput) push(date time.Time, results []exporterResult) error {
+	batches := pushBatches(date, results)
+	switch p.config.Mode {
+	case "pushgateway":
+		return p.pushGateway(date, batches)
+	case "influx":
+		return p.pushInflux(batches)
+	case "graphite":
+		return p.pushGraphite(batches)
+	}
+	return p.remoteWrite(batches)
+}
+
+//pushGateway replaces the group of the job. The Pushgateway rejects samples with timestamps, so the report time
+//is pushed as cov19_report_time_seconds, the refresh date if no exporter has a report time.
+func (p *pushOutput) pushGateway(date time.Time, batches []pushBatch) error {
+	m := make(metrics, 0)
+	reportTime := date
+	for _, b := range batches {
+		m = append(m, b.metrics...)
+		if b.reported {
+			reportTime = b.timestamp
+		}
+	}
+	body := bytes.Buffer{}
+	if err := writeMetrics(uniqueMetrics(m), &body); err != nil {
+		return err
+	}
+	fmt.Fprintf(&body, "cov19_report_time_seconds %d\n", reportTime.Unix())
+	request, err := http.NewRequest(http.MethodPut, p.config.URL+"/metrics/job/"+url.PathEscape(p.config.Job), &body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	return p.send(request)
+}
+
+func (p *pushOutput) remoteWrite(batches []pushBatch) error {
+	message := make([]byte, 0)
+	for _, b := range batches {
+		message = append(message, encodeWriteRequest(b.metrics, b.timestamp)...)
+	}
+	body := snappy.Encode(nil, message)
+	request, err := http.NewRequest(http.MethodPost, p.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	request.Header.Set("Content-Encoding", "snappy")
+	request.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	return p.send(request)
+}
+
+//pushInflux writes the line protocol to the InfluxDB v2 write url, e.g. http://influx:8086/api/v2/write?org=o&bucket=b
+func (p *pushOutput) pushInflux(batches []pushBatch) error {
+	body := bytes.Buffer{}
+	for _, b := range batches {
+		if err := (influxEncoder{}).encode(&body, b.metrics, b.timestamp); err != nil {
+			return err
+		}
+	}
+	request, err := http.NewRequest(http.MethodPost, p.config.URL, &body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if p.config.Token != "" {
+		request.Header.Set("Authorization", "Token "+p.config.Token)
+	}
+	return p.send(request)
+}
+
+//pushGraphite writes the plaintext protocol to the Graphite TCP listener
+func (p *pushOutput) pushGraphite(batches []pushBatch) error {
+	conn, err := net.DialTimeout("tcp", strings.TrimPrefix(p.config.URL, "tcp://"), p.config.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(p.config.Timeout))
+	writer := bufio.NewWriter(conn)
+	for _, b := range batches {
+		if err := (graphiteEncoder{prefix: p.config.Prefix}).encode(writer, b.metrics, b.timestamp); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+func (p *pushOutput) send(request *http.Request) error {
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("Push to %s failed with %s: %s", p.config.URL, response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+//The remote-write protocol sends a prometheus.WriteRequest protobuf message:
+//  WriteRequest { repeated TimeSeries timeseries = 1; }
+//  TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//  Label        { string name = 1; string value = 2; }
+//  Sample       { double value = 1; int64 timestamp = 2; }
+//The few fields are encoded by hand instead of depending on the generated code of Prometheus.
+
+func protoAppendVarint(b []byte, v uint64) []byte {
+	buffer := make([]byte, binary.MaxVarintLen64)
+	return append(b, buffer[:binary.PutUvarint(buffer, v)]...)
+}
+
+//protoAppendBytes appends a length-delimited field (wire type 2)
+func protoAppendBytes(b []byte, field uint64, value []byte) []byte {
+	b = protoAppendVarint(b, field<<3|2)
+	b = protoAppendVarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+//encodeWriteRequest encodes every metric as time series with a single sample, labels sorted by name
+func encodeWriteRequest(m metrics, timestamp time.Time) []byte {
+	result := make([]byte, 0)
+	for _, x := range m {
+		labels := map[string]string{"__name__": x.Name}
+		if x.Tags != nil {
+			for k, v := range *x.Tags {
+				labels[k] = v
+			}
+		}
+		names := make([]string, 0, len(labels))
+		for k := range labels {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		series := make([]byte, 0)
+		for _, name := range names {
+			label := protoAppendBytes(nil, 1, []byte(name))
+			label = protoAppendBytes(label, 2, []byte(labels[name]))
+			series = protoAppendBytes(series, 1, label)
+		}
+		sample := protoAppendVarint(nil, 1<<3|1)
+		sample = append(sample, make([]byte, 8)...)
+		binary.LittleEndian.PutUint64(sample[len(sample)-8:], math.Float64bits(x.Value))
+		sample = protoAppendVarint(sample, 2<<3|0)
+		sample = protoAppendVarint(sample, uint64(timestamp.UnixNano()/int64(time.Millisecond)))
+		series = protoAppendBytes(series, 2, sample)
+		result = protoAppendBytes(result, 1, series)
+	}
+	return result
+}
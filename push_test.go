@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+)
+
+//pushReceiver is a fake Pushgateway, remote-write and InfluxDB endpoint that records the requests
+type pushReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *pushReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	if r.status != 0 {
+		http.Error(w, "out of order sample", r.status)
+	}
+}
+
+//reportedExporter has a report time like the ministry
+type reportedExporter struct {
+	staticExporter
+	reportTime time.Time
+	err        error
+}
+
+func (r *reportedExporter) getReportTime() (time.Time, error) {
+	return r.reportTime, r.err
+}
+
+type remoteSample struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+//protoFields splits a protobuf message into its fields, varints and fixed64 are returned as 8 little endian bytes
+func protoFields(t *testing.T, b []byte) map[uint64][][]byte {
+	result := make(map[uint64][][]byte)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		var value []byte
+		switch key & 7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			value, b = make([]byte, 8), b[n:]
+			binary.LittleEndian.PutUint64(value, v)
+		case 1:
+			value, b = b[:8], b[8:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			value, b = b[n:n+int(length)], b[n+int(length):]
+		default:
+			t.Fatalf("Unexpected wire type %d", key&7)
+		}
+		result[key>>3] = append(result[key>>3], value)
+	}
+	return result
+}
+
+func decodeWriteRequest(t *testing.T, body []byte) []remoteSample {
+	data, err := snappy.Decode(nil, body)
+	assert.Nil(t, err)
+	result := make([]remoteSample, 0)
+	for _, series := range protoFields(t, data)[1] {
+		fields := protoFields(t, series)
+		s := remoteSample{labels: make(map[string]string)}
+		previous := ""
+		for _, label := range fields[1] {
+			l := protoFields(t, label)
+			name := string(l[1][0])
+			assert.True(t, name > previous, "labels must be sorted")
+			s.labels[name], previous = string(l[2][0]), name
+		}
+		sample := protoFields(t, fields[2][0])
+		s.value = math.Float64frombits(binary.LittleEndian.Uint64(sample[1][0]))
+		s.timestamp = int64(binary.LittleEndian.Uint64(sample[2][0]))
+		result = append(result, s)
+	}
+	return result
+}
+
+func TestRemoteWrite(t *testing.T) {
+	receiver := &pushReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	reportTime := time.Date(2020, 11, 9, 9, 0, 0, 0, time.UTC)
+	p, err := newPushOutput(pushConfig{Mode: "remote-write", URL: server.URL + "/api/v1/write", Timeout: time.Second})
+	assert.Nil(t, err)
+	exporter := &reportedExporter{staticExporter: staticExporter{metrics{
+		{"cov19_confirmed", nil, 1700},
+		{"cov19_detail", &map[string]string{"province": "Wien", "country": "Austria"}, 1200.5},
+		{"cov19_confirmed", nil, 1750},
+	}}, reportTime: reportTime}
+	other := &staticExporter{metrics{{"cov19_world_confirmed", &map[string]string{"country": "Italy"}, 900}}}
+	s := newScheduler([]Exporter{exporter, other}, nil)
+	s.output = p
+	before := time.Now()
+	s.refresh()
+
+	assert.Equal(t, 1, len(receiver.requests))
+	r := receiver.requests[0]
+	assert.Equal(t, http.MethodPost, r.Method)
+	assert.Equal(t, "/api/v1/write", r.URL.Path)
+	assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+	assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+	assert.Equal(t, "0.1.0", r.Header.Get("X-Prometheus-Remote-Write-Version"))
+	samples := decodeWriteRequest(t, receiver.bodies[0])
+	assert.Equal(t, 3, len(samples))
+	assert.Equal(t, []remoteSample{
+		{map[string]string{"__name__": "cov19_confirmed"}, 1750, reportTime.Unix() * 1000},
+		{map[string]string{"__name__": "cov19_detail", "country": "Austria", "province": "Wien"}, 1200.5, reportTime.Unix() * 1000},
+	}, samples[:2])
+	assert.Equal(t, map[string]string{"__name__": "cov19_world_confirmed", "country": "Italy"}, samples[2].labels)
+	assert.True(t, samples[2].timestamp >= before.UnixNano()/int64(time.Millisecond), "refresh time for other sources")
+
+	receiver.status = http.StatusBadRequest
+	err = p.push(reportTime, runExporters([]Exporter{exporter}))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "400 Bad Request: out of order sample")
+}
+
+func TestPushgateway(t *testing.T) {
+	receiver := &pushReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	refresh := time.Date(2020, 11, 9, 9, 15, 0, 0, time.UTC)
+	p, err := newPushOutput(pushConfig{Mode: "pushgateway", URL: server.URL + "/", Job: "covid19 at", Timeout: time.Second})
+	assert.Nil(t, err)
+	exporter := &reportedExporter{staticExporter: staticExporter{metrics{{"cov19_confirmed", nil, 1700}}}, err: errors.New("unavailable")}
+	assert.Nil(t, p.push(refresh, runExporters([]Exporter{exporter})))
+
+	r := receiver.requests[0]
+	assert.Equal(t, http.MethodPut, r.Method)
+	assert.Equal(t, "/metrics/job/covid19 at", r.URL.Path)
+	assert.Equal(t, "cov19_confirmed 1700.000000\ncov19_report_time_seconds 1604913300\n", string(receiver.bodies[0]))
+
+	exporter.reportTime, exporter.err = time.Date(2020, 11, 9, 9, 0, 0, 0, time.UTC), nil
+	assert.Nil(t, p.push(refresh, runExporters([]Exporter{exporter, &staticExporter{metrics{{"cov19_confirmed", nil, 1800}}}})))
+	assert.Equal(t, "cov19_confirmed 1800.000000\ncov19_report_time_seconds 1604912400\n", string(receiver.bodies[1]))
+}
+
+func TestNewPushOutput(t *testing.T) {
+	for _, c := range []pushConfig{
+		{Mode: "opentsdb", URL: "http://localhost:4242"},
+		{Mode: "pushgateway", URL: "localhost:9091", Job: "job"},
+		{Mode: "pushgateway", URL: "http://localhost:9091"},
+		{Mode: "graphite", URL: "http://localhost:2003"},
+	} {
+		_, err := newPushOutput(c)
+		assert.NotNil(t, err, c.Mode)
+	}
+	_, err := newPushOutput(pushConfig{Mode: "graphite", URL: "tcp://localhost:2003"})
+	assert.Nil(t, err)
+}
+
+func TestPushInflux(t *testing.T) {
+	receiver := &pushReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	reportTime := time.Date(2020, 11, 9, 9, 0, 0, 0, time.UTC)
+	p, err := newPushOutput(pushConfig{Mode: "influx", URL: server.URL + "/api/v2/write?org=covid&bucket=austria", Token: "secret", Timeout: time.Second})
+	assert.Nil(t, err)
+	exporter := &reportedExporter{staticExporter: staticExporter{metrics{{"cov19_detail", &map[string]string{"province": "Niederösterreich"}, 800}}}, reportTime: reportTime}
+	other := &staticExporter{metrics{{"cov19_hospitalized", nil, 120}}}
+	assert.Nil(t, p.push(reportTime.Add(time.Hour), runExporters([]Exporter{exporter, other})))
+
+	r := receiver.requests[0]
+	assert.Equal(t, http.MethodPost, r.Method)
+	assert.Equal(t, "austria", r.URL.Query().Get("bucket"))
+	assert.Equal(t, "Token secret", r.Header.Get("Authorization"))
+	assert.Equal(t, "cov19_detail,province=Niederösterreich value=800 1604912400000000000\n"+
+		"cov19_hospitalized value=120 1604916000000000000\n", string(receiver.bodies[0]))
+}
+
+func TestPushGraphite(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	received := make(chan string)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		body, _ := ioutil.ReadAll(conn)
+		received <- string(body)
+	}()
+
+	reportTime := time.Date(2020, 11, 9, 9, 0, 0, 0, time.UTC)
+	p, err := newPushOutput(pushConfig{Mode: "graphite", URL: "tcp://" + listener.Addr().String(), Prefix: "at", Timeout: time.Second})
+	assert.Nil(t, err)
+	exporter := &reportedExporter{staticExporter: staticExporter{metrics{{"cov19_detail", &map[string]string{"province": "Niederösterreich"}, 800}}}, reportTime: reportTime}
+	other := &staticExporter{metrics{{"cov19_hospitalized", nil, 120}}}
+	assert.Nil(t, p.push(reportTime.Add(time.Hour), runExporters([]Exporter{exporter, other})))
+	assert.Equal(t, "at.cov19_detail.province.Niederoesterreich 800 1604912400\nat.cov19_hospitalized 120 1604916000\n", <-received)
+}
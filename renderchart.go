@@ -0,0 +1,478 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+type chartPoint struct {
+	date  time.Time
+	value float64
+}
+
+type chartSeries struct {
+	name   string
+	points []chartPoint
+}
+
+type chartRequest struct {
+	metric  string
+	regions []string
+	from    time.Time
+	to      time.Time
+	bar     bool
+	log     bool
+	average int
+	per100k bool
+	png     bool
+	width   int
+	height  int
+}
+
+var chartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+const maxChartSeries = 10
+
+//parseChartRequest reads the chart options from the query parameters
+func parseChartRequest(r *http.Request) (chartRequest, error) {
+	q := r.URL.Query()
+	c := chartRequest{metric: q.Get("metric"), regions: q["region"], width: 800, height: 400}
+	if c.metric == "" {
+		c.metric = "cov19_confirmed"
+	}
+	var err error
+	if q.Get("from") != "" {
+		if c.from, err = time.Parse(snapshotLayout, q.Get("from")); err != nil {
+			return c, err
+		}
+	}
+	if q.Get("to") != "" {
+		if c.to, err = time.Parse(snapshotLayout, q.Get("to")); err != nil {
+			return c, err
+		}
+	}
+	switch q.Get("type") {
+	case "", "line":
+	case "bar":
+		c.bar = true
+	default:
+		return c, errors.New("Unknown chart type " + q.Get("type"))
+	}
+	c.log = q.Get("scale") == "log"
+	c.per100k = q.Get("per100k") == "1" || q.Get("per100k") == "true"
+	switch q.Get("format") {
+	case "", "svg":
+	case "png":
+		c.png = true
+	default:
+		return c, errors.New("Unknown format " + q.Get("format"))
+	}
+	for _, p := range []struct {
+		name     string
+		value    *int
+		min, max int
+	}{{"average", &c.average, 0, 28}, {"width", &c.width, 200, 2000}, {"height", &c.height, 150, 1500}} {
+		if q.Get(p.name) == "" {
+			continue
+		}
+		v, err := strconv.Atoi(q.Get(p.name))
+		if err != nil || v < p.min || v > p.max {
+			return c, fmt.Errorf("%s must be between %d and %d", p.name, p.min, p.max)
+		}
+		*p.value = v
+	}
+	return c, nil
+}
+
+func seriesName(tags map[string]string) string {
+	for _, field := range []string{"bezirk", "province", "country"} {
+		if v, ok := tags[field]; ok {
+			return v
+		}
+	}
+	return "Austria"
+}
+
+//loadChartSeries reads the series of the requested regions from the history
+func loadChartSeries(history *historyStore, c chartRequest) ([]chartSeries, error) {
+	if history == nil {
+		return nil, errors.New("History is disabled")
+	}
+	points, err := history.query(c.metric, func(tags map[string]string) bool {
+		if _, group := tags["group"]; group {
+			return false
+		}
+		if len(c.regions) == 0 {
+			return true
+		}
+		name := normalizeName(seriesName(tags))
+		for _, r := range c.regions {
+			if normalizeName(r) == name {
+				return true
+			}
+		}
+		return false
+	}, c.from, c.to)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	result := make([]chartSeries, 0)
+	for _, p := range points {
+		name := seriesName(p.Tags)
+		i, ok := index[name]
+		if !ok {
+			i = len(result)
+			index[name] = i
+			result = append(result, chartSeries{name: name})
+		}
+		date, _ := time.Parse(snapshotLayout, p.Date)
+		result[i].points = append(result[i].points, chartPoint{date, p.Value})
+	}
+
+	for i := range result {
+		if c.per100k {
+			population := populationOf(result[i].name)
+			if population == 0 {
+				return nil, errors.New("Could not find population for " + result[i].name)
+			}
+			for j := range result[i].points {
+				result[i].points[j].value = result[i].points[j].value / float64(population) * 100000
+			}
+		}
+		if c.average > 1 {
+			result[i].points = movingAverage(result[i].points, c.average)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return lastValue(result[i]) > lastValue(result[j]) })
+	if len(result) > maxChartSeries {
+		result = result[:maxChartSeries]
+	}
+	return result, nil
+}
+
+func populationOf(name string) uint64 {
+	if population := he.mp.getPopulation(name); population > 0 {
+		return population
+	}
+	return mp.getPopulation(name)
+}
+
+func lastValue(s chartSeries) float64 {
+	if len(s.points) == 0 {
+		return 0
+	}
+	return s.points[len(s.points)-1].value
+}
+
+//movingAverage averages every point with the points of the preceding days within the window
+func movingAverage(points []chartPoint, days int) []chartPoint {
+	result := make([]chartPoint, len(points))
+	for i, p := range points {
+		sum, count := 0.0, 0
+		for j := i; j >= 0 && p.date.Sub(points[j].date) < time.Duration(days)*24*time.Hour; j-- {
+			sum += points[j].value
+			count++
+		}
+		result[i] = chartPoint{p.date, sum / float64(count)}
+	}
+	return result
+}
+
+func chartTitle(c chartRequest) string {
+	options := make([]string, 0)
+	if c.per100k {
+		options = append(options, "pro 100.000 Einwohner")
+	}
+	if c.average > 1 {
+		options = append(options, fmt.Sprintf("%d-Tage-Mittel", c.average))
+	}
+	if c.log {
+		options = append(options, "logarithmisch")
+	}
+	if len(options) == 0 {
+		return c.metric
+	}
+	return c.metric + " (" + strings.Join(options, ", ") + ")"
+}
+
+//chartCanvas is implemented by the svg and png output
+type chartCanvas interface {
+	line(x1, y1, x2, y2 float64, color string, width float64)
+	rect(x, y, w, h float64, color string)
+	text(x, y float64, s string, anchor string)
+	finish(w io.Writer) error
+}
+
+const (
+	chartLeft   = 70.0
+	chartRight  = 20.0
+	chartTop    = 40.0
+	chartBottom = 60.0
+)
+
+//renderChart draws the series as line or bar chart
+func renderChart(w io.Writer, series []chartSeries, c chartRequest) error {
+	var canvas chartCanvas
+	if c.png {
+		p, err := newPngCanvas(c.width, c.height)
+		if err != nil {
+			return err
+		}
+		canvas = p
+	} else {
+		canvas = newSvgCanvas(c.width, c.height)
+	}
+
+	dates := make([]time.Time, 0)
+	seen := make(map[time.Time]bool)
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, p := range s.points {
+			if !seen[p.date] {
+				seen[p.date] = true
+				dates = append(dates, p.date)
+			}
+			if c.log && p.value <= 0 {
+				continue
+			}
+			minValue, maxValue = math.Min(minValue, p.value), math.Max(maxValue, p.value)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	width, height := float64(c.width), float64(c.height)
+	plotWidth, plotHeight := width-chartLeft-chartRight, height-chartTop-chartBottom
+	canvas.text(chartLeft, 20, chartTitle(c), "start")
+	if len(dates) == 0 || math.IsInf(minValue, 1) {
+		canvas.text(width/2, height/2, "Keine Daten", "middle")
+		return canvas.finish(w)
+	}
+
+	ticks, lower, upper := linearTicks(math.Min(0, minValue), maxValue)
+	if c.log {
+		ticks, lower, upper = logTicks(minValue, maxValue)
+	}
+	y := func(v float64) float64 {
+		if c.log {
+			return chartTop + plotHeight - (math.Log10(v)-math.Log10(lower))/(math.Log10(upper)-math.Log10(lower))*plotHeight
+		}
+		return chartTop + plotHeight - (v-lower)/(upper-lower)*plotHeight
+	}
+	slot := plotWidth / float64(len(dates))
+	position := make(map[time.Time]int, len(dates))
+	for i, d := range dates {
+		position[d] = i
+	}
+	x := func(d time.Time) float64 {
+		return chartLeft + (float64(position[d])+0.5)*slot
+	}
+
+	for _, t := range ticks {
+		canvas.line(chartLeft, y(t), chartLeft+plotWidth, y(t), "#e0e0e0", 1)
+		canvas.text(chartLeft-6, y(t)+4, formatTick(t), "end")
+	}
+	labels := int(math.Max(1, math.Ceil(float64(len(dates))*70/plotWidth)))
+	for i, d := range dates {
+		if i%labels == 0 {
+			canvas.line(x(d), chartTop+plotHeight, x(d), chartTop+plotHeight+4, "#555555", 1)
+			canvas.text(x(d), chartTop+plotHeight+18, d.Format("02.01."), "middle")
+		}
+	}
+	canvas.line(chartLeft, chartTop, chartLeft, chartTop+plotHeight, "#555555", 1)
+	canvas.line(chartLeft, chartTop+plotHeight, chartLeft+plotWidth, chartTop+plotHeight, "#555555", 1)
+
+	base := y(lower)
+	for i, s := range series {
+		color := chartColors[i%len(chartColors)]
+		if c.bar {
+			barWidth := slot * 0.8 / float64(len(series))
+			for _, p := range s.points {
+				if c.log && p.value <= 0 {
+					continue
+				}
+				left := x(p.date) - slot*0.4 + float64(i)*barWidth
+				top := math.Min(y(p.value), base)
+				canvas.rect(left, top, barWidth, math.Abs(base-y(p.value)), color)
+			}
+		} else {
+			var previous *chartPoint
+			for j, p := range s.points {
+				if c.log && p.value <= 0 {
+					previous = nil
+					continue
+				}
+				if previous != nil {
+					canvas.line(x(previous.date), y(previous.value), x(p.date), y(p.value), color, 2)
+				} else if len(s.points) == 1 {
+					canvas.rect(x(p.date)-2, y(p.value)-2, 4, 4, color)
+				}
+				previous = &s.points[j]
+			}
+		}
+		legendX := chartLeft + float64(i%5)*plotWidth/5
+		legendY := height - 24 + float64(i/5)*14 - 8
+		canvas.rect(legendX, legendY-8, 10, 10, color)
+		canvas.text(legendX+14, legendY+1, s.name, "start")
+	}
+	return canvas.finish(w)
+}
+
+//linearTicks returns about 5 ticks with a step of 1, 2 or 5 times a power of 10
+func linearTicks(min float64, max float64) ([]float64, float64, float64) {
+	if max <= min {
+		max = min + 1
+	}
+	rough := (max - min) / 5
+	magnitude := math.Pow(10, math.Floor(math.Log10(rough)))
+	step := magnitude * 10
+	for _, f := range []float64{1, 2, 5} {
+		if f*magnitude >= rough {
+			step = f * magnitude
+			break
+		}
+	}
+	lower := math.Floor(min/step) * step
+	upper := math.Ceil(max/step) * step
+	ticks := make([]float64, 0)
+	for t := lower; t <= upper+step/2; t += step {
+		ticks = append(ticks, t)
+	}
+	return ticks, lower, upper
+}
+
+//logTicks returns the powers of 10 between min and max
+func logTicks(min float64, max float64) ([]float64, float64, float64) {
+	lower := math.Pow(10, math.Floor(math.Log10(min)))
+	upper := math.Pow(10, math.Ceil(math.Log10(max)))
+	if upper <= lower {
+		upper = lower * 10
+	}
+	ticks := make([]float64, 0)
+	for t := lower; t <= upper*1.001; t *= 10 {
+		ticks = append(ticks, t)
+	}
+	return ticks, lower, upper
+}
+
+func formatTick(f float64) string {
+	switch {
+	case math.Abs(f) >= 1000000:
+		return formatNumber(f/1000000) + "M"
+	case math.Abs(f) >= 10000:
+		return formatNumber(f/1000) + "k"
+	case f != 0 && math.Abs(f) < 1:
+		return strconv.FormatFloat(f, 'g', 3, 64)
+	}
+	return formatNumber(f)
+}
+
+type svgCanvas struct {
+	width, height int
+	content       strings.Builder
+}
+
+func newSvgCanvas(width int, height int) *svgCanvas {
+	return &svgCanvas{width: width, height: height}
+}
+
+func (s *svgCanvas) line(x1, y1, x2, y2 float64, color string, width float64) {
+	fmt.Fprintf(&s.content, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%.0f" stroke-linecap="round"/>`+"\n", x1, y1, x2, y2, color, width)
+}
+
+func (s *svgCanvas) rect(x, y, w, h float64, color string) {
+	fmt.Fprintf(&s.content, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, w, h, color)
+}
+
+func (s *svgCanvas) text(x, y float64, text string, anchor string) {
+	fmt.Fprintf(&s.content, `<text x="%.1f" y="%.1f" text-anchor="%s">%s</text>`+"\n", x, y, anchor, html.EscapeString(text))
+}
+
+func (s *svgCanvas) finish(w io.Writer) error {
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n"+
+		`<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n%s</svg>\n", s.width, s.height, s.width, s.height, s.content.String())
+	return err
+}
+
+//The Go font covers Latin-1, so the umlauts of the region names are drawn
+var chartFont, chartFontErr = opentype.Parse(goregular.TTF)
+
+type pngCanvas struct {
+	image *image.RGBA
+	face  font.Face
+}
+
+func newPngCanvas(width int, height int) (*pngCanvas, error) {
+	if chartFontErr != nil {
+		return nil, chartFontErr
+	}
+	//A face caches glyphs and is not safe for concurrent use, so every canvas gets its own
+	face, err := opentype.NewFace(chartFont, &opentype.FaceOptions{Size: 12, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	canvas := &pngCanvas{image.NewRGBA(image.Rect(0, 0, width, height)), face}
+	draw.Draw(canvas.image, canvas.image.Bounds(), image.White, image.Point{}, draw.Src)
+	return canvas, nil
+}
+
+func parseColor(hex string) color.RGBA {
+	v, _ := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}
+}
+
+func (p *pngCanvas) line(x1, y1, x2, y2 float64, hex string, width float64) {
+	c := image.NewUniform(parseColor(hex))
+	steps := math.Max(1, math.Ceil(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))*2))
+	half := width / 2
+	for i := 0.0; i <= steps; i++ {
+		x := x1 + (x2-x1)*i/steps
+		y := y1 + (y2-y1)*i/steps
+		r := image.Rect(int(math.Round(x-half)), int(math.Round(y-half)), int(math.Round(x+half)), int(math.Round(y+half)))
+		if r.Empty() {
+			r = image.Rect(int(x), int(y), int(x)+1, int(y)+1)
+		}
+		draw.Draw(p.image, r, c, image.Point{}, draw.Src)
+	}
+}
+
+func (p *pngCanvas) rect(x, y, w, h float64, hex string) {
+	r := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+	draw.Draw(p.image, r, image.NewUniform(parseColor(hex)), image.Point{}, draw.Src)
+}
+
+func (p *pngCanvas) text(x, y float64, text string, anchor string) {
+	d := font.Drawer{Dst: p.image, Src: image.Black, Face: p.face}
+	width := d.MeasureString(text).Round()
+	switch anchor {
+	case "middle":
+		x -= float64(width) / 2
+	case "end":
+		x -= float64(width)
+	}
+	d.Dot = fixed.P(int(x), int(y))
+	d.DrawString(text)
+}
+
+func (p *pngCanvas) finish(w io.Writer) error {
+	return png.Encode(w, p.image)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChartRequest(t *testing.T) {
+	c, err := parseChartRequest(httptest.NewRequest("GET", "/render/chart?metric=cov19_detail&region=Wien&region=Tirol&from=2020-03-20&type=bar&scale=log&average=7&per100k=1&format=png&width=400", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "cov19_detail", c.metric)
+	assert.Equal(t, []string{"Wien", "Tirol"}, c.regions)
+	assert.Equal(t, time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC), c.from)
+	assert.True(t, c.to.IsZero())
+	assert.True(t, c.bar && c.log && c.per100k && c.png)
+	assert.Equal(t, 7, c.average)
+	assert.Equal(t, 400, c.width)
+	assert.Equal(t, 400, c.height)
+
+	c, err = parseChartRequest(httptest.NewRequest("GET", "/render/chart", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "cov19_confirmed", c.metric)
+	assert.False(t, c.bar || c.log || c.per100k || c.png)
+
+	for _, query := range []string{"type=pie", "format=gif", "width=10", "average=x", "from=20.03.2020"} {
+		_, err = parseChartRequest(httptest.NewRequest("GET", "/render/chart?"+query, nil))
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestLoadChartSeries(t *testing.T) {
+	store, cleanup := newTestHistory(t)
+	defer cleanup()
+	start := time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, store.record(start.AddDate(0, 0, i), testSnapshot(float64(100*(i+1)), float64(10*(i+1)))))
+	}
+
+	series, err := loadChartSeries(store, chartRequest{metric: "cov19_detail"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(series))
+	assert.Equal(t, "Tirol", series[0].name)
+	assert.Equal(t, 3, len(series[0].points))
+	assert.Equal(t, 30.0, series[1].points[2].value)
+
+	series, err = loadChartSeries(store, chartRequest{metric: "cov19_detail", regions: []string{"wien"}, from: start.AddDate(0, 0, 1), average: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(series))
+	assert.Equal(t, []chartPoint{{start.AddDate(0, 0, 1), 20}, {start.AddDate(0, 0, 2), 25}}, series[0].points)
+
+	series, err = loadChartSeries(store, chartRequest{metric: "cov19_confirmed", per100k: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "Austria", series[0].name)
+	assert.InDelta(t, 300/float64(populationOf("Austria"))*100000, series[0].points[2].value, 0.0001)
+
+	series, err = loadChartSeries(store, chartRequest{metric: "cov19_vaccination_age_doses"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(series))
+
+	_, err = loadChartSeries(nil, chartRequest{metric: "cov19_confirmed"})
+	assert.NotNil(t, err)
+}
+
+func TestMovingAverage(t *testing.T) {
+	day := time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC)
+	points := []chartPoint{{day, 1}, {day.AddDate(0, 0, 1), 2}, {day.AddDate(0, 0, 3), 6}}
+	assert.Equal(t, []chartPoint{{day, 1}, {day.AddDate(0, 0, 1), 1.5}, {day.AddDate(0, 0, 3), 4}}, movingAverage(points, 3))
+}
+
+func TestChartTicks(t *testing.T) {
+	ticks, lower, upper := linearTicks(0, 87)
+	assert.Equal(t, []float64{0, 20, 40, 60, 80, 100}, ticks)
+	assert.Equal(t, 0.0, lower)
+	assert.Equal(t, 100.0, upper)
+
+	ticks, lower, upper = logTicks(3, 4200)
+	assert.Equal(t, 5, len(ticks))
+	assert.Equal(t, 1.0, lower)
+	assert.Equal(t, 10000.0, upper)
+
+	assert.Equal(t, "25k", formatTick(25000))
+	assert.Equal(t, "1.5M", formatTick(1500000))
+	assert.Equal(t, "0.5", formatTick(0.5))
+}
+
+func TestRenderChart(t *testing.T) {
+	day := time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC)
+	series := []chartSeries{
+		{"Wien", []chartPoint{{day, 10}, {day.AddDate(0, 0, 1), 20}, {day.AddDate(0, 0, 2), 0}}},
+		{"Tirol & Vorarlberg", []chartPoint{{day, 5}, {day.AddDate(0, 0, 1), 50}}},
+	}
+
+	svg := strings.Builder{}
+	assert.Nil(t, renderChart(&svg, series, chartRequest{metric: "cov19_detail", average: 7, width: 800, height: 400}))
+	assert.Nil(t, xml.Unmarshal([]byte(svg.String()), new(interface{})))
+	assert.Contains(t, svg.String(), "cov19_detail (7-Tage-Mittel)")
+	assert.Contains(t, svg.String(), "Tirol &amp; Vorarlberg")
+	assert.Contains(t, svg.String(), ">20.03.<")
+	assert.Equal(t, 2, strings.Count(svg.String(), `stroke="`+chartColors[0]))
+
+	svg.Reset()
+	assert.Nil(t, renderChart(&svg, series, chartRequest{metric: "cov19_detail", bar: true, log: true, width: 800, height: 400}))
+	assert.Equal(t, 4+2, strings.Count(svg.String(), "<rect")-1)
+	assert.Contains(t, svg.String(), ">100<")
+
+	svg.Reset()
+	assert.Nil(t, renderChart(&svg, nil, chartRequest{metric: "cov19_detail", width: 800, height: 400}))
+	assert.Contains(t, svg.String(), "Keine Daten")
+
+	buffer := bytes.Buffer{}
+	assert.Nil(t, renderChart(&buffer, series, chartRequest{metric: "cov19_detail", png: true, width: 300, height: 200}))
+	img, err := png.Decode(&buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	assert.Equal(t, 200, img.Bounds().Dy())
+}
+
+func TestPngCanvasUmlauts(t *testing.T) {
+	canvas, err := newPngCanvas(100, 20)
+	assert.Nil(t, err)
+	for _, r := range "KärntenÖÜß" {
+		_, ok := canvas.face.GlyphAdvance(r)
+		assert.True(t, ok, string(r))
+	}
+
+	blank, _ := newPngCanvas(100, 20)
+	canvas.text(10, 15, "ä", "start")
+	blank.text(10, 15, "a", "start")
+	assert.NotEqual(t, blank.image.Pix, canvas.image.Pix)
+}
+
+func TestHandleRenderChart(t *testing.T) {
+	store, cleanup := newTestHistory(t)
+	defer cleanup()
+	assert.Nil(t, store.record(time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC), testSnapshot(100, 40)))
+	defer func(previous *historyStore) { history = previous }(history)
+	history = store
+
+	recorder := httptest.NewRecorder()
+	handleRenderChart(recorder, httptest.NewRequest("GET", "/render/chart?metric=cov19_confirmed&format=png", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "image/png", recorder.Header().Get("Content-type"))
+
+	defer func(previous error) { chartFontErr = previous }(chartFontErr)
+	chartFontErr = errors.New("Broken font")
+	recorder = httptest.NewRecorder()
+	handleRenderChart(recorder, httptest.NewRequest("GET", "/render/chart?metric=cov19_confirmed&format=png", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, "Broken font\n", recorder.Body.String())
+}
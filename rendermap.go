@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type mapRegion struct {
+	name     string
+	boundary *boundary
+	value    float64
+	valid    bool
+}
+
+type mapMetric struct {
+	title   string
+	metric  string
+	history bool
+}
+
+var mapMetrics = map[string]mapMetric{
+	"infected":  {"Infizierte", "cov19_bezirk_infected", false},
+	"per100k":   {"Infizierte pro 100.000 Einwohner", "cov19_bezirk_infected_100k", false},
+	"incidence": {"7-Tage-Inzidenz", "cov19_bezirk_infected", true},
+	"risk":      {"Risikostufe (7-Tage-Inzidenz)", "cov19_bezirk_infected", true},
+}
+
+//errMapWithoutHistory is returned for the metrics that are computed from the history if there is none
+var errMapWithoutHistory = errors.New("The metric requires -history-dir")
+
+var sequentialColors = []string{"#ffffb2", "#fecc5c", "#fd8d3c", "#f03b20", "#bd0026"}
+
+var riskLevels = []struct {
+	label string
+	limit float64
+	color string
+}{
+	{"gering (< 10)", 10, "#60b347"},
+	{"mittel (< 50)", 50, "#f7d038"},
+	{"hoch (< 100)", 100, "#f28c28"},
+	{"sehr hoch (≥ 100)", math.Inf(1), "#d7263d"},
+}
+
+const (
+	mapWidth    = 800.0
+	mapMargin   = 10.0
+	legendWidth = 220.0
+	noDataColor = "#d9d9d9"
+)
+
+//getBezirkMapRegions returns the districts with the value of the selected metric
+func (a *api) getBezirkMapRegions(kind string, history *historyStore, date time.Time) ([]mapRegion, error) {
+	m, ok := mapMetrics[kind]
+	if !ok {
+		return nil, errors.New("Unknown metric " + kind)
+	}
+	if m.history && history == nil {
+		return nil, errMapWithoutHistory
+	}
+	if a.bezirkBoundaries == nil {
+		return nil, errors.New("No district boundaries, see geo/README.md")
+	}
+	bezirke, err := a.he.getBezirke()
+	if err != nil {
+		return nil, err
+	}
+
+	var weekAgo map[string]float64
+	if m.history {
+		weekAgo = make(map[string]float64)
+		day := calendarDay(date).AddDate(0, 0, -7)
+		points, err := history.byRegion(m.metric, "bezirk", day, day)
+		if err != nil {
+			return nil, err
+		}
+		for name, p := range points {
+			weekAgo[name] = p[0].Value
+		}
+	}
+
+	result := make([]mapRegion, 0)
+	for _, b := range bezirke {
+		if b.Name != m.metric {
+			continue
+		}
+		name := (*b.Tags)["bezirk"]
+		region := mapRegion{name: name, boundary: a.bezirkBoundaries.getBoundary(a.he.mp.getId(name), name), value: b.Value, valid: true}
+		data := a.he.mp.getMetadata(name)
+		if weekAgo != nil {
+			previous, ok := weekAgo[normalizeName(name)]
+			region.valid = ok && data != nil && data.population > 0
+			if region.valid {
+				region.value = (b.Value - previous) / float64(data.population) * 100000
+			}
+		}
+		result = append(result, region)
+	}
+	return result, nil
+}
+
+//renderMap draws the boundaries of the regions as SVG choropleth, regions without boundary are listed below the legend
+func renderMap(w io.Writer, regions []mapRegion, kind string, date time.Time) error {
+	m, ok := mapMetrics[kind]
+	if !ok {
+		return errors.New("Unknown metric " + kind)
+	}
+	bounds, ok := mapBounds(regions)
+	if !ok {
+		return errors.New("No region has a boundary")
+	}
+	scaleX := math.Cos((bounds[1] + bounds[3]) / 2 * math.Pi / 180)
+	scale := (mapWidth - 2*mapMargin) / ((bounds[2] - bounds[0]) * scaleX)
+	height := (bounds[3]-bounds[1])*scale + 2*mapMargin
+	project := func(long float64, lat float64) (float64, float64) {
+		return mapMargin + (long-bounds[0])*scaleX*scale, mapMargin + (bounds[3]-lat)*scale
+	}
+
+	colorOf, legend := mapColors(regions, kind)
+
+	svg := &strings.Builder{}
+	fmt.Fprintf(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="sans-serif" font-size="12">`+"\n",
+		mapWidth+legendWidth, math.Max(height, 200), mapWidth+legendWidth, math.Max(height, 200))
+	fmt.Fprintf(svg, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	missing := make([]string, 0)
+	for _, r := range regions {
+		if r.boundary == nil {
+			missing = append(missing, r.name)
+			continue
+		}
+		path := &strings.Builder{}
+		for _, polygon := range r.boundary.polygons {
+			for _, ring := range polygon {
+				for i, p := range ring {
+					x, y := project(p[0], p[1])
+					if i == 0 {
+						fmt.Fprintf(path, "M%.1f %.1f", x, y)
+					} else {
+						fmt.Fprintf(path, "L%.1f %.1f", x, y)
+					}
+				}
+				path.WriteString("Z")
+			}
+		}
+		title := fmt.Sprintf("<title>%s: %s</title>", html.EscapeString(r.name), formatMapValue(r, kind))
+		fmt.Fprintf(svg, `<path d="%s" fill="%s" fill-rule="evenodd" stroke="#ffffff" stroke-width="0.5">%s</path>`+"\n", path.String(), colorOf(r), title)
+	}
+
+	x := mapWidth + 10
+	fmt.Fprintf(svg, `<text x="%.0f" y="24" font-size="14" font-weight="bold">%s</text>`+"\n", x, html.EscapeString(m.title))
+	for i, l := range legend {
+		y := 40 + float64(i)*22
+		fmt.Fprintf(svg, `<rect x="%.0f" y="%.0f" width="16" height="16" fill="%s" stroke="#555555" stroke-width="0.5"/>`, x, y, l[1])
+		fmt.Fprintf(svg, `<text x="%.0f" y="%.0f">%s</text>`+"\n", x+24, y+12, html.EscapeString(l[0]))
+	}
+	y := 40 + float64(len(legend))*22 + 20
+	fmt.Fprintf(svg, `<text x="%.0f" y="%.0f" fill="#555555">Stand: %s</text>`+"\n", x, y, date.Format("02.01.2006 15:04"))
+	fmt.Fprintf(svg, `<text x="%.0f" y="%.0f" fill="#555555" font-size="10">Grenzen: Statistik Austria (CC BY 4.0)</text>`+"\n", x, y+16)
+	y += 16
+	for i, name := range missing {
+		if i == 0 {
+			fmt.Fprintf(svg, `<text x="%.0f" y="%.0f" fill="#555555">Ohne Grenze:</text>`+"\n", x, y+22)
+		}
+		fmt.Fprintf(svg, `<text x="%.0f" y="%.0f" fill="#555555">%s</text>`+"\n", x, y+40+float64(i)*16, html.EscapeString(name))
+	}
+	svg.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, svg.String())
+	return err
+}
+
+//mapBounds returns the bounding box of the boundaries, false if no region has a boundary
+func mapBounds(regions []mapRegion) ([4]float64, bool) {
+	bounds := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	extend := func(long float64, lat float64) {
+		bounds[0], bounds[1] = math.Min(bounds[0], long), math.Min(bounds[1], lat)
+		bounds[2], bounds[3] = math.Max(bounds[2], long), math.Max(bounds[3], lat)
+	}
+	for _, r := range regions {
+		if r.boundary == nil {
+			continue
+		}
+		for _, polygon := range r.boundary.polygons {
+			for _, ring := range polygon {
+				for _, p := range ring {
+					extend(p[0], p[1])
+				}
+			}
+		}
+	}
+	return bounds, !math.IsInf(bounds[0], 1)
+}
+
+//mapColors returns the fill color of a region and the legend entries (label, color)
+func mapColors(regions []mapRegion, kind string) (func(mapRegion) string, [][2]string) {
+	if kind == "risk" {
+		legend := make([][2]string, 0, len(riskLevels)+1)
+		for _, l := range riskLevels {
+			legend = append(legend, [2]string{l.label, l.color})
+		}
+		legend = append(legend, [2]string{"keine Daten", noDataColor})
+		return func(r mapRegion) string {
+			if !r.valid {
+				return noDataColor
+			}
+			for _, l := range riskLevels {
+				if r.value < l.limit {
+					return l.color
+				}
+			}
+			return noDataColor
+		}, legend
+	}
+
+	values := make([]float64, 0, len(regions))
+	for _, r := range regions {
+		if r.valid {
+			values = append(values, r.value)
+		}
+	}
+	sort.Float64s(values)
+	breaks := make([]float64, 0, len(sequentialColors)-1)
+	for i := 1; i < len(sequentialColors) && len(values) > 0; i++ {
+		breaks = append(breaks, values[len(values)*i/len(sequentialColors)])
+	}
+
+	legend := make([][2]string, 0, len(sequentialColors)+1)
+	if len(values) > 0 {
+		lower := values[0]
+		for i, color := range sequentialColors {
+			upper := values[len(values)-1]
+			if i < len(breaks) {
+				upper = breaks[i]
+			}
+			legend = append(legend, [2]string{fmt.Sprintf("%s – %s", formatNumber(lower), formatNumber(upper)), color})
+			lower = upper
+		}
+	}
+	legend = append(legend, [2]string{"keine Daten", noDataColor})
+	return func(r mapRegion) string {
+		if !r.valid {
+			return noDataColor
+		}
+		for i, b := range breaks {
+			if r.value < b {
+				return sequentialColors[i]
+			}
+		}
+		return sequentialColors[len(sequentialColors)-1]
+	}, legend
+}
+
+func formatMapValue(r mapRegion, kind string) string {
+	if !r.valid {
+		return "keine Daten"
+	}
+	if kind == "risk" {
+		return formatNumber(r.value) + " (7-Tage-Inzidenz)"
+	}
+	return formatNumber(r.value)
+}
+
+func formatNumber(f float64) string {
+	if f == math.Trunc(f) {
+		return fmt.Sprintf("%.0f", f)
+	}
+	return fmt.Sprintf("%.1f", f)
+}
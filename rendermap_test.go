@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMap(t *testing.T) {
+	boundaries, err := parseBoundaries([]byte(testBoundaries))
+	assert.Nil(t, err)
+	regions := []mapRegion{
+		{"Graz(Stadt)", boundaries.getBoundary("601", "Graz(Stadt)"), 5, true},
+		{"Wien(Stadt)", boundaries.getBoundary("900", "Wien(Stadt)"), 120, true},
+		{"Linz(Stadt)", nil, 0, false},
+	}
+
+	svg := strings.Builder{}
+	assert.Nil(t, renderMap(&svg, regions, "risk", time.Date(2020, 11, 2, 14, 0, 0, 0, time.UTC)))
+	assert.Nil(t, xml.Unmarshal([]byte(svg.String()), new(interface{})))
+	assert.Equal(t, 2, strings.Count(svg.String(), "<path"))
+	assert.Contains(t, svg.String(), `fill="#60b347" fill-rule="evenodd"`)
+	assert.Contains(t, svg.String(), `fill="#d7263d" fill-rule="evenodd"`)
+	assert.Contains(t, svg.String(), "Ohne Grenze:")
+	assert.Contains(t, svg.String(), ">Linz(Stadt)</text>")
+	assert.Contains(t, svg.String(), "Stand: 02.11.2020 14:00")
+	assert.Contains(t, svg.String(), "Risikostufe")
+	assert.Contains(t, svg.String(), "Grenzen: Statistik Austria (CC BY 4.0)")
+
+	svg.Reset()
+	assert.Nil(t, renderMap(&svg, regions, "infected", time.Now()))
+	assert.Contains(t, svg.String(), "5 – 120")
+	assert.NotNil(t, renderMap(&svg, regions, "unknown", time.Now()))
+	assert.NotNil(t, renderMap(&svg, regions[2:], "infected", time.Now()))
+}
+
+func TestBezirkMapRegions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	writeSnapshot(t, dir, "", "Bezirke.js", `var dpBezirke = [{"label":"Graz(Stadt)","y":340},{"label":"Unbekannt","y":20}];`)
+	store, cleanup := newTestHistory(t)
+	defer cleanup()
+	date := time.Date(2020, 11, 9, 10, 0, 0, 0, time.UTC)
+	assert.Nil(t, store.record(date.AddDate(0, 0, -7), metrics{{"cov19_bezirk_infected", &map[string]string{"bezirk": "Graz(Stadt)"}, 51}}))
+
+	mapApi := &api{he: &healthMinistryExporter{mp: e.mp, url: "file://" + filepath.ToSlash(dir)}}
+	_, err = mapApi.getBezirkMapRegions("incidence", store, date)
+	assert.NotNil(t, err)
+
+	mapApi.bezirkBoundaries, err = parseBoundaries([]byte(testBoundaries))
+	assert.Nil(t, err)
+	regions, err := mapApi.getBezirkMapRegions("incidence", store, date)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(regions))
+	assert.True(t, regions[0].valid)
+	assert.InDelta(t, 289.0/288806*100000, regions[0].value, 0.0001)
+	assert.NotNil(t, regions[0].boundary)
+	assert.False(t, regions[1].valid)
+
+	regions, err = mapApi.getBezirkMapRegions("per100k", nil, date)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(regions))
+
+	_, err = mapApi.getBezirkMapRegions("risk", nil, date)
+	assert.Equal(t, errMapWithoutHistory, err)
+
+	_, err = mapApi.getBezirkMapRegions("unknown", nil, date)
+	assert.NotNil(t, err)
+}
+
+func TestHandleRenderBezirkMapWithoutHistory(t *testing.T) {
+	defer func(previous *historyStore) { history = previous }(history)
+	history = nil
+	recorder := httptest.NewRecorder()
+	handleRenderBezirkMap(recorder, httptest.NewRequest("GET", "/render/map/bezirk.svg?metric=incidence", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "The metric incidence requires -history-dir\n", recorder.Body.String())
+}
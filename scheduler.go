@@ -0,0 +1,105 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+//scheduler periodically runs all exporters and records the results in the history
+type scheduler struct {
+	exporters []Exporter
+	history   *historyStore
+	changes   *changeBroker
+	webhooks  *webhookNotifier
+	output    *pushOutput
+	api       *api
+	interval  time.Duration
+	started   sync.Once
+
+	mu        sync.Mutex
+	current   metrics
+	updated   time.Time
+	dashboard *dashboard
+}
+
+func newScheduler(exporters []Exporter, history *historyStore) *scheduler {
+	return &scheduler{exporters: exporters, history: history, changes: newChangeBroker()}
+}
+
+//now is the replay date if snapshots are replayed, the current time otherwise
+func now() time.Time {
+	if date := replay.get(); !date.IsZero() {
+		return date
+	}
+	return time.Now()
+}
+
+func (s *scheduler) refresh() metrics {
+	results := runExporters(s.exporters)
+	result := mergeResults(results)
+	updated := now()
+
+	s.mu.Lock()
+	previous := s.current
+	s.current = result
+	s.updated = updated
+	s.mu.Unlock()
+
+	if previous != nil {
+		if changes := diffMetrics(previous, result); len(changes) > 0 {
+			addReportTimes(changes, results)
+			s.changes.publish(updated, changes)
+		}
+	}
+
+	if s.history != nil {
+		if err := s.history.record(updated, result); err != nil {
+			logger.Print(err)
+		}
+	}
+	if s.output != nil {
+		if err := s.output.push(updated, results); err != nil {
+			logger.Print(err)
+		}
+	}
+	if s.webhooks != nil {
+		s.webhooks.check(updated, results, previous, result)
+	}
+	if s.api != nil {
+		//the dashboard is built once per refresh instead of fetching the sources on every page load
+		if d, err := s.api.getDashboard(s.history, updated); err != nil {
+			logger.Print(err)
+		} else {
+			s.mu.Lock()
+			s.dashboard = &d
+			s.mu.Unlock()
+		}
+	}
+	return result
+}
+
+//start runs the scheduler in the background, it is only started once something consumes the refreshes
+func (s *scheduler) start() {
+	s.started.Do(func() { go s.run(s.interval) })
+}
+
+func (s *scheduler) run(interval time.Duration) {
+	s.refresh()
+	for range time.Tick(interval) {
+		s.refresh()
+	}
+}
+
+//cachedDashboard returns the dashboard of the last successful refresh, nil before
+func (s *scheduler) cachedDashboard() *dashboard {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dashboard
+}
+
+//snapshot returns the metrics of the last refresh
+func (s *scheduler) snapshot() (metrics, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current, s.updated
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const snapshotLayout = "2006-01-02"
+
+//snapshotClock selects the dated snapshot that is read from directory sources
+type snapshotClock struct {
+	mu   sync.Mutex
+	date time.Time
+}
+
+//replay is the snapshot date of all file sources, the zero value selects the latest snapshot
+var replay = &snapshotClock{}
+
+func (c *snapshotClock) get() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.date
+}
+
+func (c *snapshotClock) set(date time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.date = date
+}
+
+func (c *snapshotClock) advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.date = c.date.Add(d)
+	return c.date
+}
+
+func isFileURL(url string) bool {
+	return strings.HasPrefix(url, "file://")
+}
+
+//readFromFile reads a file:// url. If the file does not exist, the closest existing parent directory is
+//searched for dated snapshot directories (e.g. 2020-03-20/Bezirke.js) and the newest one not after the
+//replay date is used.
+func readFromFile(url string) ([]byte, error) {
+	path := filepath.FromSlash(strings.TrimPrefix(url, "file://"))
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return ioutil.ReadFile(path)
+	}
+
+	base, rest := path, ""
+	for {
+		if info, err := os.Stat(base); err == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(base)
+		if parent == base {
+			return nil, errors.New("Could not find " + path)
+		}
+		rest = filepath.Join(filepath.Base(base), rest)
+		base = parent
+	}
+
+	snapshots, err := snapshotDirs(base)
+	if err != nil {
+		return nil, err
+	}
+	date := replay.get()
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !date.IsZero() && snapshots[i].After(date) {
+			continue
+		}
+		file := filepath.Join(base, snapshots[i].Format(snapshotLayout), rest)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return ioutil.ReadFile(file)
+		}
+	}
+	return nil, errors.New("Could not find snapshot for " + path)
+}
+
+//snapshotDirs returns the sorted dates of all snapshot directories in dir
+func snapshotDirs(dir string) ([]time.Time, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]time.Time, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if date, err := time.Parse(snapshotLayout, e.Name()); err == nil {
+			result = append(result, date)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+	return result, nil
+}
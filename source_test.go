@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSnapshot(t *testing.T, dir string, date string, name string, content string) {
+	path := filepath.Join(dir, date, name)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestReadFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	writeSnapshot(t, dir, "", "plain.txt", "plain")
+
+	content, err := readFromGet("file://" + filepath.ToSlash(filepath.Join(dir, "plain.txt")))
+	assert.Nil(t, err)
+	assert.Equal(t, "plain", string(content))
+
+	_, err = readFromGet("file://" + filepath.ToSlash(filepath.Join(dir, "missing.txt")))
+	assert.NotNil(t, err)
+}
+
+func TestReadFromSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer replay.set(time.Time{})
+
+	writeSnapshot(t, dir, "2020-03-20", "data/SimpleData.js", `var Erkrankungen = "100";`)
+	writeSnapshot(t, dir, "2020-03-22", "data/SimpleData.js", `var Erkrankungen = "300";`)
+	writeSnapshot(t, dir, "2020-03-23", "data/Other.js", `other`)
+	writeSnapshot(t, dir, "notadate", "data/SimpleData.js", `var Erkrankungen = "999";`)
+	url := "file://" + filepath.ToSlash(dir) + "/data/SimpleData.js"
+
+	value, err := readJsVarFromGet(url, "Erkrankungen")
+	assert.Nil(t, err)
+	assert.Equal(t, "300", value)
+
+	replay.set(time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC))
+	value, err = readJsVarFromGet(url, "Erkrankungen")
+	assert.Nil(t, err)
+	assert.Equal(t, "100", value)
+
+	replay.advance(24 * time.Hour)
+	value, err = readJsVarFromGet(url, "Erkrankungen")
+	assert.Nil(t, err)
+	assert.Equal(t, "100", value)
+
+	replay.advance(24 * time.Hour)
+	value, err = readJsVarFromGet(url, "Erkrankungen")
+	assert.Nil(t, err)
+	assert.Equal(t, "300", value)
+
+	replay.set(time.Date(2020, 3, 19, 0, 0, 0, 0, time.UTC))
+	_, err = readJsVarFromGet(url, "Erkrankungen")
+	assert.NotNil(t, err)
+}
+
+func TestHealthMinistryFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	writeSnapshot(t, dir, "2020-03-20", "Bezirke.js", `var dpBezirke = [{"label":"Graz(Stadt)","y":52},{"label":"Wien(Stadt)","y":380}];`)
+
+	h := &healthMinistryExporter{mp: e.mp, url: "file://" + filepath.ToSlash(dir)}
+	result, err := h.getBezirke()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(result))
+	assert.Equal(t, float64(52), result.findMetric("cov19_bezirk_infected", "bezirk=Graz(Stadt)").Value)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//streamHeartbeat keeps idle connections open through proxies
+var streamHeartbeat = 30 * time.Second
+
+//streamOrigins are the origins of other sites whose pages may open WebSocket connections, "*" allows all
+var streamOrigins []string
+
+var streamUpgrader = websocket.Upgrader{CheckOrigin: checkStreamOrigin}
+
+//checkStreamOrigin accepts WebSocket connections of clients without Origin (no browser), of pages on the same host
+//and of streamOrigins
+func checkStreamOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range streamOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+//filterChanges keeps the changes of the given metrics, all changes if metrics is empty
+func filterChanges(event changeEvent, metrics []string) (changeEvent, bool) {
+	if len(metrics) == 0 {
+		return event, true
+	}
+	changes := make([]metricChange, 0, len(event.Changes))
+	for _, c := range event.Changes {
+		if contains(metrics, c.Metric) {
+			changes = append(changes, c)
+		}
+	}
+	event.Changes = changes
+	return event, len(changes) > 0
+}
+
+//lastEventID reads the Last-Event-ID header of reconnecting EventSource clients or the last_event_id parameter
+func lastEventID(r *http.Request) (uint64, bool) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	return id, err == nil
+}
+
+//serveStream pushes the change events as Server-Sent Events or, for upgrade requests, as WebSocket messages
+func serveStream(w http.ResponseWriter, r *http.Request, broker *changeBroker) {
+	metrics := splitList(r.URL.Query()["metric"])
+	last, resume := lastEventID(r)
+	events, missed := broker.subscribe(last)
+	defer broker.unsubscribe(events)
+	if !resume {
+		missed = nil
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		conn, err := streamUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		streamWebSocket(conn, events, missed, metrics)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprint(w, "retry: 10000\n\n")
+	for _, e := range missed {
+		writeServerSentEvent(w, e, metrics)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-events:
+			writeServerSentEvent(w, e, metrics)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, event changeEvent, metrics []string) {
+	event, ok := filterChanges(event, metrics)
+	if !ok {
+		return
+	}
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "id: %d\nevent: change\ndata: %s\n\n", event.ID, data)
+}
+
+func streamWebSocket(conn *websocket.Conn, events chan changeEvent, missed []changeEvent, metrics []string) {
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	write := func(e changeEvent) error {
+		if e, ok := filterChanges(e, metrics); ok {
+			return conn.WriteJSON(e)
+		}
+		return nil
+	}
+	for _, e := range missed {
+		if write(e) != nil {
+			return
+		}
+	}
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-closed:
+			return
+		case e := <-events:
+			err = write(e)
+		case <-heartbeat.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterChanges(t *testing.T) {
+	event := changeEvent{ID: 1, Changes: []metricChange{{Metric: "cov19_confirmed"}, {Metric: "cov19_dead"}}}
+	filtered, ok := filterChanges(event, []string{"cov19_dead"})
+	assert.True(t, ok)
+	assert.Equal(t, []metricChange{{Metric: "cov19_dead"}}, filtered.Changes)
+	_, ok = filterChanges(event, []string{"cov19_healed"})
+	assert.False(t, ok)
+	filtered, ok = filterChanges(event, nil)
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(filtered.Changes))
+}
+
+func readServerSentEvent(t *testing.T, reader *bufio.Reader) string {
+	lines := make([]string, 0)
+	for {
+		line, err := reader.ReadString('\n')
+		assert.Nil(t, err)
+		if line == "\n" {
+			return strings.Join(lines, "")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestServerSentEvents(t *testing.T) {
+	broker := newChangeBroker()
+	date := time.Date(2020, 3, 20, 10, 0, 0, 0, time.UTC)
+	broker.publish(date, []metricChange{{Metric: "cov19_confirmed", Labels: map[string]string{}, New: 100}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { serveStream(w, r, broker) }))
+	defer server.Close()
+
+	request, err := http.NewRequest("GET", server.URL+"?metric=cov19_confirmed", nil)
+	assert.Nil(t, err)
+	request.Header.Set("Last-Event-ID", "0")
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, "text/event-stream; charset=utf-8", response.Header.Get("Content-type"))
+
+	reader := bufio.NewReader(response.Body)
+	assert.Equal(t, "retry: 10000\n", readServerSentEvent(t, reader))
+	assert.Equal(t, "id: 1\nevent: change\ndata: {\"id\":1,\"time\":\"2020-03-20T10:00:00Z\",\"changes\":[{\"metric\":\"cov19_confirmed\",\"labels\":{},\"old\":null,\"new\":100}]}\n",
+		readServerSentEvent(t, reader))
+
+	broker.publish(date, []metricChange{{Metric: "cov19_dead", Labels: map[string]string{}, New: 1}})
+	old := 100.0
+	broker.publish(date, []metricChange{{Metric: "cov19_confirmed", Labels: map[string]string{}, Old: &old, New: 120}})
+	assert.Equal(t, "id: 3\nevent: change\ndata: {\"id\":3,\"time\":\"2020-03-20T10:00:00Z\",\"changes\":[{\"metric\":\"cov19_confirmed\",\"labels\":{},\"old\":100,\"new\":120}]}\n",
+		readServerSentEvent(t, reader))
+}
+
+func TestWebSocketStream(t *testing.T) {
+	broker := newChangeBroker()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { serveStream(w, r, broker) }))
+	defer server.Close()
+
+	_, response, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Origin": {"https://evil.example.com"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, response.StatusCode)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Origin": {server.URL}})
+	assert.Nil(t, err)
+	defer conn.Close()
+	for i := 0; i < 100; i++ {
+		broker.mu.Lock()
+		subscribed := len(broker.subscribers)
+		broker.mu.Unlock()
+		if subscribed > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	broker.publish(time.Date(2020, 3, 20, 10, 0, 0, 0, time.UTC), []metricChange{{Metric: "cov19_confirmed", Labels: map[string]string{}, New: 100}})
+	event := changeEvent{}
+	assert.Nil(t, conn.ReadJSON(&event))
+	assert.Equal(t, uint64(1), event.ID)
+	assert.Equal(t, 100.0, event.Changes[0].New)
+}
+
+func TestCheckStreamOrigin(t *testing.T) {
+	defer func() { streamOrigins = nil }()
+	request := func(origin string) *http.Request {
+		r := httptest.NewRequest("GET", "http://covid19.example.org/api/stream", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+	assert.True(t, checkStreamOrigin(request("")))
+	assert.True(t, checkStreamOrigin(request("https://covid19.example.org")))
+	assert.False(t, checkStreamOrigin(request("https://evil.example.com")))
+	assert.False(t, checkStreamOrigin(request("https://dashboard.example.org")))
+
+	streamOrigins = []string{"https://dashboard.example.org/"}
+	assert.True(t, checkStreamOrigin(request("https://dashboard.example.org")))
+	assert.False(t, checkStreamOrigin(request("http://dashboard.example.org")))
+	assert.False(t, checkStreamOrigin(request("https://evil.example.com")))
+
+	streamOrigins = []string{"*"}
+	assert.True(t, checkStreamOrigin(request("https://evil.example.com")))
+}
+
+func TestHandleApiStreamWithoutScheduler(t *testing.T) {
+	defer func(previous *scheduler) { sched = previous }(sched)
+	sched = nil
+	recorder := httptest.NewRecorder()
+	handleApiStream(recorder, httptest.NewRequest("GET", "/api/stream", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//summaryTopCount is the number of districts in the rankings of the summary
+const summaryTopCount = 5
+
+//summaryFormats are the formats of the daily summary by their content type
+var summaryFormats = map[string]string{
+	"text":     "text/plain; charset=utf-8",
+	"markdown": "text/markdown; charset=utf-8",
+	"slack":    "application/json; charset=utf-8",
+}
+
+type summaryRegion struct {
+	Name  string
+	Value float64
+}
+
+//dailySummary contains the numbers that are posted to chat after an update, the changes require a history
+type dailySummary struct {
+	Date                time.Time
+	HasHistory          bool
+	Infected            uint64
+	NewCases            float64
+	Dead                uint64
+	NewDead             float64
+	IntensiveCare       uint64
+	IntensiveCareChange float64
+	Incidence           float64
+	TopIncidence        []summaryRegion
+	TopNewCases         []summaryRegion
+}
+
+var summaryTexts = map[string]map[string]string{
+	"de": {
+		"title":         "COVID-19 in Österreich",
+		"asOf":          "Stand",
+		"newCases":      "Neue Fälle",
+		"infected":      "Infizierte",
+		"dead":          "Todesfälle",
+		"intensiveCare": "Intensivstation",
+		"incidence":     "7-Tage-Inzidenz",
+		"total":         "gesamt",
+		"topIncidence":  "Höchste 7-Tage-Inzidenz",
+		"topNewCases":   "Meiste neue Fälle",
+	},
+	"en": {
+		"title":         "COVID-19 in Austria",
+		"asOf":          "as of",
+		"newCases":      "New cases",
+		"infected":      "Infected",
+		"dead":          "Deaths",
+		"intensiveCare": "Intensive care",
+		"incidence":     "7-day incidence",
+		"total":         "total",
+		"topIncidence":  "Highest 7-day incidence",
+		"topNewCases":   "Most new cases",
+	},
+}
+
+//summaryTemplateSources are shared by all languages, bold is ** in Markdown and * in Slack mrkdwn
+var summaryTemplateSources = map[string]string{
+	"text": `{{.T.title}} ({{.T.asOf}} {{date .Date}})
+{{if .HasHistory}}{{.T.newCases}}: {{signed .NewCases}} ({{.T.total}} {{count .Infected}})
+{{.T.dead}}: {{signed .NewDead}} ({{.T.total}} {{count .Dead}})
+{{.T.intensiveCare}}: {{count .IntensiveCare}} ({{signed .IntensiveCareChange}})
+{{.T.incidence}}: {{decimal .Incidence}}
+{{else}}{{.T.infected}}: {{count .Infected}}
+{{.T.dead}}: {{count .Dead}}
+{{.T.intensiveCare}}: {{count .IntensiveCare}}
+{{end}}{{if .TopIncidence}}
+{{.T.topIncidence}}:
+{{range $i, $r := .TopIncidence}}{{inc $i}}. {{$r.Name}}: {{decimal $r.Value}}
+{{end}}{{end}}{{if .TopNewCases}}
+{{.T.topNewCases}}:
+{{range $i, $r := .TopNewCases}}{{inc $i}}. {{$r.Name}}: {{signed $r.Value}}
+{{end}}{{end}}`,
+	"markdown": `{{bold .T.title}} ({{.T.asOf}} {{date .Date}})
+
+{{if .HasHistory}}- {{.T.newCases}}: {{bold (signed .NewCases)}} ({{.T.total}} {{count .Infected}})
+- {{.T.dead}}: {{bold (signed .NewDead)}} ({{.T.total}} {{count .Dead}})
+- {{.T.intensiveCare}}: {{bold (count .IntensiveCare)}} ({{signed .IntensiveCareChange}})
+- {{.T.incidence}}: {{bold (decimal .Incidence)}}
+{{else}}- {{.T.infected}}: {{bold (count .Infected)}}
+- {{.T.dead}}: {{bold (count .Dead)}}
+- {{.T.intensiveCare}}: {{bold (count .IntensiveCare)}}
+{{end}}{{if .TopIncidence}}
+{{bold .T.topIncidence}}
+{{range $i, $r := .TopIncidence}}{{inc $i}}. {{$r.Name}}: {{decimal $r.Value}}
+{{end}}{{end}}{{if .TopNewCases}}
+{{bold .T.topNewCases}}
+{{range $i, $r := .TopNewCases}}{{inc $i}}. {{$r.Name}}: {{signed $r.Value}}
+{{end}}{{end}}`,
+}
+
+//summaryTemplates are the templates by language and format, slack uses the markdown template with mrkdwn bold
+var summaryTemplates = newSummaryTemplates()
+
+func newSummaryTemplates() map[string]map[string]*template.Template {
+	result := make(map[string]map[string]*template.Template)
+	for lang := range summaryTexts {
+		result[lang] = make(map[string]*template.Template)
+		for _, format := range []string{"text", "markdown", "slack"} {
+			source := summaryTemplateSources[format]
+			bold := "**"
+			if format == "slack" {
+				source, bold = summaryTemplateSources["markdown"], "*"
+			}
+			result[lang][format] = template.Must(template.New(format).Funcs(summaryFuncs(lang, bold)).Parse(source))
+		}
+	}
+	return result
+}
+
+//formatLocalized formats a number with thousands separators and the given decimals in German or English notation
+func formatLocalized(v float64, decimals int, lang string) string {
+	text := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	integer, fraction := text, ""
+	if i := strings.Index(text, "."); i >= 0 {
+		integer, fraction = text[:i], text[i+1:]
+	}
+	thousands, decimal := ".", ","
+	if lang == "en" {
+		thousands, decimal = ",", "."
+	}
+	result := strings.Builder{}
+	if v < 0 && text != strconv.FormatFloat(0, 'f', decimals, 64) {
+		result.WriteRune('-')
+	}
+	for i, d := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			result.WriteString(thousands)
+		}
+		result.WriteRune(d)
+	}
+	if fraction != "" {
+		result.WriteString(decimal + fraction)
+	}
+	return result.String()
+}
+
+func summaryFuncs(lang string, bold string) template.FuncMap {
+	return template.FuncMap{
+		"count":   func(v uint64) string { return formatLocalized(float64(v), 0, lang) },
+		"decimal": func(v float64) string { return formatLocalized(v, 1, lang) },
+		"signed": func(v float64) string {
+			if v >= 0 {
+				return "+" + formatLocalized(v, 0, lang)
+			}
+			return formatLocalized(v, 0, lang)
+		},
+		"date": func(t time.Time) string { return t.Format("02.01.2006 15:04") },
+		"bold": func(s string) string { return bold + s + bold },
+		"inc":  func(i int) int { return i + 1 },
+	}
+}
+
+//topRegions returns the regions with the highest values
+func topRegions(values map[string]float64, count int) []summaryRegion {
+	result := make([]summaryRegion, 0, len(values))
+	for name, v := range values {
+		result = append(result, summaryRegion{name, v})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Value != result[j].Value {
+			return result[i].Value > result[j].Value
+		}
+		return result[i].Name < result[j].Name
+	})
+	if len(result) > count {
+		result = result[:count]
+	}
+	return result
+}
+
+//getSummary collects the numbers of the daily summary, the changes are calculated against the history of the day before
+func (a *api) getSummary(history *historyStore, date time.Time) (dailySummary, error) {
+	total, err := a.GetOverallStat()
+	if err != nil {
+		return dailySummary{}, err
+	}
+	result := dailySummary{Date: date, Infected: total.TotalInfected, Dead: total.TotalDead, IntensiveCare: total.TotalIntensiveCare}
+	if history == nil {
+		return result, nil
+	}
+
+	yesterday := calendarDay(date).AddDate(0, 0, -1)
+	weekAgo := calendarDay(date).AddDate(0, 0, -7)
+	previous := make(map[string]float64)
+	for _, name := range []string{"cov19_confirmed", "cov19_dead", "cov19_intensive_care"} {
+		points, err := history.byRegion(name, "", yesterday, yesterday)
+		if err != nil {
+			return result, err
+		}
+		if p, ok := points[""]; ok {
+			previous[name] = p[0].Value
+		}
+	}
+	if confirmed, ok := previous["cov19_confirmed"]; ok {
+		result.HasHistory = true
+		result.NewCases = float64(total.TotalInfected) - confirmed
+		result.NewDead = float64(total.TotalDead) - previous["cov19_dead"]
+		result.IntensiveCareChange = float64(total.TotalIntensiveCare) - previous["cov19_intensive_care"]
+	}
+	points, err := history.byRegion("cov19_confirmed", "", weekAgo, weekAgo)
+	if err != nil {
+		return result, err
+	}
+	if p, ok := points[""]; ok {
+		result.Incidence = (float64(total.TotalInfected) - p[0].Value) / float64(populationOf("Austria")) * 100000
+	}
+
+	rows, err := a.getBezirkRows(history, date)
+	if err != nil {
+		return result, err
+	}
+	districts, err := history.byRegion("cov19_bezirk_infected", "bezirk", yesterday, yesterday)
+	if err != nil {
+		return result, err
+	}
+	incidences, newCases := make(map[string]float64), make(map[string]float64)
+	for _, row := range rows {
+		if row.hasIncidence {
+			incidences[row.Name] = row.incidence
+		}
+		if p, ok := districts[normalizeName(row.Name)]; ok {
+			newCases[row.Name] = float64(row.Infected) - p[0].Value
+		}
+	}
+	result.TopIncidence = topRegions(incidences, summaryTopCount)
+	result.TopNewCases = topRegions(newCases, summaryTopCount)
+	return result, nil
+}
+
+//render formats the summary as text, markdown or a Slack/Mattermost message with blocks
+func (s dailySummary) render(format string, lang string) ([]byte, error) {
+	templates, ok := summaryTemplates[lang]
+	if !ok {
+		return nil, fmt.Errorf("Unknown language %s", lang)
+	}
+	t, ok := templates[format]
+	if !ok {
+		return nil, fmt.Errorf("Unknown format %s", format)
+	}
+	data := struct {
+		dailySummary
+		T map[string]string
+	}{s, summaryTexts[lang]}
+	buffer := bytes.Buffer{}
+	if err := t.Execute(&buffer, data); err != nil {
+		return nil, err
+	}
+	if format != "slack" {
+		return buffer.Bytes(), nil
+	}
+	//Mattermost shows text, Slack shows the blocks and uses text for notifications
+	parts := strings.SplitN(buffer.String(), "\n\n", 2)
+	blocks := []map[string]interface{}{
+		{"type": "header", "text": map[string]interface{}{"type": "plain_text", "text": fmt.Sprintf("%s (%s %s)", summaryTexts[lang]["title"], summaryTexts[lang]["asOf"], s.Date.Format("02.01.2006 15:04"))}},
+	}
+	for _, section := range strings.Split(strings.TrimSpace(parts[len(parts)-1]), "\n\n") {
+		blocks = append(blocks, map[string]interface{}{"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": section}})
+	}
+	return json.Marshal(map[string]interface{}{"text": buffer.String(), "blocks": blocks})
+}
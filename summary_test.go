@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSummary(t *testing.T) (dailySummary, func()) {
+	testApi, cleanup := newTestDashboardApi(t)
+	store, cleanupHistory := newTestHistory(t)
+	date := time.Date(2020, 11, 9, 10, 0, 0, 0, time.UTC)
+	for i := 7; i >= 1; i-- {
+		assert.Nil(t, store.record(date.AddDate(0, 0, -i), metrics{
+			{"cov19_confirmed", nil, float64(1700 - 100*i)},
+			{"cov19_dead", nil, float64(12 - i)},
+			{"cov19_intensive_care", nil, 2},
+			{"cov19_bezirk_infected", &map[string]string{"bezirk": "Graz(Stadt)", "country": "Austria"}, float64(340 - 10*i)},
+			{"cov19_bezirk_infected", &map[string]string{"bezirk": "Wien(Stadt)", "country": "Austria"}, float64(1200 - 100*i)},
+		}))
+	}
+	s, err := testApi.getSummary(store, date)
+	assert.Nil(t, err)
+	return s, func() { cleanup(); cleanupHistory() }
+}
+
+func TestSummary(t *testing.T) {
+	s, cleanup := newTestSummary(t)
+	defer cleanup()
+	assert.True(t, s.HasHistory)
+	assert.Equal(t, uint64(1700), s.Infected)
+	assert.Equal(t, 100.0, s.NewCases)
+	assert.Equal(t, 1.0, s.NewDead)
+	assert.Equal(t, -2.0, s.IntensiveCareChange)
+	assert.InDelta(t, 700/float64(populationOf("Austria"))*100000, s.Incidence, 0.0001)
+	assert.Equal(t, []summaryRegion{{"Wien(Stadt)", 700.0 / 1897491 * 100000}, {"Graz(Stadt)", 70.0 / 288806 * 100000}}, s.TopIncidence)
+	assert.Equal(t, []summaryRegion{{"Wien(Stadt)", 100}, {"Graz(Stadt)", 10}}, s.TopNewCases)
+}
+
+func TestSummaryAfterMidnight(t *testing.T) {
+	testApi, cleanup := newTestDashboardApi(t)
+	defer cleanup()
+	store, cleanupHistory := newTestHistory(t)
+	defer cleanupHistory()
+	//00:30 in Vienna is the previous day in UTC
+	date := time.Date(2020, 11, 9, 0, 30, 0, 0, viennaLocation())
+	assert.Nil(t, store.record(time.Date(2020, 11, 8, 20, 0, 0, 0, viennaLocation()), metrics{{"cov19_confirmed", nil, 1600}}))
+	assert.Nil(t, store.record(time.Date(2020, 11, 2, 20, 0, 0, 0, viennaLocation()), metrics{{"cov19_confirmed", nil, 1000}}))
+
+	s, err := testApi.getSummary(store, date)
+	assert.Nil(t, err)
+	assert.Equal(t, 100.0, s.NewCases)
+	assert.InDelta(t, 700/float64(populationOf("Austria"))*100000, s.Incidence, 0.0001)
+}
+
+func TestRenderSummary(t *testing.T) {
+	s, cleanup := newTestSummary(t)
+	defer cleanup()
+
+	text, err := s.render("text", "de")
+	assert.Nil(t, err)
+	assert.Contains(t, string(text), "COVID-19 in Österreich (Stand 09.11.2020 10:00)\nNeue Fälle: +100 (gesamt 1.700)\nTodesfälle: +1 (gesamt 12)\nIntensivstation: 0 (-2)\n")
+	assert.Contains(t, string(text), "\nHöchste 7-Tage-Inzidenz:\n1. Wien(Stadt): 36,9\n2. Graz(Stadt): 24,2\n")
+
+	markdown, err := s.render("markdown", "en")
+	assert.Nil(t, err)
+	assert.Contains(t, string(markdown), "**COVID-19 in Austria** (as of 09.11.2020 10:00)\n\n- New cases: **+100** (total 1,700)\n")
+	assert.Contains(t, string(markdown), "**Most new cases**\n1. Wien(Stadt): +100\n2. Graz(Stadt): +10\n")
+
+	slack, err := s.render("slack", "de")
+	assert.Nil(t, err)
+	message := struct {
+		Text   string `json:"text"`
+		Blocks []struct {
+			Type string `json:"type"`
+			Text struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"text"`
+		} `json:"blocks"`
+	}{}
+	assert.Nil(t, json.Unmarshal(slack, &message))
+	assert.Contains(t, message.Text, "*COVID-19 in Österreich*")
+	assert.Equal(t, 4, len(message.Blocks))
+	assert.Equal(t, "header", message.Blocks[0].Type)
+	assert.Equal(t, "COVID-19 in Österreich (Stand 09.11.2020 10:00)", message.Blocks[0].Text.Text)
+	assert.Equal(t, "mrkdwn", message.Blocks[1].Text.Type)
+	assert.Equal(t, "- Neue Fälle: *+100* (gesamt 1.700)\n- Todesfälle: *+1* (gesamt 12)\n- Intensivstation: *0* (-2)\n- 7-Tage-Inzidenz: *8,0*", message.Blocks[1].Text.Text)
+
+	_, err = s.render("html", "de")
+	assert.NotNil(t, err)
+	_, err = s.render("text", "fr")
+	assert.NotNil(t, err)
+}
+
+func TestFormatLocalized(t *testing.T) {
+	assert.Equal(t, "1.234.567", formatLocalized(1234567, 0, "de"))
+	assert.Equal(t, "1,234,567.9", formatLocalized(1234567.89, 1, "en"))
+	assert.Equal(t, "-1.234,5", formatLocalized(-1234.5, 1, "de"))
+	assert.Equal(t, "0", formatLocalized(-0.2, 0, "en"))
+}
+
+func TestHandleApiSummary(t *testing.T) {
+	testApi, cleanup := newTestDashboardApi(t)
+	defer cleanup()
+	defer func(previousApi *api, previousHistory *historyStore) { a, history = previousApi, previousHistory }(a, history)
+	a, history = testApi, nil
+
+	recorder := httptest.NewRecorder()
+	handleApiSummary(recorder, httptest.NewRequest("GET", "/api/summary?lang=en", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", recorder.Header().Get("Content-type"))
+	assert.Contains(t, recorder.Body.String(), "\nInfected: 1,700\n")
+
+	recorder = httptest.NewRecorder()
+	handleApiSummary(recorder, httptest.NewRequest("GET", "/api/summary?format=pdf", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type testingsExporter struct {
+	mp  *metadataProvider
+	url string
+}
+
+type testingsDay struct {
+	date    time.Time
+	cases   uint64
+	tests   uint64
+	pcr     uint64
+	antigen uint64
+}
+
+type testingsStat struct {
+	tests     uint64
+	pcr       uint64
+	antigen   uint64
+	newTests  uint64
+	newCases7 uint64
+	newTests7 uint64
+	//hasWeek is false if the report of 7 days ago is missing, the 7-day values are 0 then
+	hasWeek bool
+	latest  time.Time
+}
+
+func newTestingsExporter(mp *metadataProvider) *testingsExporter {
+	return &testingsExporter{mp: mp, url: "https://info.gesundheitsministerium.at/data"}
+}
+
+//positivity is the share of positive tests within the last 7 days, 0 without the report of 7 days ago
+func (t testingsStat) positivity() float64 {
+	if !t.hasWeek || t.newTests7 == 0 {
+		return 0
+	}
+	return float64(t.newCases7) / float64(t.newTests7)
+}
+
+func (t *testingsExporter) GetMetrics() (metrics, error) {
+	stats, err := t.getTestingsStat()
+	if err != nil {
+		return nil, err
+	}
+	result := make(metrics, 0)
+	for _, province := range sortedKeys(stats) {
+		s := stats[province]
+		if province == "Austria" {
+			result = append(result, metric{"cov19_tests_pcr", nil, float64(s.pcr)})
+			result = append(result, metric{"cov19_tests_antigen", nil, float64(s.antigen)})
+			result = append(result, metric{"cov19_tests_daily", nil, float64(s.newTests)})
+			if s.hasWeek {
+				result = append(result, metric{"cov19_test_positivity", nil, s.positivity()})
+			}
+			continue
+		}
+		tags := getAustrianTags(province, "province", t.mp.getMetadata(province))
+		result = append(result, metric{"cov19_tests_detail", tags, float64(s.tests)})
+		result = append(result, metric{"cov19_tests_pcr_detail", tags, float64(s.pcr)})
+		result = append(result, metric{"cov19_tests_antigen_detail", tags, float64(s.antigen)})
+		result = append(result, metric{"cov19_tests_daily_detail", tags, float64(s.newTests)})
+		if s.hasWeek {
+			result = append(result, metric{"cov19_test_positivity_detail", tags, s.positivity()})
+		}
+	}
+	return result, nil
+}
+
+func (t *testingsExporter) Health() []error {
+	errors := make([]error, 0)
+	stats, err := t.getTestingsStat()
+	if err != nil {
+		return append(errors, err)
+	}
+	if len(stats) != 10 {
+		errors = append(errors, fmt.Errorf("Missing testing results for provinces: %d", len(stats)))
+	}
+	for _, province := range sortedKeys(stats) {
+		if s := stats[province]; !s.hasWeek {
+			errors = append(errors, fmt.Errorf("No 7-day positivity for %s, the report of %s is missing", province, calendarDay(s.latest).AddDate(0, 0, -7).Format("2006-01-02")))
+		}
+	}
+	return errors
+}
+
+//getTestingsStat returns the test counts per province, "Austria" holds the national numbers
+func (t *testingsExporter) getTestingsStat() (map[string]testingsStat, error) {
+	records, err := readCsvFromGet(t.url+"/timeline-faelle-bundeslaender.csv", ';')
+	if err != nil {
+		return nil, err
+	}
+	c, err := csvColumns(records[0], "Datum", "BundeslandID", "Name", "BestaetigteFaelleBundeslaender", "Testungen", "TestungenPCR", "TestungenAntigen")
+	if err != nil {
+		return nil, err
+	}
+
+	days := make(map[string][]testingsDay)
+	for _, row := range records[1:] {
+		date, err := time.Parse(time.RFC3339, row[c["Datum"]])
+		if err != nil {
+			return nil, err
+		}
+		province := row[c["Name"]]
+		if row[c["BundeslandID"]] == "10" {
+			province = "Austria"
+		}
+		days[province] = append(days[province], testingsDay{
+			date:    date,
+			cases:   atoi(row[c["BestaetigteFaelleBundeslaender"]]),
+			tests:   atoi(row[c["Testungen"]]),
+			pcr:     atoi(row[c["TestungenPCR"]]),
+			antigen: atoi(row[c["TestungenAntigen"]]),
+		})
+	}
+
+	result := make(map[string]testingsStat, len(days))
+	for province, d := range days {
+		sort.Slice(d, func(i, j int) bool { return d[i].date.Before(d[j].date) })
+		latest := d[len(d)-1]
+		s := testingsStat{tests: latest.tests, pcr: latest.pcr, antigen: latest.antigen, latest: latest.date}
+		if len(d) > 1 {
+			s.newTests = delta(latest.tests, d[len(d)-2].tests)
+		}
+		weekAgo := calendarDay(latest.date).AddDate(0, 0, -7)
+		for _, day := range d {
+			if calendarDay(day.date).Equal(weekAgo) {
+				s.newCases7 = delta(latest.cases, day.cases)
+				s.newTests7 = delta(latest.tests, day.tests)
+				s.hasWeek = true
+			}
+		}
+		result[province] = s
+	}
+	return result, nil
+}
+
+func delta(current uint64, previous uint64) uint64 {
+	if current < previous {
+		return 0
+	}
+	return current - previous
+}
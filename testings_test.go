@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testingsCsv() string {
+	rows := []string{"Datum;BundeslandID;Name;BestaetigteFaelleBundeslaender;Todesfaelle;Genesen;Hospitalisierung;Intensivstation;Testungen;TestungenPCR;TestungenAntigen"}
+	for day := 1; day <= 9; day++ {
+		date := fmt.Sprintf("2020-11-%02dT09:30:00+01:00", day)
+		rows = append(rows, fmt.Sprintf("%s;9;Wien;%d;0;0;0;0;%d;%d;%d", date, 1000+day*100, 10000+day*1000, 8000+day*800, 2000+day*200))
+		rows = append(rows, fmt.Sprintf("%s;10;Österreich;%d;0;0;0;0;%d;%d;%d", date, 5000+day*500, 40000+day*5000, 30000+day*4000, 10000+day*1000))
+	}
+	return strings.Join(rows, "\n") + "\n"
+}
+
+func TestTestingsStat(t *testing.T) {
+	te := newTestingsExporter(e.mp)
+	serveTestContent(t, &te.url, testingsCsv())
+
+	result, err := te.getTestingsStat()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(result))
+
+	vienna := result["Wien"]
+	assert.Equal(t, uint64(19000), vienna.tests)
+	assert.Equal(t, uint64(15200), vienna.pcr)
+	assert.Equal(t, uint64(3800), vienna.antigen)
+	assert.Equal(t, uint64(1000), vienna.newTests)
+	assert.Equal(t, uint64(700), vienna.newCases7)
+	assert.Equal(t, uint64(7000), vienna.newTests7)
+	assert.InDelta(t, 0.1, vienna.positivity(), 0.0001)
+}
+
+func TestTestingsMetrics(t *testing.T) {
+	te := newTestingsExporter(e.mp)
+	serveTestContent(t, &te.url, testingsCsv())
+
+	result, err := te.GetMetrics()
+	assert.Nil(t, err)
+	assert.Equal(t, "cov19_tests_pcr", result[0].Name)
+	assert.Equal(t, "Wien", (*result[4].Tags)["province"])
+
+	positivity := assertMetric(t, result, "cov19_test_positivity_detail", "province=Wien", 0.1)
+	assert.Equal(t, "Austria", (*positivity.Tags)["country"])
+	assertMetric(t, result, "cov19_test_positivity", "", 0.1)
+	assertMetric(t, result, "cov19_tests_antigen", "", 19000)
+}
+
+func TestTestingsPositivityWithoutTests(t *testing.T) {
+	assert.Equal(t, float64(0), testingsStat{newCases7: 10}.positivity())
+	assert.Equal(t, uint64(0), delta(5, 10))
+}
+
+func TestTestingsMissingWeek(t *testing.T) {
+	rows := strings.Split(testingsCsv(), "\n")
+	csv := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if !strings.HasPrefix(row, "2020-11-02T09:30:00+01:00;9;") {
+			csv = append(csv, row)
+		}
+	}
+	te := newTestingsExporter(e.mp)
+	serveTestContent(t, &te.url, strings.Join(csv, "\n"))
+
+	stats, err := te.getTestingsStat()
+	assert.Nil(t, err)
+	assert.False(t, stats["Wien"].hasWeek)
+	assert.Equal(t, float64(0), stats["Wien"].positivity())
+	assert.True(t, stats["Austria"].hasWeek)
+
+	result, err := te.GetMetrics()
+	assert.Nil(t, err)
+	assert.Nil(t, result.findMetric("cov19_test_positivity_detail", "province=Wien"))
+	assert.NotNil(t, result.findMetric("cov19_tests_detail", "province=Wien"))
+	assert.NotNil(t, result.findMetric("cov19_test_positivity", ""))
+	assert.Contains(t, te.Health(), errors.New("No 7-day positivity for Wien, the report of 2020-11-02 is missing"))
+}
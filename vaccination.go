@@ -0,0 +1,158 @@
+package main
+
+import "fmt"
+
+type vaccinationExporter struct {
+	mp  *metadataProvider
+	url string
+}
+
+type vaccinationStat struct {
+	firstDose   uint64
+	secondDose  uint64
+	boosterDose uint64
+	population  uint64
+}
+
+type provinceVaccinationStat struct {
+	vaccinationStat
+	ageGroups map[string]*vaccinationStat
+}
+
+func newVaccinationExporter(mp *metadataProvider) *vaccinationExporter {
+	return &vaccinationExporter{mp: mp, url: "https://info.gesundheitsministerium.at/data"}
+}
+
+func (v vaccinationStat) doses() uint64 {
+	return v.firstDose + v.secondDose + v.boosterDose
+}
+
+func (v *vaccinationStat) add(o vaccinationStat) {
+	v.firstDose += o.firstDose
+	v.secondDose += o.secondDose
+	v.boosterDose += o.boosterDose
+	v.population += o.population
+}
+
+func (v *vaccinationExporter) GetMetrics() (metrics, error) {
+	stats, err := v.getVaccinationStat()
+	if err != nil {
+		return nil, err
+	}
+	result := make(metrics, 0)
+	for _, province := range sortedKeys(stats) {
+		s := stats[province]
+		var tags *map[string]string
+		if province == "Austria" {
+			tags = &map[string]string{"country": "Austria"}
+		} else {
+			tags = getAustrianTags(province, "province", v.mp.getMetadata(province))
+		}
+		result = append(result, vaccinationMetrics("cov19_vaccination", tags, s.vaccinationStat)...)
+		for _, group := range sortedKeys(s.ageGroups) {
+			a := s.ageGroups[group]
+			groupTags := map[string]string{"group": group}
+			for k, t := range *tags {
+				groupTags[k] = t
+			}
+			result = append(result, vaccinationMetrics("cov19_vaccination_age", &groupTags, *a)...)
+		}
+	}
+	return result, nil
+}
+
+func vaccinationMetrics(prefix string, tags *map[string]string, s vaccinationStat) metrics {
+	result := metrics{
+		{prefix + "_doses", tags, float64(s.doses())},
+		{prefix + "_first_dose", tags, float64(s.firstDose)},
+		{prefix + "_second_dose", tags, float64(s.secondDose)},
+		{prefix + "_booster_dose", tags, float64(s.boosterDose)},
+	}
+	if s.population > 0 {
+		result = append(result,
+			metric{prefix + "_first_dose_percent", tags, percent(s.firstDose, s.population)},
+			metric{prefix + "_second_dose_percent", tags, percent(s.secondDose, s.population)},
+			metric{prefix + "_booster_dose_percent", tags, percent(s.boosterDose, s.population)},
+		)
+	}
+	return result
+}
+
+func (v *vaccinationExporter) Health() []error {
+	errors := make([]error, 0)
+	stats, err := v.getVaccinationStat()
+	if err != nil {
+		return append(errors, err)
+	}
+	if len(stats) != 10 {
+		errors = append(errors, fmt.Errorf("Missing vaccination results for provinces: %d", len(stats)))
+	}
+	for province, s := range stats {
+		if s.population == 0 {
+			errors = append(errors, fmt.Errorf("Could not find population for province: %s", province))
+		}
+	}
+	return errors
+}
+
+//getVaccinationStat returns the vaccinations of the latest day per province, "Austria" holds the national numbers
+func (v *vaccinationExporter) getVaccinationStat() (map[string]*provinceVaccinationStat, error) {
+	records, err := readCsvFromGet(v.url+"/COVID19_vaccination_doses_agegroups.csv", ';')
+	if err != nil {
+		return nil, err
+	}
+	c, err := csvColumns(records[0], "date", "state_id", "state_name", "age_group", "dose_1", "dose_2", "dose_3")
+	if err != nil {
+		return nil, err
+	}
+	population, hasPopulation := c["population"]
+
+	latest := ""
+	for _, row := range records[1:] {
+		if row[c["date"]] > latest {
+			latest = row[c["date"]]
+		}
+	}
+
+	result := make(map[string]*provinceVaccinationStat)
+	for _, row := range records[1:] {
+		if row[c["date"]] != latest || row[c["state_id"]] == "0" {
+			continue
+		}
+		province := row[c["state_name"]]
+		if row[c["state_id"]] == "10" {
+			province = "Austria"
+		}
+		s := vaccinationStat{firstDose: atoi(row[c["dose_1"]]), secondDose: atoi(row[c["dose_2"]]), boosterDose: atoi(row[c["dose_3"]])}
+		if hasPopulation {
+			s.population = atoi(row[population])
+		}
+		p, ok := result[province]
+		if !ok {
+			p = &provinceVaccinationStat{ageGroups: make(map[string]*vaccinationStat)}
+			result[province] = p
+		}
+		group := row[c["age_group"]]
+		if _, ok := p.ageGroups[group]; !ok {
+			p.ageGroups[group] = &vaccinationStat{}
+		}
+		p.ageGroups[group].add(s)
+		s.population = 0
+		p.add(s)
+	}
+
+	for province, p := range result {
+		if province == "Austria" {
+			continue
+		}
+		p.population = v.mp.getPopulation(province)
+	}
+	if austria, ok := result["Austria"]; ok {
+		for province, p := range result {
+			if province != "Austria" {
+				austria.population += p.population
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const vaccinationCsv = "\ufeffdate;state_id;state_name;age_group;gender;population;dose_1;dose_2;dose_3\n" +
+	"2021-12-01;9;Wien;15-24;Female;100000;70000;60000;10000\n" +
+	"2021-12-01;9;Wien;15-24;Male;100000;60000;50000;5000\n" +
+	"2021-12-01;9;Wien;25-34;Female;200000;150000;140000;30000\n" +
+	"2021-12-02;0;KeineZuordnung;15-24;Female;0;10;10;10\n" +
+	"2021-12-02;9;Wien;15-24;Female;100000;71000;61000;11000\n" +
+	"2021-12-02;9;Wien;15-24;Male;100000;61000;51000;6000\n" +
+	"2021-12-02;5;Salzburg;15-24;Female;50000;30000;20000;1000\n" +
+	"2021-12-02;5;Salzburg;05-14;Female;40000;10000;5000;100\n" +
+	"2021-12-02;10;Österreich;15-24;Female;150000;101000;81000;12000\n" +
+	"2021-12-02;10;Österreich;15-24;Male;100000;61000;51000;6000\n"
+
+func TestVaccinationStat(t *testing.T) {
+	ve := newVaccinationExporter(e.mp)
+	serveTestContent(t, &ve.url, vaccinationCsv)
+
+	result, err := ve.getVaccinationStat()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(result))
+
+	vienna := result["Wien"]
+	assert.NotNil(t, vienna)
+	assert.Equal(t, uint64(132000), vienna.firstDose)
+	assert.Equal(t, uint64(112000), vienna.secondDose)
+	assert.Equal(t, uint64(17000), vienna.boosterDose)
+	assert.Equal(t, uint64(261000), vienna.doses())
+	assert.Equal(t, uint64(1889100), vienna.population)
+	assert.Equal(t, 1, len(vienna.ageGroups))
+	assert.Equal(t, uint64(200000), vienna.ageGroups["15-24"].population)
+
+	austria := result["Austria"]
+	assert.NotNil(t, austria)
+	assert.Equal(t, uint64(162000), austria.firstDose)
+	assert.Equal(t, uint64(1889100+552600), austria.population)
+}
+
+func TestVaccinationMetrics(t *testing.T) {
+	ve := newVaccinationExporter(e.mp)
+	serveTestContent(t, &ve.url, vaccinationCsv)
+
+	result, err := ve.GetMetrics()
+	assert.Nil(t, err)
+
+	doses := assertMetric(t, result, "cov19_vaccination_doses", "province=Wien", 261000)
+	assert.Equal(t, "48.206351", (*doses.Tags)["latitude"])
+
+	coverage := assertMetric(t, result, "cov19_vaccination_age_first_dose_percent", "province=Wien", 66.0)
+	assert.Equal(t, "15-24", (*coverage.Tags)["group"])
+
+	assert.NotNil(t, result.findMetric("cov19_vaccination_booster_dose_percent", "country=Austria"))
+
+	groups := make([]string, 0)
+	for _, m := range result {
+		if m.Name == "cov19_vaccination_age_doses" && (*m.Tags)["province"] == "Salzburg" {
+			groups = append(groups, (*m.Tags)["group"])
+		}
+	}
+	assert.Equal(t, []string{"05-14", "15-24"}, groups)
+}
+
+func TestVaccinationHealth(t *testing.T) {
+	ve := newVaccinationExporter(e.mp)
+	serveTestContent(t, &ve.url, vaccinationCsv)
+
+	assert.Equal(t, 1, len(ve.Health()))
+
+	ve.url += "/missing"
+	assert.Equal(t, 1, len(ve.Health()))
+}
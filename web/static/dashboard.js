@@ -0,0 +1,30 @@
+// Sorts the tables with class "sortable" by the clicked column. Cells are compared by their data-value attribute
+// if present, columns with data-type="text" are compared as text and data-type="none" is not sortable.
+document.querySelectorAll("table.sortable").forEach(function (table) {
+    var headers = table.querySelectorAll("th");
+    headers.forEach(function (th, column) {
+        if (th.dataset.type === "none") {
+            return;
+        }
+        th.addEventListener("click", function () {
+            var ascending = !th.classList.contains("asc");
+            headers.forEach(function (h) {
+                h.classList.remove("asc", "desc");
+            });
+            th.classList.add(ascending ? "asc" : "desc");
+
+            var tbody = table.querySelector("tbody");
+            var rows = Array.prototype.slice.call(tbody.rows);
+            rows.sort(function (a, b) {
+                var x = a.cells[column], y = b.cells[column];
+                var result = th.dataset.type === "text"
+                    ? x.textContent.trim().localeCompare(y.textContent.trim(), "de")
+                    : parseFloat(x.dataset.value) - parseFloat(y.dataset.value);
+                return ascending ? result : -result;
+            });
+            rows.forEach(function (row) {
+                tbody.appendChild(row);
+            });
+        });
+    });
+});
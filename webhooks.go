@@ -0,0 +1,486 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+//webhookEvents are the event types a webhook can subscribe to
+var webhookEvents = []string{"daily", "summary", "threshold", "unhealthy", "healthy"}
+
+//webhookConfig describes a receiver of notifications
+type webhookConfig struct {
+	URL        string        `yaml:"url"`
+	Secret     string        `yaml:"secret"`
+	Events     []string      `yaml:"events"`
+	Thresholds []float64     `yaml:"thresholds"`
+	Bezirke    []string      `yaml:"bezirke"`
+	Payload    string        `yaml:"payload"`
+	Format     string        `yaml:"summary_format"`
+	Lang       string        `yaml:"lang"`
+	Retries    *int          `yaml:"retries"`
+	Backoff    time.Duration `yaml:"backoff"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+type webhooksConfig struct {
+	DeadLetter string          `yaml:"dead_letter"`
+	Webhooks   []webhookConfig `yaml:"webhooks"`
+}
+
+//webhookEvent is the default payload, configured payload templates are executed with it
+type webhookEvent struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Summary   string    `json:"summary"`
+	Region    string    `json:"region,omitempty"`
+	Value     float64   `json:"value"`
+	Previous  *float64  `json:"previous,omitempty"`
+	Threshold float64   `json:"threshold,omitempty"`
+	Direction string    `json:"direction,omitempty"`
+	Exporter  string    `json:"exporter,omitempty"`
+	Errors    []string  `json:"errors,omitempty"`
+
+	//raw is sent instead of the event, e.g. a rendered Slack summary
+	raw []byte
+}
+
+//deadLetter is a line of the dead letter log, written if all attempts of a delivery failed
+type deadLetter struct {
+	Time     time.Time       `json:"time"`
+	URL      string          `json:"url"`
+	Event    string          `json:"event"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+type webhook struct {
+	config  webhookConfig
+	payload *template.Template
+}
+
+//webhookNotifier detects events after every refresh and delivers them to the configured webhooks
+type webhookNotifier struct {
+	webhooks   []*webhook
+	deadLetter string
+	client     *http.Client
+	//incidences returns the 7-day incidence by district
+	incidences func(date time.Time) (map[string]float64, error)
+	//summary returns the daily summary, it is sent with the first daily event of a day
+	summary func(date time.Time) (dailySummary, error)
+
+	lastDaily       string
+	lastIncidences  map[string]float64
+	unhealthy       map[string]bool
+	deliveries      sync.WaitGroup
+	deadLetterMutex sync.Mutex
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		content, err := json.Marshal(v)
+		return string(content), err
+	},
+}
+
+//loadWebhooksConfig reads the webhooks from a yaml file
+func loadWebhooksConfig(filename string) (webhooksConfig, error) {
+	config := webhooksConfig{}
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return config, err
+	}
+	err = yaml.UnmarshalStrict(content, &config)
+	return config, err
+}
+
+func newWebhookNotifier(config webhooksConfig, incidences func(date time.Time) (map[string]float64, error), summary func(date time.Time) (dailySummary, error)) (*webhookNotifier, error) {
+	n := &webhookNotifier{
+		deadLetter: config.DeadLetter,
+		client:     &http.Client{},
+		incidences: incidences,
+		summary:    summary,
+		unhealthy:  make(map[string]bool),
+	}
+	for _, c := range config.Webhooks {
+		if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+			return nil, fmt.Errorf("Webhook url %s must be http or https", c.URL)
+		}
+		if len(c.Events) == 0 {
+			c.Events = []string{"daily", "unhealthy", "healthy"}
+			if len(c.Thresholds) > 0 {
+				c.Events = append(c.Events, "threshold")
+			}
+		}
+		for _, e := range c.Events {
+			if !contains(webhookEvents, e) {
+				return nil, fmt.Errorf("Unknown webhook event %s, expected one of %s", e, strings.Join(webhookEvents, ", "))
+			}
+		}
+		if contains(c.Events, "threshold") && len(c.Thresholds) == 0 {
+			return nil, fmt.Errorf("Webhook %s requires thresholds for threshold events", c.URL)
+		}
+		if c.Format == "" {
+			c.Format = "slack"
+		}
+		if c.Lang == "" {
+			c.Lang = "de"
+		}
+		if _, ok := summaryFormats[c.Format]; !ok {
+			return nil, fmt.Errorf("Unknown summary format %s", c.Format)
+		}
+		if _, ok := summaryTexts[c.Lang]; !ok {
+			return nil, fmt.Errorf("Unknown summary language %s", c.Lang)
+		}
+		if c.Retries == nil {
+			retries := 3
+			c.Retries = &retries
+		}
+		if c.Backoff == 0 {
+			c.Backoff = 10 * time.Second
+		}
+		if c.Timeout == 0 {
+			c.Timeout = 10 * time.Second
+		}
+		w := &webhook{config: c}
+		if c.Payload != "" {
+			t, err := template.New(c.URL).Funcs(webhookTemplateFuncs).Parse(c.Payload)
+			if err != nil {
+				return nil, err
+			}
+			w.payload = t
+		}
+		n.webhooks = append(n.webhooks, w)
+	}
+	return n, nil
+}
+
+//exporterName is the name of an exporter in health events
+func exporterName(e Exporter) string {
+	if d, ok := e.(*declarativeExporter); ok {
+		return d.config.Name
+	}
+	t := reflect.TypeOf(e)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.TrimSuffix(t.Name(), "Exporter")
+}
+
+//check detects the events of a refresh and delivers them in the background
+func (n *webhookNotifier) check(date time.Time, results []exporterResult, previous metrics, current metrics) {
+	daily := n.dailyEvents(date, previous, current)
+	events := append(daily, n.healthEvents(date, results)...)
+	for _, w := range n.webhooks {
+		for _, e := range events {
+			if contains(w.config.Events, e.Type) {
+				n.send(w, e)
+			}
+		}
+	}
+	if len(daily) > 0 {
+		n.sendSummary(date)
+	}
+	n.checkThresholds(date)
+}
+
+//sendSummary renders the daily summary in the format and language of every webhook that subscribed to it
+func (n *webhookNotifier) sendSummary(date time.Time) {
+	var summary *dailySummary
+	for _, w := range n.webhooks {
+		if !contains(w.config.Events, "summary") || n.summary == nil {
+			continue
+		}
+		if summary == nil {
+			s, err := n.summary(date)
+			if err != nil {
+				logger.Print(err)
+				return
+			}
+			summary = &s
+		}
+		body, err := summary.render(w.config.Format, w.config.Lang)
+		if err != nil {
+			logger.Print(err)
+			continue
+		}
+		event := webhookEvent{Type: "summary", Time: date, Summary: string(body)}
+		if w.config.Format == "slack" && w.payload == nil {
+			event.raw = body
+		}
+		n.send(w, event)
+	}
+}
+
+//dailyEvents returns an event on the first change of the confirmed cases of a day
+func (n *webhookNotifier) dailyEvents(date time.Time, previous metrics, current metrics) []webhookEvent {
+	//the figures belong to the day in Vienna, e.g. a refresh at 23:30 UTC is already on the next day
+	day := calendarDay(date).Format(snapshotLayout)
+	if previous == nil || n.lastDaily == day {
+		return nil
+	}
+	for _, c := range diffMetrics(previous, current) {
+		if c.Metric != "cov19_confirmed" || len(c.Labels) > 0 {
+			continue
+		}
+		n.lastDaily = day
+		summary := fmt.Sprintf("New figures for %s: %s infected", calendarDay(date).Format("02.01.2006"), formatCount(uint64(c.New)))
+		if c.Old != nil && c.New >= *c.Old {
+			summary += fmt.Sprintf(" (+%s)", formatCount(uint64(c.New-*c.Old)))
+		} else if c.Old != nil {
+			summary += fmt.Sprintf(" (-%s)", formatCount(uint64(*c.Old-c.New)))
+		}
+		return []webhookEvent{{Type: "daily", Time: date, Summary: summary, Region: "Austria", Value: c.New, Previous: c.Old}}
+	}
+	return nil
+}
+
+//subscribed returns whether a webhook receives one of the event types
+func (n *webhookNotifier) subscribed(types ...string) bool {
+	for _, w := range n.webhooks {
+		for _, t := range types {
+			if contains(w.config.Events, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//healthEvents returns an event if an exporter turns unhealthy or healthy again. GetMetrics of the refresh only fails
+//for some exporters (e.g. the health ministry exporter skips missing files), so the others are checked with Health.
+func (n *webhookNotifier) healthEvents(date time.Time, results []exporterResult) []webhookEvent {
+	result := make([]webhookEvent, 0)
+	if !n.subscribed("unhealthy", "healthy") {
+		return result
+	}
+	for _, r := range results {
+		name := exporterName(r.exporter)
+		errs := []error{r.err}
+		if r.err == nil {
+			errs = r.exporter.Health()
+		}
+		switch {
+		case len(errs) > 0 && !n.unhealthy[name]:
+			n.unhealthy[name] = true
+			messages := make([]string, 0, len(errs))
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+			result = append(result, webhookEvent{Type: "unhealthy", Time: date, Summary: fmt.Sprintf("Exporter %s is unhealthy: %s", name, messages[0]), Exporter: name, Errors: messages})
+		case len(errs) == 0 && n.unhealthy[name]:
+			delete(n.unhealthy, name)
+			result = append(result, webhookEvent{Type: "healthy", Time: date, Summary: fmt.Sprintf("Exporter %s is healthy again", name), Exporter: name})
+		}
+	}
+	return result
+}
+
+//checkThresholds sends an event for every district whose incidence crossed a threshold of a webhook since the last check
+func (n *webhookNotifier) checkThresholds(date time.Time) {
+	if n.incidences == nil {
+		return
+	}
+	current, err := n.incidences(date)
+	if err != nil {
+		logger.Print(err)
+		return
+	}
+	previous := n.lastIncidences
+	n.lastIncidences = current
+	if previous == nil {
+		return
+	}
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, w := range n.webhooks {
+		if !contains(w.config.Events, "threshold") {
+			continue
+		}
+		for _, name := range names {
+			before, ok := previous[name]
+			if !ok || (len(w.config.Bezirke) > 0 && !containsNormalized(w.config.Bezirke, name)) {
+				continue
+			}
+			value := current[name]
+			for _, t := range w.config.Thresholds {
+				direction := ""
+				if before < t && value >= t {
+					direction = "up"
+				} else if before >= t && value < t {
+					direction = "down"
+				}
+				if direction == "" {
+					continue
+				}
+				previous := before
+				verb := map[string]string{"up": "above", "down": "below"}[direction]
+				n.send(w, webhookEvent{
+					Type:      "threshold",
+					Time:      date,
+					Summary:   fmt.Sprintf("7-day incidence of %s is %s %s: %s", name, verb, formatDecimal(t), formatDecimal(value)),
+					Region:    name,
+					Value:     value,
+					Previous:  &previous,
+					Threshold: t,
+					Direction: direction,
+				})
+			}
+		}
+	}
+}
+
+func containsNormalized(values []string, value string) bool {
+	for _, v := range values {
+		if normalizeName(v) == normalizeName(value) {
+			return true
+		}
+	}
+	return false
+}
+
+//render executes the configured template or the event as json
+func (w *webhook) render(event webhookEvent) ([]byte, error) {
+	if event.raw != nil {
+		return event.raw, nil
+	}
+	if w.payload == nil {
+		return json.Marshal(event)
+	}
+	buffer := bytes.Buffer{}
+	if err := w.payload.Execute(&buffer, event); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buffer.Bytes()) {
+		return buffer.Bytes(), errors.New("Payload template did not render valid json")
+	}
+	return buffer.Bytes(), nil
+}
+
+//webhookSignature is the hex encoded HMAC-SHA256 of the body
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (n *webhookNotifier) send(w *webhook, event webhookEvent) {
+	n.deliveries.Add(1)
+	go func() {
+		defer n.deliveries.Done()
+		body, err := w.render(event)
+		attempts := 0
+		if err == nil {
+			attempts, err = n.deliver(w, event, body)
+		}
+		if err != nil {
+			n.writeDeadLetter(deadLetter{Time: time.Now(), URL: w.config.URL, Event: event.Type, Attempts: attempts, Error: err.Error(), Payload: validJSON(body)})
+		}
+	}()
+}
+
+//deliver posts the body and retries with exponential backoff, it returns the number of attempts
+func (n *webhookNotifier) deliver(w *webhook, event webhookEvent, body []byte) (int, error) {
+	var err error
+	backoff := w.config.Backoff
+	for attempt := 1; ; attempt++ {
+		if err = n.post(w, event, body); err == nil {
+			return attempt, nil
+		}
+		if attempt > *w.config.Retries {
+			return attempt, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (n *webhookNotifier) post(w *webhook, event webhookEvent, body []byte) error {
+	request, err := http.NewRequest(http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "covid19-at")
+	request.Header.Set("X-Covid19-Event", event.Type)
+	if w.config.Secret != "" {
+		request.Header.Set("X-Covid19-Signature", webhookSignature(w.config.Secret, body))
+	}
+	client := *n.client
+	client.Timeout = w.config.Timeout
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	ioutil.ReadAll(response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s responded with %s", w.config.URL, response.Status)
+	}
+	return nil
+}
+
+func validJSON(body []byte) json.RawMessage {
+	if !json.Valid(body) {
+		return nil
+	}
+	return body
+}
+
+//writeDeadLetter appends a failed delivery to the dead letter log, the log is only written if configured
+func (n *webhookNotifier) writeDeadLetter(d deadLetter) {
+	logger.Printf("Webhook %s failed after %d attempts: %s", d.URL, d.Attempts, d.Error)
+	if n.deadLetter == "" {
+		return
+	}
+	n.deadLetterMutex.Lock()
+	defer n.deadLetterMutex.Unlock()
+	line, _ := json.Marshal(d)
+	file, err := os.OpenFile(n.deadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.Print(err)
+		return
+	}
+	defer file.Close()
+	file.Write(append(line, '\n'))
+}
+
+//wait blocks until all pending deliveries are done
+func (n *webhookNotifier) wait() {
+	n.deliveries.Wait()
+}
+
+//getBezirkIncidences returns the 7-day incidence of every district with history
+func (a *api) getBezirkIncidences(history *historyStore, date time.Time) (map[string]float64, error) {
+	rows, err := a.getBezirkRows(history, date)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]float64)
+	for _, row := range rows {
+		if row.hasIncidence {
+			result[row.Name] = row.incidence
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type webhookRequest struct {
+	header http.Header
+	body   string
+}
+
+//webhookReceiver records the requests and fails the first failures requests
+type webhookReceiver struct {
+	mu       sync.Mutex
+	failures int
+	requests []webhookRequest
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, webhookRequest{req.Header, string(body)})
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+//unhealthyExporter fails with its first error unless partial, Health counts the calls
+type unhealthyExporter struct {
+	staticExporter
+	errors       []error
+	partial      bool
+	healthChecks int
+}
+
+func (e *unhealthyExporter) GetMetrics() (metrics, error) {
+	if len(e.errors) > 0 && !e.partial {
+		return nil, e.errors[0]
+	}
+	return e.metrics, nil
+}
+
+func (e *unhealthyExporter) Health() []error {
+	e.healthChecks++
+	return e.errors
+}
+
+func TestWebhooksConfig(t *testing.T) {
+	config, err := loadWebhooksConfig("config/webhooks.yml")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(config.Webhooks))
+	assert.Equal(t, 30*time.Second, config.Webhooks[0].Backoff)
+	n, err := newWebhookNotifier(config, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, *n.webhooks[1].config.Retries)
+	assert.Equal(t, []string{"daily"}, n.webhooks[1].config.Events)
+	n, err = newWebhookNotifier(webhooksConfig{Webhooks: []webhookConfig{{URL: "http://example.org"}}}, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"daily", "unhealthy", "healthy"}, n.webhooks[0].config.Events)
+
+	for _, c := range []webhookConfig{
+		{URL: "ftp://example.org"},
+		{URL: "http://example.org", Events: []string{"hourly"}},
+		{URL: "http://example.org", Events: []string{"threshold"}},
+		{URL: "http://example.org", Events: []string{"daily"}, Payload: "{{.Missing"},
+	} {
+		_, err := newWebhookNotifier(webhooksConfig{Webhooks: []webhookConfig{c}}, nil, nil)
+		assert.NotNil(t, err, c.URL)
+	}
+}
+
+func TestWebhookEvents(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	incidences := map[string]float64{"Graz(Stadt)": 45, "Wien(Stadt)": 120}
+	n, err := newWebhookNotifier(webhooksConfig{Webhooks: []webhookConfig{
+		{URL: server.URL, Secret: "secret", Thresholds: []float64{50, 100}, Bezirke: []string{"graz (stadt)"}},
+	}}, func(time.Time) (map[string]float64, error) { return incidences, nil }, nil)
+	assert.Nil(t, err)
+
+	exporter := &unhealthyExporter{}
+	date := time.Date(2020, 11, 9, 9, 0, 0, 0, time.UTC)
+	previous := metrics{{"cov19_confirmed", nil, 1600}}
+	n.check(date, runExporters([]Exporter{exporter}), nil, previous)
+	n.wait()
+	assert.Empty(t, receiver.requests)
+
+	incidences = map[string]float64{"Graz(Stadt)": 55, "Wien(Stadt)": 90}
+	exporter.errors = []error{errors.New("Bezirke.js: 404 Not Found")}
+	current := metrics{{"cov19_confirmed", nil, 1700}, {"cov19_detail", &map[string]string{"province": "Wien"}, 10}}
+	n.check(date.Add(time.Hour), runExporters([]Exporter{exporter}), previous, current)
+	n.check(date.Add(2*time.Hour), runExporters([]Exporter{exporter}), current, metrics{{"cov19_confirmed", nil, 1750}})
+	n.wait()
+
+	events := make(map[string]webhookEvent)
+	for _, r := range receiver.requests {
+		assert.Equal(t, "application/json", r.header.Get("Content-type"))
+		assert.Equal(t, webhookSignature("secret", []byte(r.body)), r.header.Get("X-Covid19-Signature"))
+		e := webhookEvent{}
+		assert.Nil(t, json.Unmarshal([]byte(r.body), &e))
+		assert.Equal(t, e.Type, r.header.Get("X-Covid19-Event"))
+		events[e.Type] = e
+	}
+	assert.Equal(t, 3, len(receiver.requests))
+	assert.Equal(t, "New figures for 09.11.2020: 1.700 infected (+100)", events["daily"].Summary)
+	assert.Equal(t, 1600.0, *events["daily"].Previous)
+	assert.Equal(t, "threshold", events["threshold"].Type)
+	assert.Equal(t, "Graz(Stadt)", events["threshold"].Region)
+	assert.Equal(t, 50.0, events["threshold"].Threshold)
+	assert.Equal(t, "up", events["threshold"].Direction)
+	assert.Equal(t, "7-day incidence of Graz(Stadt) is above 50,0: 55,0", events["threshold"].Summary)
+	assert.Equal(t, "unhealthy", events["unhealthy"].Exporter)
+	assert.Equal(t, []string{"Bezirke.js: 404 Not Found"}, events["unhealthy"].Errors)
+
+	exporter.errors = nil
+	n.check(date.Add(3*time.Hour), runExporters([]Exporter{exporter}), current, current)
+	n.wait()
+	assert.Equal(t, 4, len(receiver.requests))
+	assert.Contains(t, receiver.requests[3].body, `"summary":"Exporter unhealthy is healthy again"`)
+	assert.Equal(t, 2, exporter.healthChecks)
+}
+
+func TestWebhookHealthEvents(t *testing.T) {
+	n, err := newWebhookNotifier(webhooksConfig{Webhooks: []webhookConfig{{URL: "http://example.org"}}}, nil, nil)
+	assert.Nil(t, err)
+	date := time.Date(2020, 11, 9, 9, 0, 0, 0, time.UTC)
+
+	exporter := &unhealthyExporter{partial: true, errors: []error{errors.New("Genesen.js: 404 Not Found"), errors.New("Missing Bundesland result 24")}}
+	results := runExporters([]Exporter{exporter})
+	assert.Nil(t, results[0].err)
+	events := n.healthEvents(date, results)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "unhealthy", events[0].Type)
+	assert.Equal(t, []string{"Genesen.js: 404 Not Found", "Missing Bundesland result 24"}, events[0].Errors)
+	assert.Empty(t, n.healthEvents(date, results))
+
+	exporter.errors = nil
+	events = n.healthEvents(date, runExporters([]Exporter{exporter}))
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "healthy", events[0].Type)
+	assert.Equal(t, 3, exporter.healthChecks)
+
+	n, err = newWebhookNotifier(webhooksConfig{Webhooks: []webhookConfig{{URL: "http://example.org", Events: []string{"daily"}}}}, nil, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, n.healthEvents(date, runExporters([]Exporter{exporter})))
+	assert.Equal(t, 3, exporter.healthChecks)
+}
+
+func TestWebhookDailyEventsInVienna(t *testing.T) {
+	n, err := newWebhookNotifier(webhooksConfig{Webhooks: []webhookConfig{{URL: "http://example.org"}}}, nil, nil)
+	assert.Nil(t, err)
+	previous := metrics{{"cov19_confirmed", nil, 1600}}
+
+	//23:30 UTC is already the 10th in Vienna
+	events := n.dailyEvents(time.Date(2020, 11, 9, 23, 30, 0, 0, time.UTC), previous, metrics{{"cov19_confirmed", nil, 1700}})
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "New figures for 10.11.2020: 1.700 infected (+100)", events[0].Summary)
+	assert.Empty(t, n.dailyEvents(time.Date(2020, 11, 10, 9, 0, 0, 0, time.UTC), previous, metrics{{"cov19_confirmed", nil, 1800}}))
+	assert.Equal(t, 1, len(n.dailyEvents(time.Date(2020, 11, 10, 23, 0, 0, 0, time.UTC), previous, metrics{{"cov19_confirmed", nil, 1800}})))
+}
+
+func TestWebhookRetriesAndDeadLetter(t *testing.T) {
+	receiver := &webhookReceiver{failures: 2}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "webhooks")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	retries := 2
+	n, err := newWebhookNotifier(webhooksConfig{DeadLetter: filepath.Join(dir, "dead-letter.jsonl"), Webhooks: []webhookConfig{
+		{URL: server.URL, Events: []string{"daily"}, Retries: &retries, Backoff: time.Millisecond, Payload: `{"text": {{json .Summary}}}`},
+	}}, nil, nil)
+	assert.Nil(t, err)
+	event := webhookEvent{Type: "daily", Summary: `New "figures"`}
+	n.send(n.webhooks[0], event)
+	n.wait()
+	assert.Equal(t, 3, len(receiver.requests))
+	assert.Equal(t, `{"text": "New \"figures\""}`, receiver.requests[2].body)
+	_, err = os.Stat(filepath.Join(dir, "dead-letter.jsonl"))
+	assert.True(t, os.IsNotExist(err))
+
+	receiver.failures = 5
+	n.send(n.webhooks[0], event)
+	n.wait()
+	assert.Equal(t, 6, len(receiver.requests))
+	content, err := ioutil.ReadFile(filepath.Join(dir, "dead-letter.jsonl"))
+	assert.Nil(t, err)
+	letter := deadLetter{}
+	assert.Nil(t, json.Unmarshal(content, &letter))
+	assert.Equal(t, server.URL, letter.URL)
+	assert.Equal(t, 3, letter.Attempts)
+	assert.True(t, strings.HasSuffix(letter.Error, "responded with 503 Service Unavailable"))
+	assert.JSONEq(t, `{"text": "New \"figures\""}`, string(letter.Payload))
+}
+
+func TestWebhookSignature(t *testing.T) {
+	assert.Equal(t, "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		webhookSignature("key", []byte("The quick brown fox jumps over the lazy dog")))
+}
+
+func TestWebhookSummary(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	summary := func(date time.Time) (dailySummary, error) {
+		return dailySummary{Date: date, HasHistory: true, Infected: 1700, NewCases: 100, TopNewCases: []summaryRegion{{"Graz(Stadt)", 10}}}, nil
+	}
+	n, err := newWebhookNotifier(webhooksConfig{Webhooks: []webhookConfig{
+		{URL: server.URL, Events: []string{"summary"}},
+		{URL: server.URL, Events: []string{"summary"}, Format: "text", Lang: "en"},
+	}}, nil, summary)
+	assert.Nil(t, err)
+
+	date := time.Date(2020, 11, 9, 9, 0, 0, 0, time.UTC)
+	n.check(date, nil, metrics{{"cov19_confirmed", nil, 1600}}, metrics{{"cov19_confirmed", nil, 1700}})
+	n.wait()
+	assert.Equal(t, 2, len(receiver.requests))
+
+	for _, r := range receiver.requests {
+		assert.Equal(t, "summary", r.header.Get("X-Covid19-Event"))
+		if strings.Contains(r.body, `"blocks"`) {
+			message := map[string]interface{}{}
+			assert.Nil(t, json.Unmarshal([]byte(r.body), &message))
+			assert.Contains(t, message["text"], "*COVID-19 in Österreich*")
+		} else {
+			e := webhookEvent{}
+			assert.Nil(t, json.Unmarshal([]byte(r.body), &e))
+			assert.Equal(t, "summary", e.Type)
+			assert.Contains(t, e.Summary, "New cases: +100 (total 1,700)\n")
+			assert.Contains(t, e.Summary, "Most new cases:\n1. Graz(Stadt): +10\n")
+		}
+	}
+
+	n.check(date.Add(time.Hour), nil, metrics{{"cov19_confirmed", nil, 1700}}, metrics{{"cov19_confirmed", nil, 1750}})
+	n.wait()
+	assert.Equal(t, 2, len(receiver.requests))
+}